@@ -1,8 +1,9 @@
 import axios from 'axios';
+import withCsrf from './csrf';
 const apiUrl = import.meta.env.VITE_API_BASE_URL;
 
-export default axios.create({
+export default withCsrf(axios.create({
     baseURL:apiUrl,
     headers:{'Content-Type':'application/json'},
     withCredentials: true,
-})
\ No newline at end of file
+}))
\ No newline at end of file
@@ -1,15 +1,16 @@
 import axios from 'axios';
+import withCsrf from './csrf';
 
 const API_BASE_URL = 'http://localhost:9090'; // Replace with your API base URL
 
-const axiosPrivate = axios.create({
+const axiosPrivate = withCsrf(axios.create({
   baseURL: API_BASE_URL,
   headers: {
     'Content-Type': 'application/json',
 
   },
   withCredentials: true, // important for HTTP-only cookies
-});
+}));
 
 // Add a request interceptor to include the token
 // axiosPrivate.interceptors.request.use(
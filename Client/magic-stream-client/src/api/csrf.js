@@ -0,0 +1,53 @@
+import axios from 'axios';
+
+const CSRF_HEADER = 'X-CSRF-Token';
+const SAFE_METHODS = ['get', 'head', 'options'];
+
+// One token per page load, shared by every axios instance
+let csrfToken = null;
+let csrfRequest = null;
+
+const fetchCsrfToken = (baseURL) => {
+    if (!csrfRequest) {
+        csrfRequest = axios
+            .get('/api/v1/auth/csrf', { baseURL, withCredentials: true })
+            .then((response) => {
+                csrfToken = response.data.csrf_token;
+                return csrfToken;
+            })
+            .finally(() => {
+                csrfRequest = null;
+            });
+    }
+    return csrfRequest;
+};
+
+// The server rejects cookie-authenticated POST/PUT/PATCH/DELETE requests without the
+// CSRF token; attach it, and fetch a new one once if the server no longer accepts it
+const withCsrf = (instance) => {
+    instance.interceptors.request.use(async (config) => {
+        if (SAFE_METHODS.includes((config.method || 'get').toLowerCase())) {
+            return config;
+        }
+        const token = csrfToken || (await fetchCsrfToken(config.baseURL || instance.defaults.baseURL));
+        config.headers[CSRF_HEADER] = token;
+        return config;
+    });
+
+    instance.interceptors.response.use(
+        (response) => response,
+        async (error) => {
+            const originalRequest = error.config;
+            if (error.response?.data?.code === 'CSRF_TOKEN_INVALID' && originalRequest && !originalRequest._csrfRetry) {
+                originalRequest._csrfRetry = true;
+                csrfToken = null;
+                return instance(originalRequest);
+            }
+            return Promise.reject(error);
+        }
+    );
+
+    return instance;
+};
+
+export default withCsrf;
@@ -2,6 +2,7 @@ import { useEffect, useMemo, useRef } from 'react';
 import axios from 'axios';
 
 import useAuth from './useAuth';
+import withCsrf from '../api/csrf';
 
 const apiUrl = import.meta.env.VITE_API_BASE_URL;
 
@@ -11,10 +12,10 @@ const useAxiosPrivate = () => {
     // Stable axios instance to avoid re-creating and re-fetching on every render
     const axiosAuth = useMemo(
         () =>
-            axios.create({
+            withCsrf(axios.create({
         baseURL: apiUrl,
         withCredentials: true, // important for HTTP-only cookies
-            }),
+            })),
         []
     );
 
@@ -0,0 +1,156 @@
+// Package apierror defines the error envelope every API response uses:
+//
+//	{"error": "<human readable message>", "code": "<STABLE_CODE>", "fields": [...], "details": {...}, "request_id": "..."}
+//
+// "error" stays a plain string so existing clients that display it keep working; clients that
+// branch on the failure should use "code", which never changes once published.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code is a stable machine-readable error code
+type Code string
+
+// Generic codes, one per HTTP status the API returns
+const (
+	CodeBadRequest   Code = "BAD_REQUEST"
+	CodeUnauthorized Code = "UNAUTHORIZED"
+	CodeForbidden    Code = "FORBIDDEN"
+	CodeNotFound     Code = "NOT_FOUND"
+	CodeConflict     Code = "CONFLICT"
+	CodeGone         Code = "GONE"
+	CodeRateLimited  Code = "RATE_LIMITED"
+	CodeInternal     Code = "INTERNAL_ERROR"
+	CodeUnavailable  Code = "SERVICE_UNAVAILABLE"
+)
+
+// Specific codes for failures clients are expected to handle
+const (
+	CodeInvalidInput          Code = "INVALID_INPUT"
+	CodeValidationFailed      Code = "VALIDATION_FAILED"
+	CodeNotAuthenticated      Code = "NOT_AUTHENTICATED"
+	CodeInvalidToken          Code = "INVALID_TOKEN"
+	CodeInvalidCredentials    Code = "INVALID_CREDENTIALS"
+	CodeAccountSuspended      Code = "ACCOUNT_SUSPENDED"
+	CodeMissingPermission     Code = "MISSING_PERMISSION"
+	CodeKidsProfileRestricted Code = "KIDS_PROFILE_RESTRICTED"
+	CodeIncorrectPin          Code = "INCORRECT_PIN"
+	CodeParentalPinRequired   Code = "PARENTAL_PIN_REQUIRED"
+	CodeProfileLocked         Code = "PROFILE_LOCKED"
+	CodeEmailInUse            Code = "EMAIL_IN_USE"
+	CodeProfileLimitReached   Code = "PROFILE_LIMIT_REACHED"
+	CodeImpersonationReadOnly Code = "IMPERSONATION_READ_ONLY"
+	CodeUpstreamUnavailable   Code = "UPSTREAM_UNAVAILABLE"
+	CodeAPIKeyLimitReached    Code = "API_KEY_LIMIT_REACHED"
+	CodeAPIKeyReadOnly        Code = "API_KEY_READ_ONLY"
+	CodeCSRFTokenInvalid      Code = "CSRF_TOKEN_INVALID"
+	CodeOriginNotAllowed      Code = "ORIGIN_NOT_ALLOWED"
+)
+
+// Error is an API error carrying its HTTP status and code
+type Error struct {
+	Status  int                    `json:"-"`
+	Code    Code                   `json:"code"`
+	Message string                 `json:"error"`
+	Fields  []FieldError           `json:"fields,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// New builds an error with an explicit status and code
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithCode returns a copy of e with a more specific code
+func (e *Error) WithCode(code Code) *Error {
+	copied := e.clone()
+	copied.Code = code
+	return copied
+}
+
+// WithDetail returns a copy of e with an extra entry in details
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	copied := e.clone()
+	copied.Details = make(map[string]interface{}, len(e.Details)+1)
+	for k, v := range e.Details {
+		copied.Details[k] = v
+	}
+	copied.Details[key] = value
+	return copied
+}
+
+func (e *Error) clone() *Error {
+	copied := *e
+	return &copied
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+func Gone(message string) *Error {
+	return New(http.StatusGone, CodeGone, message)
+}
+
+func TooManyRequests(message string) *Error {
+	return New(http.StatusTooManyRequests, CodeRateLimited, message)
+}
+
+// Internal is for failures the client can't fix; the message must not include internal error text
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+func Unavailable(message string) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+// ErrNotAuthenticated is returned when a protected handler runs without an authenticated user
+var ErrNotAuthenticated = Unauthorized("User not authenticated").WithCode(CodeNotAuthenticated)
+
+// Respond writes err as the error envelope and aborts the handler chain.
+// Errors that are not *Error are reported as a generic 500 without exposing their text.
+func Respond(c *gin.Context, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = Internal("Internal server error")
+	}
+
+	body := gin.H{"error": apiErr.Message, "code": apiErr.Code}
+	if len(apiErr.Fields) > 0 {
+		body["fields"] = apiErr.Fields
+	}
+	if len(apiErr.Details) > 0 {
+		body["details"] = apiErr.Details
+	}
+	if requestId := c.GetString("requestId"); requestId != "" {
+		body["request_id"] = requestId
+	}
+
+	c.AbortWithStatusJSON(apiErr.Status, body)
+}
@@ -0,0 +1,132 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one invalid field, named as it appears in the JSON body
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func init() {
+	// Validation run by ShouldBindJSON reports JSON names too
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		UseJSONFieldNames(v)
+	}
+}
+
+// NewValidator returns a validator that reports fields by their JSON name
+func NewValidator() *validator.Validate {
+	v := validator.New()
+	UseJSONFieldNames(v)
+	return v
+}
+
+// UseJSONFieldNames makes v report fields by their json tag rather than the Go field name
+func UseJSONFieldNames(v *validator.Validate) {
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+}
+
+// Invalid converts a binding or validation error into a 400 without leaking decoder internals
+func Invalid(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		apiErr := New(http.StatusBadRequest, CodeValidationFailed, "Validation failed")
+		for _, fe := range validationErrs {
+			apiErr.Fields = append(apiErr.Fields, FieldError{
+				Field:   fieldPath(fe),
+				Rule:    fe.Tag(),
+				Message: fieldMessage(fe),
+			})
+		}
+		return apiErr
+	}
+
+	apiErr := New(http.StatusBadRequest, CodeInvalidInput, "Invalid input")
+
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		apiErr.Fields = []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: "must be of type " + typeErr.Type.String(),
+		}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		apiErr.Message = "Request body is not valid JSON"
+	case errors.Is(err, io.EOF):
+		apiErr.Message = "Request body is empty"
+	}
+	return apiErr
+}
+
+// fieldPath drops the top-level struct name from the namespace: "Req.genre[0].genre_id" -> "genre[0].genre_id"
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if", "required_with", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "min":
+		if fe.Kind() == reflect.String || fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at least %s items/characters", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if fe.Kind() == reflect.String || fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at most %s items/characters", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "len":
+		return "must have length " + fe.Param()
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "lte":
+		return "must be less than or equal to " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "numeric", "number":
+		return "must be numeric"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "dive":
+		return "contains an invalid item"
+	}
+	return fmt.Sprintf("failed the %q rule", fe.Tag())
+}
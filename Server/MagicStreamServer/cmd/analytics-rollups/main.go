@@ -0,0 +1,87 @@
+// Command analytics-rollups backfills or rebuilds the analytics_daily collection.
+//
+//	go run ./cmd/analytics-rollups backfill
+//	go run ./cmd/analytics-rollups rebuild -from 2025-01-01 [-to 2025-03-31]
+//
+// backfill computes every day since the first recorded activity that has no rollup yet.
+// rebuild recomputes every day in the range (to defaults to today), overwriting existing facts.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: analytics-rollups backfill")
+	fmt.Fprintln(os.Stderr, "       analytics-rollups rebuild -from YYYY-MM-DD [-to YYYY-MM-DD]")
+	os.Exit(2)
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func main() {
+	utils.InitLogger()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	client := database.Connect()
+	if client == nil {
+		fatal("Failed to connect to MongoDB")
+	}
+	defer client.Disconnect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	switch os.Args[1] {
+	case "backfill":
+		filled, err := workers.BackfillAnalyticsRollups(ctx, client)
+		if err != nil {
+			fatal("Backfill failed", "error", err)
+		}
+		slog.Info("Backfilled analytics rollups", "days", filled)
+
+	case "rebuild":
+		fs := flag.NewFlagSet("rebuild", flag.ExitOnError)
+		fromStr := fs.String("from", "", "first day to rebuild (YYYY-MM-DD, required)")
+		toStr := fs.String("to", "", "last day to rebuild (YYYY-MM-DD, default today)")
+		fs.Parse(os.Args[2:])
+
+		from, err := time.Parse("2006-01-02", *fromStr)
+		if err != nil {
+			usage()
+		}
+		to := workers.UTCDay(time.Now())
+		if *toStr != "" {
+			if to, err = time.Parse("2006-01-02", *toStr); err != nil {
+				usage()
+			}
+		}
+		if to.Before(from) {
+			fatal("-to must not be before -from")
+		}
+
+		rebuilt, err := workers.RebuildAnalyticsRollups(ctx, client, from, to)
+		if err != nil {
+			fatal("Rebuild failed", "error", err)
+		}
+		slog.Info("Rebuilt analytics rollups", "days", rebuilt)
+
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,159 @@
+// Command openapi-contract checks a running server's responses against the OpenAPI document it serves.
+//
+//	go run ./cmd/openapi-contract [-base http://localhost:8080] [-token <access token>] [-param imdb_id=tt0111161 ...]
+//
+// Every documented GET route is called once. Routes that need login are only called when a token is
+// given (-token or CONTRACT_ACCESS_TOKEN), and routes with path parameters only when every parameter
+// has a -param value. Exits 1 when any JSON response diverges from the spec, so CI can run it
+// against a seeded instance started with OPENAPI_VALIDATE_RESPONSES=true.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/openapi"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+)
+
+// paramFlags collects repeated -param name=value flags
+type paramFlags map[string]string
+
+func (p paramFlags) String() string { return fmt.Sprint(map[string]string(p)) }
+
+func (p paramFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+	p[name] = val
+	return nil
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func main() {
+	utils.InitLogger()
+
+	params := paramFlags{}
+	base := flag.String("base", "http://localhost:8080", "server base URL")
+	token := flag.String("token", os.Getenv("CONTRACT_ACCESS_TOKEN"), "access token sent as the access_token cookie")
+	flag.Var(params, "param", "path parameter value as name=value (repeatable)")
+	flag.Parse()
+	baseURL := strings.TrimRight(*base, "/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	status, body, _, err := get(ctx, client, baseURL+"/openapi.json", "")
+	if err != nil || status != http.StatusOK {
+		fatal("Failed to fetch the OpenAPI document", "status", status, "error", err)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(body, &doc); err != nil {
+		fatal("OpenAPI document is not valid JSON", "error", err)
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	checked, skipped, failed := 0, 0, 0
+	for _, path := range paths {
+		op := doc.Paths[path]["get"]
+		if op == nil {
+			continue
+		}
+
+		url, ok := fillPath(path, params)
+		if !ok || (len(op.Security) > 0 && !optionalAuth(op) && *token == "") {
+			skipped++
+			continue
+		}
+
+		status, body, contentType, err := get(ctx, client, baseURL+url, *token)
+		if err != nil {
+			fatal("Request failed", "path", url, "error", err)
+		}
+		if !strings.HasPrefix(contentType, "application/json") {
+			skipped++
+			continue
+		}
+
+		checked++
+		if problems := doc.ValidateResponse(http.MethodGet, path, status, body); len(problems) > 0 {
+			failed++
+			fmt.Printf("FAIL GET %s (%d)\n", url, status)
+			for _, problem := range problems {
+				fmt.Printf("    %s\n", problem)
+			}
+			continue
+		}
+		fmt.Printf("ok   GET %s (%d)\n", url, status)
+	}
+
+	fmt.Printf("\n%d checked, %d skipped, %d diverged from the spec\n", checked, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// fillPath substitutes {name} segments from params and reports whether all were provided
+func fillPath(path string, params paramFlags) (string, bool) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") {
+			continue
+		}
+		value, ok := params[strings.Trim(segment, "{}")]
+		if !ok {
+			return "", false
+		}
+		segments[i] = value
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// optionalAuth reports whether the operation also accepts anonymous calls
+func optionalAuth(op *openapi.OperationObject) bool {
+	for _, requirement := range op.Security {
+		if len(requirement) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func get(ctx context.Context, client *http.Client, url, token string) (int, []byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	if token != "" {
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, body, resp.Header.Get("Content-Type"), err
+}
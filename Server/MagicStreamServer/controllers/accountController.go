@@ -3,16 +3,43 @@ package controllers
 import (
 	"context"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// meSubscription summarises the active subscription; without one only can_stream is set
+type meSubscription struct {
+	PlanID    string     `json:"plan_id,omitempty"`
+	PlanName  string     `json:"plan_name,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CanStream bool       `json:"can_stream"`
+}
+
+type meResponse struct {
+	UserID              string                 `json:"user_id"`
+	FirstName           string                 `json:"first_name"`
+	LastName            string                 `json:"last_name"`
+	Email               string                 `json:"email"`
+	Role                string                 `json:"role"`
+	Permissions         []string               `json:"permissions"`
+	Profile             models.ProfileResponse `json:"profile"`
+	FavouriteGenres     []models.Genre         `json:"favourite_genres"`
+	EmailVerified       bool                   `json:"email_verified"`
+	Subscription        meSubscription         `json:"subscription"`
+	RatingsCount        int64                  `json:"ratings_count"`
+	DeletionScheduledAt *time.Time             `json:"deletion_scheduled_at,omitempty"`
+}
+
 // GetMe returns canonical server-side user profile
 func GetMe(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -21,7 +48,7 @@ func GetMe(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
@@ -30,10 +57,10 @@ func GetMe(client *mongo.Client) gin.HandlerFunc {
 		err = userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				apierror.Respond(c, apierror.NotFound("User not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch user profile"))
 			return
 		}
 
@@ -46,54 +73,69 @@ func GetMe(client *mongo.Client) gin.HandlerFunc {
 		}
 		err = subscriptionCollection.FindOne(ctx, subFilter).Decode(&subscription)
 		
-		var subscriptionInfo map[string]interface{}
+		var subscriptionInfo meSubscription
 		if err == nil && subscription.ExpiresAt.After(time.Now()) {
+			subscriptionInfo = meSubscription{
+				PlanID:    subscription.PlanID,
+				Status:    subscription.Status,
+				ExpiresAt: &subscription.ExpiresAt,
+				CanStream: true,
+			}
+
 			// Get plan details
 			planCollection := database.OpenCollection("plans", client)
 			var plan models.Plan
-			planErr := planCollection.FindOne(ctx, bson.D{{Key: "plan_id", Value: subscription.PlanID}}).Decode(&plan)
-			if planErr == nil {
-				subscriptionInfo = map[string]interface{}{
-					"plan_id":    subscription.PlanID,
-					"plan_name":  plan.Name,
-					"status":     subscription.Status,
-					"expires_at": subscription.ExpiresAt,
-					"can_stream": true,
-				}
-			} else {
-				subscriptionInfo = map[string]interface{}{
-					"plan_id":    subscription.PlanID,
-					"status":     subscription.Status,
-					"expires_at": subscription.ExpiresAt,
-					"can_stream": true,
-				}
+			if planErr := planCollection.FindOne(ctx, bson.D{{Key: "plan_id", Value: subscription.PlanID}}).Decode(&plan); planErr == nil {
+				subscriptionInfo.PlanName = plan.Name
 			}
-		} else {
-			subscriptionInfo = map[string]interface{}{
-				"can_stream": false,
+		}
+
+		// Preferences and ratings follow the selected profile
+		profileID := utils.GetProfileIdFromContext(c)
+		activeProfile := primaryProfileResponse(user)
+		if profileID != "" {
+			var profile models.Profile
+			err = database.OpenCollection("profiles", client).FindOne(ctx, bson.D{
+				{Key: "profile_id", Value: profileID},
+				{Key: "user_id", Value: userID},
+			}).Decode(&profile)
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to fetch profile"))
+				return
 			}
+			activeProfile = profileResponse(profile)
 		}
 
 		// Get user ratings count (optional, for display)
 		ratingCollection := database.OpenCollection("ratings", client)
-		ratingCount, _ := ratingCollection.CountDocuments(ctx, bson.D{{Key: "user_id", Value: userID}})
-
-		response := map[string]interface{}{
-			"user_id":          user.UserID,
-			"first_name":       user.FirstName,
-			"last_name":        user.LastName,
-			"email":            user.Email,
-			"role":             user.Role,
-			"favourite_genres": user.FavouriteGenres,
-			"email_verified":   user.EmailVerified,
-			"subscription":     subscriptionInfo,
-			"ratings_count":    ratingCount,
-		}
+		ratingCount, _ := ratingCollection.CountDocuments(ctx, profileScopedFilter(userID, profileID))
 
-		c.JSON(http.StatusOK, response)
+		c.JSON(http.StatusOK, meResponse{
+			UserID:              user.UserID,
+			FirstName:           user.FirstName,
+			LastName:            user.LastName,
+			Email:               user.Email,
+			Role:                user.Role,
+			Permissions:         utils.GetPermissionsFromContext(c),
+			Profile:             activeProfile,
+			FavouriteGenres:     activeProfile.FavouriteGenres,
+			EmailVerified:       user.EmailVerified,
+			Subscription:        subscriptionInfo,
+			RatingsCount:        ratingCount,
+			DeletionScheduledAt: user.DeletionScheduledAt,
+		})
 	}
 }
 
+type updatePreferencesRequest struct {
+	FavouriteGenres []models.Genre `json:"favourite_genres" validate:"dive"`
+}
+
+type updatePreferencesResponse struct {
+	Message         string         `json:"message"`
+	FavouriteGenres []models.Genre `json:"favourite_genres"`
+}
+
 // UpdatePreferences updates user's favourite genres
 func UpdatePreferences(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -102,16 +144,38 @@ func UpdatePreferences(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
-		var req struct {
-			FavouriteGenres []models.Genre `json:"favourite_genres" validate:"dive"`
-		}
+		var req updatePreferencesRequest
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		// Additional profiles keep their own preferences
+		if profileID := utils.GetProfileIdFromContext(c); profileID != "" {
+			result, err := database.OpenCollection("profiles", client).UpdateOne(ctx, bson.D{
+				{Key: "profile_id", Value: profileID},
+				{Key: "user_id", Value: userID},
+			}, bson.M{
+				"$set": bson.M{
+					"favourite_genres": req.FavouriteGenres,
+					"updated_at":       time.Now(),
+				},
+			})
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to update preferences"))
+				return
+			}
+			if result.MatchedCount == 0 {
+				apierror.Respond(c, apierror.NotFound("Profile not found"))
+				return
+			}
+
+			c.JSON(http.StatusOK, updatePreferencesResponse{Message: "Preferences updated successfully", FavouriteGenres: req.FavouriteGenres})
 			return
 		}
 
@@ -127,19 +191,333 @@ func UpdatePreferences(client *mongo.Client) gin.HandlerFunc {
 		filter := bson.D{{Key: "user_id", Value: userID}}
 		result, err := userCollection.UpdateOne(ctx, filter, update)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+			apierror.Respond(c, apierror.Internal("Failed to update preferences"))
 			return
 		}
 
 		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			apierror.Respond(c, apierror.NotFound("User not found"))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":           "Preferences updated successfully",
-			"favourite_genres": req.FavouriteGenres,
-		})
+		c.JSON(http.StatusOK, updatePreferencesResponse{Message: "Preferences updated successfully", FavouriteGenres: req.FavouriteGenres})
 	}
 }
 
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6"`
+}
+
+// ChangePassword updates the password of the logged-in user after checking the current one and
+// signs out every other session; Bearer clients get replacement tokens in the response
+func ChangePassword(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		var req changePasswordRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		userCollection := database.OpenCollection("users", client)
+		var user models.User
+		err = userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+			apierror.Respond(c, apierror.Unauthorized("Current password is incorrect").WithCode(apierror.CodeInvalidCredentials))
+			return
+		}
+
+		if req.CurrentPassword == req.NewPassword {
+			apierror.Respond(c, apierror.BadRequest("New password must be different from the current password"))
+			return
+		}
+
+		hashedPassword, err := utils.HashPassword(req.NewPassword)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to hash password"))
+			return
+		}
+
+		update := bson.M{
+			"$set": bson.M{
+				"password":  hashedPassword,
+				"update_at": time.Now(),
+			},
+		}
+
+		_, err = userCollection.UpdateOne(ctx, bson.D{{Key: "user_id", Value: userID}}, update)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to update password"))
+			return
+		}
+
+		if err := utils.DefaultMailer.Send(user.Email, "Your MagicStream password was changed",
+			"The password for your MagicStream account was just changed. If this wasn't you, reset your password immediately."); err != nil {
+			utils.RequestLogger(c).Warn("Failed to send password change notification", "error", err)
+		}
+
+		// Sign out every other session; this one gets new tokens issued after the revocation
+		validAfter, err := utils.RevokeAllTokens(ctx, client, userID)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Password changed, but other sessions could not be signed out"))
+			return
+		}
+
+		response := refreshTokenResponse{Message: "Password changed successfully"}
+		if utils.GetAPIKeyIdFromContext(c) == "" {
+			permissions, err := utils.ResolvePermissions(ctx, client, user.Role)
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to load permissions"))
+				return
+			}
+			token, refreshToken, err := reissueSession(c, client, user, utils.GetLockedProfileIdFromContext(c), permissions, validAfter)
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to issue tokens"))
+				return
+			}
+			response.Token, response.RefreshToken = token, refreshToken
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+type requestEmailChangeRequest struct {
+	NewEmail        string `json:"new_email" validate:"required,email"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+// RequestEmailChange emails a confirmation token to the new address; it is never returned in the response.
+// The email is only swapped once the token is confirmed via ConfirmEmailChange.
+func RequestEmailChange(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		var req requestEmailChangeRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		req.NewEmail = strings.TrimSpace(req.NewEmail)
+		if err := validate.Struct(req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		userCollection := database.OpenCollection("users", client)
+		var user models.User
+		err = userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+			apierror.Respond(c, apierror.Unauthorized("Current password is incorrect").WithCode(apierror.CodeInvalidCredentials))
+			return
+		}
+
+		if strings.EqualFold(req.NewEmail, user.Email) {
+			apierror.Respond(c, apierror.BadRequest("New email must be different from the current email"))
+			return
+		}
+
+		count, err := userCollection.CountDocuments(ctx, bson.D{{Key: "email", Value: req.NewEmail}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to check existing user"))
+			return
+		}
+		if count > 0 {
+			apierror.Respond(c, apierror.Conflict("Email is already in use").WithCode(apierror.CodeEmailInUse))
+			return
+		}
+
+		token, err := generateToken()
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to generate token"))
+			return
+		}
+
+		emailChangeCollection := database.OpenCollection("email_changes", client)
+		now := time.Now()
+
+		// Only the latest request stays valid
+		_, err = emailChangeCollection.UpdateMany(ctx, bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		}, bson.M{"$set": bson.M{"used_at": now}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to invalidate previous email change requests"))
+			return
+		}
+
+		change := models.EmailChange{
+			UserID:    userID,
+			OldEmail:  user.Email,
+			NewEmail:  req.NewEmail,
+			Token:     token,
+			ExpiresAt: now.Add(24 * time.Hour), // 24 hour expiry
+			CreatedAt: now,
+		}
+
+		_, err = emailChangeCollection.InsertOne(ctx, change)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to create email change request"))
+			return
+		}
+
+		// The token only goes to the new address, proving the user controls it
+		if err := utils.DefaultMailer.Send(req.NewEmail, "Confirm your new MagicStream email",
+			"Use this token to confirm your new email address: "+token); err != nil {
+			utils.RequestLogger(c).Error("Failed to send email change confirmation", "error", err)
+			apierror.Respond(c, apierror.Internal("Failed to send the confirmation email"))
+			return
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "A confirmation token was sent to the new email address"})
+	}
+}
+
+type confirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type confirmEmailChangeResponse struct {
+	Message       string `json:"message"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// ConfirmEmailChange validates the token, swaps the email and notifies the old address.
+// email_verified is reset so the new address goes through the normal verification flow.
+func ConfirmEmailChange(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		var req confirmEmailChangeRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		emailChangeCollection := database.OpenCollection("email_changes", client)
+		filter := bson.D{
+			{Key: "token", Value: req.Token},
+			{Key: "user_id", Value: userID},
+			{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+			{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		}
+
+		var change models.EmailChange
+		err = emailChangeCollection.FindOne(ctx, filter).Decode(&change)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.BadRequest("Invalid or expired token"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to validate token"))
+			return
+		}
+
+		// Re-check uniqueness: the address may have been registered since the request
+		userCollection := database.OpenCollection("users", client)
+		count, err := userCollection.CountDocuments(ctx, bson.D{{Key: "email", Value: change.NewEmail}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to check existing user"))
+			return
+		}
+		if count > 0 {
+			apierror.Respond(c, apierror.Conflict("Email is already in use").WithCode(apierror.CodeEmailInUse))
+			return
+		}
+
+		update := bson.M{
+			"$set": bson.M{
+				"email":          change.NewEmail,
+				"email_verified": false,
+				"update_at":      time.Now(),
+			},
+		}
+
+		result, err := userCollection.UpdateOne(ctx, bson.D{{Key: "user_id", Value: userID}}, update)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to update email"))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Respond(c, apierror.NotFound("User not found"))
+			return
+		}
+
+		// Mark token as used and drop verification tokens issued for the old address.
+		// The email has already changed, so failures here are logged rather than reported.
+		now := time.Now()
+		_, err = emailChangeCollection.UpdateOne(ctx, bson.D{{Key: "_id", Value: change.ID}}, bson.M{
+			"$set": bson.M{"used_at": now},
+		})
+		if err != nil {
+			utils.RequestLogger(c).Error("Failed to mark email change token as used", "error", err)
+		}
+		emailVerificationCollection := database.OpenCollection("email_verifications", client)
+		_, err = emailVerificationCollection.UpdateMany(ctx, bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		}, bson.M{"$set": bson.M{"used_at": now}})
+		if err != nil {
+			utils.RequestLogger(c).Error("Failed to invalidate verification tokens for the old email", "error", err)
+		}
+
+		if err := utils.DefaultMailer.Send(change.OldEmail, "Your MagicStream email was changed",
+			"The email for your MagicStream account was changed to "+change.NewEmail+". If this wasn't you, contact support immediately."); err != nil {
+			utils.RequestLogger(c).Warn("Failed to send email change notification", "error", err)
+		}
+
+		c.JSON(http.StatusOK, confirmEmailChangeResponse{Message: "Email changed successfully", Email: change.NewEmail})
+	}
+}
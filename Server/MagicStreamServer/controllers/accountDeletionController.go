@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// clearAuthCookies expires the access and refresh token cookies
+func clearAuthCookies(c *gin.Context) {
+	for _, name := range []string{"access_token", "refresh_token"} {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteNoneMode,
+		})
+	}
+}
+
+// ExportMyData returns a ZIP archive with one JSON file per collection keyed to the current user
+func ExportMyData(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 60*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		userFilter := bson.D{{Key: "user_id", Value: userID}}
+
+		// Never export password/token/refresh_token
+		userProjection := bson.M{
+			"password":      0,
+			"token":         0,
+			"refresh_token": 0,
+		}
+		var userDoc bson.M
+		err = database.OpenCollection("users", client).FindOne(ctx, userFilter, options.FindOne().SetProjection(userProjection)).Decode(&userDoc)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		ratings := []models.Rating{}
+		watchlists := []models.Watchlist{}
+		subscriptions := []models.Subscription{}
+		payments := []models.Payment{}
+		profiles := []models.Profile{}
+		watchProgress := []models.WatchProgress{}
+		apiKeys := []models.APIKey{}
+		reviewReports := []models.ReviewReport{}
+		reviewVotes := []models.ReviewVote{}
+
+		collections := []struct {
+			name   string
+			result interface{}
+		}{
+			{"ratings", &ratings},
+			{"watchlists", &watchlists},
+			{"subscriptions", &subscriptions},
+			{"payments", &payments},
+			{"profiles", &profiles},
+			{"watch_progress", &watchProgress},
+			{"api_keys", &apiKeys},
+			{"review_reports", &reviewReports},
+			{"review_votes", &reviewVotes},
+		}
+
+		files := map[string]interface{}{"user.json": userDoc}
+		names := []string{"user.json"}
+		for _, coll := range collections {
+			cursor, err := database.OpenCollection(coll.name, client).Find(ctx, userFilter)
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to fetch "+coll.name))
+				return
+			}
+			err = cursor.All(ctx, coll.result)
+			cursor.Close(ctx)
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to decode "+coll.name))
+				return
+			}
+			files[coll.name+".json"] = coll.result
+			names = append(names, coll.name+".json")
+		}
+
+		var buf bytes.Buffer
+		zipWriter := zip.NewWriter(&buf)
+		for _, name := range names {
+			data, err := json.MarshalIndent(files[name], "", "  ")
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to encode export"))
+				return
+			}
+			w, err := zipWriter.Create(name)
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to build export"))
+				return
+			}
+			if _, err := w.Write(data); err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to build export"))
+				return
+			}
+		}
+		if err := zipWriter.Close(); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to build export"))
+			return
+		}
+
+		filename := "magicstream-export-" + time.Now().UTC().Format("20060102") + ".zip"
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Data(http.StatusOK, "application/zip", buf.Bytes())
+	}
+}
+
+type deleteMeRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type deleteMeResponse struct {
+	Message             string    `json:"message"`
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// DeleteMe schedules the current user's account for deletion after the configured grace period.
+// Sessions are revoked immediately; the user can log back in and cancel before the deadline.
+func DeleteMe(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 60*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		var req deleteMeRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		userCollection := database.OpenCollection("users", client)
+		var user models.User
+		err = userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			apierror.Respond(c, apierror.Unauthorized("Password is incorrect").WithCode(apierror.CodeInvalidCredentials))
+			return
+		}
+
+		grace := workers.AccountDeletionGracePeriod()
+		if grace == 0 {
+			if err := workers.PurgeUser(ctx, client, userID); err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to delete account"))
+				return
+			}
+			clearAuthCookies(c)
+			c.JSON(http.StatusOK, messageResponse{Message: "Account deleted"})
+			return
+		}
+
+		now := time.Now()
+		scheduledAt := now.Add(grace)
+		update := bson.M{
+			"$set": bson.M{
+				"deletion_requested_at": now,
+				"deletion_scheduled_at": scheduledAt,
+				"tokens_valid_after":    utils.RevocationTime(),
+				"token":                 "",
+				"refresh_token":         "",
+				"update_at":             now,
+			},
+		}
+
+		_, err = userCollection.UpdateOne(ctx, bson.D{{Key: "user_id", Value: userID}}, update)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to schedule account deletion"))
+			return
+		}
+
+		if err := utils.DefaultMailer.Send(user.Email, "Your MagicStream account is scheduled for deletion",
+			"Your account and data will be deleted on "+scheduledAt.Format("January 2, 2006")+". Log in before then to cancel."); err != nil {
+			utils.RequestLogger(c).Warn("Failed to send account deletion notification", "error", err)
+		}
+
+		clearAuthCookies(c)
+		c.JSON(http.StatusAccepted, deleteMeResponse{Message: "Account scheduled for deletion", DeletionScheduledAt: scheduledAt})
+	}
+}
+
+// CancelAccountDeletion clears a pending deletion request for the current user
+func CancelAccountDeletion(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		userCollection := database.OpenCollection("users", client)
+		filter := bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "deletion_scheduled_at", Value: bson.D{{Key: "$exists", Value: true}}},
+		}
+		update := bson.M{
+			"$unset": bson.M{
+				"deletion_requested_at": "",
+				"deletion_scheduled_at": "",
+			},
+			"$set": bson.M{"update_at": time.Now()},
+		}
+
+		result, err := userCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to cancel account deletion"))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Respond(c, apierror.NotFound("No pending account deletion"))
+			return
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "Account deletion cancelled"})
+	}
+}
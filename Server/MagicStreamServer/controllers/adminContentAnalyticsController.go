@@ -0,0 +1,367 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// normalizeSearchQuery lowercases and collapses whitespace so "The  Matrix" and "the matrix" count together
+func normalizeSearchQuery(q string) string {
+	q = strings.ToLower(strings.Join(strings.Fields(q), " "))
+	if runes := []rune(q); len(runes) > 100 {
+		q = string(runes[:100])
+	}
+	return q
+}
+
+// recordZeroResultSearch bumps today's counter for a search that matched no titles.
+// Failures are logged only; they must never break the search itself.
+func recordZeroResultSearch(ctx context.Context, client *mongo.Client, query string) {
+	query = normalizeSearchQuery(query)
+	if len([]rune(query)) < 2 {
+		return
+	}
+
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	_, err := database.OpenCollection("zero_result_searches", client).UpdateOne(ctx,
+		bson.D{{Key: "query", Value: query}, {Key: "day", Value: day}},
+		bson.M{
+			"$inc": bson.M{"count": 1},
+			"$set": bson.M{"last_seen_at": now},
+		},
+		options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		slog.Warn("Failed to record zero-result search", "error", err)
+	}
+}
+
+type contentMovieCount struct {
+	ImdbID string `bson:"_id" json:"imdb_id"`
+	Title  string `bson:"title" json:"title"`
+	Count  int64  `bson:"count" json:"count"`
+}
+
+type contentMovieRating struct {
+	ImdbID    string  `bson:"_id" json:"imdb_id"`
+	Title     string  `bson:"title" json:"title"`
+	Ratings   int64   `bson:"ratings" json:"ratings"`
+	AvgRating float64 `bson:"avg_rating" json:"avg_rating"`
+}
+
+type genreActivity struct {
+	GenreID       int    `json:"genre_id"`
+	GenreName     string `json:"genre_name"`
+	WatchlistAdds int64  `json:"watchlist_adds"`
+	Ratings       int64  `json:"ratings"`
+}
+
+type genreTrendPoint struct {
+	PeriodStart string          `json:"period_start"`
+	Genres      []genreActivity `json:"genres"`
+}
+
+type zeroResultQuery struct {
+	Query      string    `bson:"_id" json:"query"`
+	Count      int64     `bson:"count" json:"count"`
+	LastSeenAt time.Time `bson:"last_seen_at" json:"last_seen_at"`
+}
+
+type contentAnalytics struct {
+	TopWatchlisted     []contentMovieCount  `json:"top_watchlisted"`
+	MostRated          []contentMovieRating `json:"most_rated"`
+	HighestRated       []contentMovieRating `json:"highest_rated"`
+	Genres             []genreActivity      `json:"genres"`
+	GenreTrends        []genreTrendPoint    `json:"genre_trends"`
+	ZeroResultSearches []zeroResultQuery    `json:"zero_result_searches"`
+}
+
+// movieTitleStages joins the movie title onto rows grouped by imdb_id
+func movieTitleStages() []bson.M {
+	return []bson.M{
+		{"$lookup": bson.M{
+			"from":         "movies",
+			"localField":   "_id",
+			"foreignField": "imdb_id",
+			"as":           "movie",
+		}},
+		{"$unwind": bson.M{"path": "$movie", "preserveNullAndEmptyArrays": true}},
+		{"$addFields": bson.M{"title": "$movie.title"}},
+		{"$project": bson.M{"movie": 0}},
+	}
+}
+
+// genrePeriodPipeline counts documents per period and genre of the referenced movie
+func genrePeriodPipeline(match bson.M, granularity string) []bson.M {
+	return []bson.M{
+		{"$match": match},
+		{"$lookup": bson.M{
+			"from":         "movies",
+			"localField":   "imdb_id",
+			"foreignField": "imdb_id",
+			"as":           "movie",
+		}},
+		{"$unwind": "$movie"},
+		{"$unwind": "$movie.genre"},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"period":   periodKeyExpr("$created_at", granularity),
+				"genre_id": "$movie.genre.genre_id",
+			},
+			"genre_name": bson.M{"$first": "$movie.genre.genre_name"},
+			"count":      bson.M{"$sum": 1},
+		}},
+	}
+}
+
+// loadContentAnalytics reports catalog engagement for [from, to]: watchlist adds, rating volume
+// and averages per title, genre activity per period, and searches that found nothing
+func loadContentAnalytics(ctx context.Context, client *mongo.Client, granularity, fromStr, toStr string, limit, minRatings int64) (contentAnalytics, error) {
+	result := contentAnalytics{
+		TopWatchlisted:     []contentMovieCount{},
+		MostRated:          []contentMovieRating{},
+		HighestRated:       []contentMovieRating{},
+		Genres:             []genreActivity{},
+		GenreTrends:        []genreTrendPoint{},
+		ZeroResultSearches: []zeroResultQuery{},
+	}
+
+	watchlistCollection := database.OpenCollection("watchlists", client)
+	ratingCollection := database.OpenCollection("ratings", client)
+
+	createdAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
+	if err != nil {
+		return result, errInvalidDateRange
+	}
+	dayFilter, err := buildTimeRangeFilter("day", fromStr, toStr)
+	if err != nil {
+		return result, errInvalidDateRange
+	}
+
+	ratingMatch := workers.RatingAggregateMatch()
+	for k, v := range createdAtFilter {
+		ratingMatch[k] = v
+	}
+
+	// Top movies by watchlist adds
+	watchlistPipeline := append([]bson.M{
+		{"$match": createdAtFilter},
+		{"$group": bson.M{"_id": "$imdb_id", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		{"$limit": limit},
+	}, movieTitleStages()...)
+
+	cursor, err := watchlistCollection.Aggregate(ctx, watchlistPipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate watchlist adds")
+	}
+	if err := cursor.All(ctx, &result.TopWatchlisted); err != nil {
+		return result, errors.New("Failed to decode watchlist adds")
+	}
+
+	// Rating volume and average per movie, ranked two ways
+	ratingGroup := []bson.M{
+		{"$match": ratingMatch},
+		{"$group": bson.M{
+			"_id":        "$imdb_id",
+			"ratings":    bson.M{"$sum": 1},
+			"avg_rating": bson.M{"$avg": "$rating"},
+		}},
+	}
+
+	mostRatedPipeline := append(append([]bson.M{}, ratingGroup...),
+		bson.M{"$sort": bson.D{{Key: "ratings", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": limit},
+	)
+	cursor, err = ratingCollection.Aggregate(ctx, append(mostRatedPipeline, movieTitleStages()...))
+	if err != nil {
+		return result, errors.New("Failed to aggregate rating volume")
+	}
+	if err := cursor.All(ctx, &result.MostRated); err != nil {
+		return result, errors.New("Failed to decode rating volume")
+	}
+
+	// A minimum volume keeps a single 5-star rating from topping the list
+	highestRatedPipeline := append(append([]bson.M{}, ratingGroup...),
+		bson.M{"$match": bson.M{"ratings": bson.M{"$gte": minRatings}}},
+		bson.M{"$sort": bson.D{{Key: "avg_rating", Value: -1}, {Key: "ratings", Value: -1}}},
+		bson.M{"$limit": limit},
+	)
+	cursor, err = ratingCollection.Aggregate(ctx, append(highestRatedPipeline, movieTitleStages()...))
+	if err != nil {
+		return result, errors.New("Failed to aggregate average ratings")
+	}
+	if err := cursor.All(ctx, &result.HighestRated); err != nil {
+		return result, errors.New("Failed to decode average ratings")
+	}
+	for i := range result.MostRated {
+		result.MostRated[i].AvgRating = roundTo(result.MostRated[i].AvgRating, 2)
+	}
+	for i := range result.HighestRated {
+		result.HighestRated[i].AvgRating = roundTo(result.HighestRated[i].AvgRating, 2)
+	}
+
+	// Genre activity per period, merged from watchlist adds and ratings
+	type genreRow struct {
+		ID struct {
+			Period  string `bson:"period"`
+			GenreID int    `bson:"genre_id"`
+		} `bson:"_id"`
+		GenreName string `bson:"genre_name"`
+		Count     int64  `bson:"count"`
+	}
+
+	var watchlistGenres, ratingGenres []genreRow
+	cursor, err = watchlistCollection.Aggregate(ctx, genrePeriodPipeline(createdAtFilter, granularity))
+	if err != nil {
+		return result, errors.New("Failed to aggregate genre trends")
+	}
+	if err := cursor.All(ctx, &watchlistGenres); err != nil {
+		return result, errors.New("Failed to decode genre trends")
+	}
+	cursor, err = ratingCollection.Aggregate(ctx, genrePeriodPipeline(ratingMatch, granularity))
+	if err != nil {
+		return result, errors.New("Failed to aggregate genre trends")
+	}
+	if err := cursor.All(ctx, &ratingGenres); err != nil {
+		return result, errors.New("Failed to decode genre trends")
+	}
+
+	periods := map[string]map[int]*genreActivity{}
+	totals := map[int]*genreActivity{}
+	activityFor := func(row genreRow) (*genreActivity, *genreActivity) {
+		byGenre, ok := periods[row.ID.Period]
+		if !ok {
+			byGenre = map[int]*genreActivity{}
+			periods[row.ID.Period] = byGenre
+		}
+		p, ok := byGenre[row.ID.GenreID]
+		if !ok {
+			p = &genreActivity{GenreID: row.ID.GenreID, GenreName: row.GenreName}
+			byGenre[row.ID.GenreID] = p
+		}
+		t, ok := totals[row.ID.GenreID]
+		if !ok {
+			t = &genreActivity{GenreID: row.ID.GenreID, GenreName: row.GenreName}
+			totals[row.ID.GenreID] = t
+		}
+		return p, t
+	}
+	for _, row := range watchlistGenres {
+		p, t := activityFor(row)
+		p.WatchlistAdds += row.Count
+		t.WatchlistAdds += row.Count
+	}
+	for _, row := range ratingGenres {
+		p, t := activityFor(row)
+		p.Ratings += row.Count
+		t.Ratings += row.Count
+	}
+
+	// Most active genres first
+	byActivity := func(list []genreActivity) {
+		sort.Slice(list, func(i, j int) bool {
+			ai, aj := list[i].WatchlistAdds+list[i].Ratings, list[j].WatchlistAdds+list[j].Ratings
+			if ai != aj {
+				return ai > aj
+			}
+			return list[i].GenreID < list[j].GenreID
+		})
+	}
+
+	periodKeys := make([]string, 0, len(periods))
+	for k := range periods {
+		periodKeys = append(periodKeys, k)
+	}
+	sort.Strings(periodKeys)
+	for _, k := range periodKeys {
+		point := genreTrendPoint{PeriodStart: k, Genres: []genreActivity{}}
+		for _, g := range periods[k] {
+			point.Genres = append(point.Genres, *g)
+		}
+		byActivity(point.Genres)
+		result.GenreTrends = append(result.GenreTrends, point)
+	}
+	for _, g := range totals {
+		result.Genres = append(result.Genres, *g)
+	}
+	byActivity(result.Genres)
+
+	// Searches that found nothing, most frequent first
+	searchPipeline := []bson.M{
+		{"$match": dayFilter},
+		{"$group": bson.M{
+			"_id":          "$query",
+			"count":        bson.M{"$sum": "$count"},
+			"last_seen_at": bson.M{"$max": "$last_seen_at"},
+		}},
+		{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "last_seen_at", Value: -1}}},
+		{"$limit": limit},
+	}
+	cursor, err = database.OpenCollection("zero_result_searches", client).Aggregate(ctx, searchPipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate zero-result searches")
+	}
+	if err := cursor.All(ctx, &result.ZeroResultSearches); err != nil {
+		return result, errors.New("Failed to decode zero-result searches")
+	}
+
+	return result, nil
+}
+
+// AdminContentAnalytics returns catalog engagement: top movies by watchlist adds, rating volume and
+// average rating, genre popularity per day/week/month and zero-result search queries.
+// Query: from, to, granularity, limit (default 10, max 50), min_ratings (default 3).
+// Admin-only route (protected by RequirePermission middleware).
+func AdminContentAnalytics(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		granularity, ok := normalizeGranularity(c.Query("granularity"))
+		if !ok {
+			apierror.Respond(c, apierror.BadRequest("Invalid granularity"))
+			return
+		}
+
+		var limit int64 = 10
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if parsed, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		if limit > 50 {
+			limit = 50
+		}
+
+		var minRatings int64 = 3
+		if minStr := c.Query("min_ratings"); minStr != "" {
+			if parsed, err := strconv.ParseInt(minStr, 10, 64); err == nil && parsed > 0 {
+				minRatings = parsed
+			}
+		}
+
+		result, err := loadContentAnalytics(ctx, client, granularity, c.Query("from"), c.Query("to"), limit, minRatings)
+		if err != nil {
+			apierror.Respond(c, analyticsError(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,366 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const maxRetentionMonths = 36
+
+type retentionCohort struct {
+	Cohort    string    `json:"cohort"`    // YYYY-MM of the customer's first subscription
+	Customers int64     `json:"customers"` // cohort size
+	Retention []float64 `json:"retention"` // percent of the cohort subscribed in month 0, 1, 2, ...
+}
+
+type mrrMovement struct {
+	Opening     float64 `json:"opening"`
+	New         float64 `json:"new"`
+	Expansion   float64 `json:"expansion"`
+	Contraction float64 `json:"contraction"`
+	Churned     float64 `json:"churned"`
+	Closing     float64 `json:"closing"`
+	NetChange   float64 `json:"net_change"`
+}
+
+type retentionMonth struct {
+	Month            string      `json:"month"`
+	CustomersStart   int64       `json:"customers_start"`
+	CustomersEnd     int64       `json:"customers_end"`
+	Cancellations    int64       `json:"cancellations"`     // cancel requests made this month, plan switches excluded
+	ChurnedCustomers int64       `json:"churned_customers"` // subscribed at month start, not at month end
+	ChurnRate        float64     `json:"churn_rate"`        // percent of customers_start
+	MRR              mrrMovement `json:"mrr"`
+}
+
+type retentionSummary struct {
+	PayingCustomers        int64   `json:"paying_customers"`
+	TotalRevenue           float64 `json:"total_revenue"`
+	AverageLifetimeValue   float64 `json:"average_lifetime_value"` // realized revenue per paying customer, all time
+	ARPU                   float64 `json:"arpu"`                   // closing MRR per current customer
+	AverageChurnRate       float64 `json:"average_churn_rate"`     // mean monthly churn rate over the range
+	EstimatedLifetimeValue float64 `json:"estimated_lifetime_value"`
+}
+
+type retentionAnalytics struct {
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Currency string            `json:"currency"`
+	Cohorts  []retentionCohort `json:"cohorts"`
+	Months   []retentionMonth  `json:"months"`
+	Summary  retentionSummary  `json:"summary"`
+}
+
+// subscriptionSpan is the time a subscription actually provided service, and what it was worth per month
+type subscriptionSpan struct {
+	start time.Time
+	end   time.Time
+	mrr   float64
+}
+
+func monthStartUTC(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// parseMonthParam accepts YYYY-MM as well as the date/datetime formats of the other analytics endpoints
+func parseMonthParam(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse("2006-01", s); err == nil {
+		return t, nil
+	}
+	t, _, err := parseDateOrDateTime(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return monthStartUTC(t), nil
+}
+
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
+
+func spansLiveAt(spans []subscriptionSpan, t time.Time) (bool, float64) {
+	live, mrr := false, 0.0
+	for _, s := range spans {
+		if !s.start.After(t) && s.end.After(t) {
+			live = true
+			mrr += s.mrr
+		}
+	}
+	return live, mrr
+}
+
+func spansOverlap(spans []subscriptionSpan, from, to time.Time) bool {
+	for _, s := range spans {
+		if s.start.Before(to) && s.end.After(from) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRetentionAnalytics builds signup-month cohorts, monthly churn and MRR movements for
+// the months between from and to (inclusive), plus lifetime value from successful payments.
+//
+// A subscription provides service from started_at until expires_at, or until the customer's
+// next subscription starts (a plan switch). Cancellation only stops renewal, so churn is
+// counted when service ends; cancellations are reported separately by canceled_at.
+func loadRetentionAnalytics(ctx context.Context, client *mongo.Client, fromStr, toStr string) (retentionAnalytics, error) {
+	now := time.Now().UTC()
+	result := retentionAnalytics{Currency: "USD", Cohorts: []retentionCohort{}, Months: []retentionMonth{}}
+
+	rangeEnd := monthStartUTC(now)
+	if strings.TrimSpace(toStr) != "" {
+		t, err := parseMonthParam(toStr)
+		if err != nil {
+			return result, errInvalidDateRange
+		}
+		rangeEnd = t
+	}
+	rangeStart := rangeEnd.AddDate(0, -11, 0)
+	if strings.TrimSpace(fromStr) != "" {
+		t, err := parseMonthParam(fromStr)
+		if err != nil {
+			return result, errInvalidDateRange
+		}
+		rangeStart = t
+	}
+	if rangeEnd.After(monthStartUTC(now)) {
+		rangeEnd = monthStartUTC(now)
+	}
+	if rangeStart.After(rangeEnd) {
+		return result, errInvalidDateRange
+	}
+
+	var months []time.Time
+	for m := rangeStart; !m.After(rangeEnd); m = m.AddDate(0, 1, 0) {
+		months = append(months, m)
+	}
+	if len(months) > maxRetentionMonths {
+		return result, errInvalidDateRange
+	}
+	result.From = rangeStart.Format("2006-01")
+	result.To = rangeEnd.Format("2006-01")
+
+	// Month boundaries; the current month is measured up to now
+	monthEnd := func(m time.Time) time.Time {
+		next := m.AddDate(0, 1, 0)
+		if next.After(now) {
+			return now
+		}
+		return next
+	}
+	periodEnd := monthEnd(rangeEnd)
+
+	planCursor, err := database.OpenCollection("plans", client).Find(ctx, bson.D{},
+		options.Find().SetProjection(bson.M{"plan_id": 1, "price_monthly": 1}))
+	if err != nil {
+		return result, errors.New("Failed to fetch plans")
+	}
+	var plans []models.Plan
+	if err := planCursor.All(ctx, &plans); err != nil {
+		return result, errors.New("Failed to decode plans")
+	}
+	prices := make(map[string]float64, len(plans))
+	for _, p := range plans {
+		prices[p.PlanID] = p.PriceMonthly
+	}
+
+	// Full history is needed to find each customer's first subscription (their cohort)
+	subCursor, err := database.OpenCollection("subscriptions", client).Find(ctx,
+		bson.D{{Key: "started_at", Value: bson.D{{Key: "$lt", Value: periodEnd}}}},
+		options.Find().
+			SetSort(bson.D{{Key: "user_id", Value: 1}, {Key: "started_at", Value: 1}}).
+			SetProjection(bson.M{
+				"user_id": 1, "plan_id": 1, "status": 1, "started_at": 1, "expires_at": 1,
+				"canceled_at": 1, "cancel_reason": 1, "updated_at": 1,
+			}))
+	if err != nil {
+		return result, errors.New("Failed to fetch subscriptions")
+	}
+	var subscriptions []models.Subscription
+	if err := subCursor.All(ctx, &subscriptions); err != nil {
+		return result, errors.New("Failed to decode subscriptions")
+	}
+
+	spansByUser := map[string][]subscriptionSpan{}
+	cancellationsByMonth := map[string]int64{}
+	for i, sub := range subscriptions {
+		end := sub.ExpiresAt
+		if end.IsZero() {
+			end = sub.StartedAt.AddDate(0, 1, 0)
+		}
+		if i+1 < len(subscriptions) && subscriptions[i+1].UserID == sub.UserID && subscriptions[i+1].StartedAt.Before(end) {
+			end = subscriptions[i+1].StartedAt
+		}
+		if end.After(sub.StartedAt) {
+			spansByUser[sub.UserID] = append(spansByUser[sub.UserID], subscriptionSpan{
+				start: sub.StartedAt,
+				end:   end,
+				mrr:   prices[sub.PlanID],
+			})
+		}
+
+		if sub.Status == "CANCELED" && sub.CancelReason != models.CancelReasonReplaced {
+			canceledAt := sub.UpdatedAt
+			if sub.CanceledAt != nil {
+				canceledAt = *sub.CanceledAt
+			}
+			cancellationsByMonth[monthStartUTC(canceledAt).Format("2006-01")]++
+		}
+	}
+
+	// Cohorts by month of first subscription
+	cohortMembers := map[string][]string{}
+	for userID, spans := range spansByUser {
+		first := monthStartUTC(spans[0].start)
+		if first.Before(rangeStart) || first.After(rangeEnd) {
+			continue
+		}
+		key := first.Format("2006-01")
+		cohortMembers[key] = append(cohortMembers[key], userID)
+	}
+	for _, m := range months {
+		key := m.Format("2006-01")
+		members := cohortMembers[key]
+		if len(members) == 0 {
+			continue
+		}
+
+		cohort := retentionCohort{Cohort: key, Customers: int64(len(members)), Retention: []float64{}}
+		for n := m; !n.After(rangeEnd); n = n.AddDate(0, 1, 0) {
+			retained := 0
+			for _, userID := range members {
+				if spansOverlap(spansByUser[userID], n, monthEnd(n)) {
+					retained++
+				}
+			}
+			cohort.Retention = append(cohort.Retention, roundTo(float64(retained)*100/float64(len(members)), 1))
+		}
+		result.Cohorts = append(result.Cohorts, cohort)
+	}
+
+	// Monthly churn and MRR movements
+	churnRateSum, churnMonths := 0.0, 0
+	for _, m := range months {
+		start, end := m, monthEnd(m)
+		month := retentionMonth{Month: m.Format("2006-01"), Cancellations: cancellationsByMonth[m.Format("2006-01")]}
+
+		for _, spans := range spansByUser {
+			liveStart, mrrStart := spansLiveAt(spans, start)
+			liveEnd, mrrEnd := spansLiveAt(spans, end)
+
+			if liveStart {
+				month.CustomersStart++
+				month.MRR.Opening += mrrStart
+			}
+			if liveEnd {
+				month.CustomersEnd++
+				month.MRR.Closing += mrrEnd
+			}
+
+			switch {
+			case !liveStart && liveEnd:
+				month.MRR.New += mrrEnd
+			case liveStart && !liveEnd:
+				month.ChurnedCustomers++
+				month.MRR.Churned += mrrStart
+			case liveStart && liveEnd && mrrEnd > mrrStart:
+				month.MRR.Expansion += mrrEnd - mrrStart
+			case liveStart && liveEnd && mrrEnd < mrrStart:
+				month.MRR.Contraction += mrrStart - mrrEnd
+			}
+		}
+
+		if month.CustomersStart > 0 {
+			rate := float64(month.ChurnedCustomers) * 100 / float64(month.CustomersStart)
+			month.ChurnRate = roundTo(rate, 2)
+			churnRateSum += rate
+			churnMonths++
+		}
+
+		month.MRR.NetChange = roundTo(month.MRR.Closing-month.MRR.Opening, 2)
+		month.MRR.Opening = roundTo(month.MRR.Opening, 2)
+		month.MRR.New = roundTo(month.MRR.New, 2)
+		month.MRR.Expansion = roundTo(month.MRR.Expansion, 2)
+		month.MRR.Contraction = roundTo(month.MRR.Contraction, 2)
+		month.MRR.Churned = roundTo(month.MRR.Churned, 2)
+		month.MRR.Closing = roundTo(month.MRR.Closing, 2)
+
+		result.Months = append(result.Months, month)
+	}
+
+	// Lifetime value from successful payments
+	type revenueRow struct {
+		Customers int64   `bson:"customers"`
+		Revenue   float64 `bson:"revenue"`
+	}
+	paymentCursor, err := database.OpenCollection("payments", client).Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"status": "SUCCESS"}},
+		{"$group": bson.M{"_id": "$user_id", "revenue": bson.M{"$sum": "$amount"}}},
+		{"$group": bson.M{"_id": nil, "customers": bson.M{"$sum": 1}, "revenue": bson.M{"$sum": "$revenue"}}},
+	})
+	if err != nil {
+		return result, errors.New("Failed to aggregate customer revenue")
+	}
+	var revenueRows []revenueRow
+	if err := paymentCursor.All(ctx, &revenueRows); err != nil {
+		return result, errors.New("Failed to decode customer revenue")
+	}
+	if len(revenueRows) > 0 {
+		result.Summary.PayingCustomers = revenueRows[0].Customers
+		result.Summary.TotalRevenue = roundTo(revenueRows[0].Revenue, 2)
+		if revenueRows[0].Customers > 0 {
+			result.Summary.AverageLifetimeValue = roundTo(revenueRows[0].Revenue/float64(revenueRows[0].Customers), 2)
+		}
+	}
+
+	if len(result.Months) > 0 {
+		last := result.Months[len(result.Months)-1]
+		if last.CustomersEnd > 0 {
+			result.Summary.ARPU = roundTo(last.MRR.Closing/float64(last.CustomersEnd), 2)
+		}
+	}
+	if churnMonths > 0 {
+		avgChurn := churnRateSum / float64(churnMonths)
+		result.Summary.AverageChurnRate = roundTo(avgChurn, 2)
+		// Expected lifetime is 1/churn months, so LTV = ARPU / monthly churn
+		if avgChurn > 0 {
+			result.Summary.EstimatedLifetimeValue = roundTo(result.Summary.ARPU/(avgChurn/100), 2)
+		}
+	}
+
+	return result, nil
+}
+
+// AdminRetentionAnalytics returns cohort retention, churn, MRR movements and customer lifetime value.
+// Query: from/to as YYYY-MM (or a date), default the last 12 months, at most 36 months.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminRetentionAnalytics(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		result, err := loadRetentionAnalytics(ctx, client, c.Query("from"), c.Query("to"))
+		if err != nil {
+			apierror.Respond(c, analyticsError(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
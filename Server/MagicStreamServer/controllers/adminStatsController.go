@@ -2,103 +2,88 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
-// GetAdminStats returns high-level admin dashboard statistics.
-// Route should be protected by Auth middleware + RequireAdmin middleware.
-func GetAdminStats(client *mongo.Client) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(c, 30*time.Second)
-		defer cancel()
-
-		now := time.Now()
-
-		movieCollection := database.OpenCollection("movies", client)
-		userCollection := database.OpenCollection("users", client)
-		subscriptionCollection := database.OpenCollection("subscriptions", client)
-		ratingCollection := database.OpenCollection("ratings", client)
-		watchlistCollection := database.OpenCollection("watchlists", client)
-		paymentCollection := database.OpenCollection("payments", client)
-
-		totalMovies, err := movieCollection.CountDocuments(ctx, bson.D{})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count movies"})
-			return
-		}
+// adminStatsRevenue is the all-time revenue figure on the dashboard
+type adminStatsRevenue struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
 
-		totalUsers, err := userCollection.CountDocuments(ctx, bson.D{})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count users"})
-			return
-		}
+// adminStatsSnapshot is the high-level dashboard summary
+type adminStatsSnapshot struct {
+	TotalMovies         int64             `json:"total_movies"`
+	TotalUsers          int64             `json:"total_users"`
+	ActiveSubscriptions int64             `json:"active_subscriptions"`
+	TotalRatings        int64             `json:"total_ratings"`
+	TotalWatchlistItems int64             `json:"total_watchlist_items"`
+	Revenue             adminStatsRevenue `json:"revenue"`
+	GeneratedAt         string            `json:"generated_at"`
+	Source              string            `json:"source"` // "rollup" or "live"
+}
 
-		activeSubscriptionFilter := bson.D{
-			{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
-			{Key: "status", Value: bson.D{{Key: "$in", Value: []string{"ACTIVE", "CANCELED"}}}},
-		}
-		activeSubscriptions, err := subscriptionCollection.CountDocuments(ctx, activeSubscriptionFilter)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count active subscriptions"})
-			return
-		}
+func adminStatsFromTotals(totals models.AnalyticsTotals, source string) adminStatsSnapshot {
+	return adminStatsSnapshot{
+		TotalMovies:         totals.Movies,
+		TotalUsers:          totals.Users,
+		ActiveSubscriptions: totals.ActiveSubscriptions,
+		TotalRatings:        totals.Ratings,
+		TotalWatchlistItems: totals.WatchlistItems,
+		Revenue:             adminStatsRevenue{Amount: totals.Revenue, Currency: "USD"},
+		GeneratedAt:         totals.ComputedAt.UTC().Format(time.RFC3339),
+		Source:              source,
+	}
+}
 
-		totalRatings, err := ratingCollection.CountDocuments(ctx, bson.D{})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count ratings"})
-			return
+// loadAdminStats returns the dashboard summary from the latest rollup snapshot, or counts it
+// live when fresh is set or no snapshot exists yet. Errors carry a client-safe message.
+func loadAdminStats(ctx context.Context, client *mongo.Client, fresh bool) (adminStatsSnapshot, error) {
+	if !fresh {
+		var latest models.AnalyticsDaily
+		err := database.OpenCollection("analytics_daily", client).FindOne(ctx,
+			bson.D{{Key: "totals", Value: bson.D{{Key: "$exists", Value: true}}}},
+			options.FindOne().SetSort(bson.D{{Key: "day", Value: -1}})).Decode(&latest)
+		if err == nil && latest.Totals != nil {
+			return adminStatsFromTotals(*latest.Totals, "rollup"), nil
 		}
-
-		totalWatchlistItems, err := watchlistCollection.CountDocuments(ctx, bson.D{})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count watchlist items"})
-			return
+		if err != nil && err != mongo.ErrNoDocuments {
+			return adminStatsSnapshot{}, errors.New("Failed to load dashboard statistics")
 		}
+	}
 
-		// Revenue (all-time): sum of successful payments
-		type revenueAggResult struct {
-			Amount float64 `bson:"amount"`
-		}
+	totals, err := workers.ComputeAnalyticsTotals(ctx, client)
+	if err != nil {
+		return adminStatsSnapshot{}, errors.New("Failed to compute dashboard statistics")
+	}
+	return adminStatsFromTotals(totals, "live"), nil
+}
 
-		revenueAmount := 0.0
-		pipeline := []bson.M{
-			{"$match": bson.M{"status": "SUCCESS"}},
-			{"$group": bson.M{"_id": nil, "amount": bson.M{"$sum": "$amount"}}},
-		}
+// GetAdminStats returns high-level admin dashboard statistics.
+// Numbers come from the periodic rollup snapshot; pass fresh=true to count them live.
+// Route should be protected by Auth middleware + RequirePermission middleware.
+func GetAdminStats(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
 
-		cursor, err := paymentCollection.Aggregate(ctx, pipeline)
+		stats, err := loadAdminStats(ctx, client, c.Query("fresh") == "true")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate revenue"})
+			apierror.Respond(c, apierror.Internal(err.Error()))
 			return
 		}
-		defer cursor.Close(ctx)
-
-		if cursor.Next(ctx) {
-			var res revenueAggResult
-			if err := cursor.Decode(&res); err == nil {
-				revenueAmount = res.Amount
-			}
-		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"total_movies":          totalMovies,
-			"total_users":           totalUsers,
-			"active_subscriptions":  activeSubscriptions,
-			"total_ratings":         totalRatings,
-			"total_watchlist_items": totalWatchlistItems,
-			"revenue": gin.H{
-				"amount":   revenueAmount,
-				"currency": "USD",
-			},
-			"generated_at": time.Now().UTC().Format(time.RFC3339),
-		})
+		c.JSON(http.StatusOK, stats)
 	}
 }
-
-
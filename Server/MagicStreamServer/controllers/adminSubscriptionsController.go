@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"regexp"
 	"sort"
@@ -10,9 +11,12 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 func normalizeSubscriptionListStatusFilter(s string) (string, bool) {
@@ -135,6 +139,11 @@ func periodKeyExpr(dateField string, granularity string) bson.M {
 	}
 }
 
+type adminSubscriptionList struct {
+	Items []bson.M `json:"items"`
+	pageInfo
+}
+
 // AdminListSubscriptions lists subscriptions with joined user + plan information and effective status (EXPIRED based on expires_at).
 func AdminListSubscriptions(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -151,7 +160,7 @@ func AdminListSubscriptions(client *mongo.Client) gin.HandlerFunc {
 
 		statusFilter, ok := normalizeSubscriptionListStatusFilter(statusParam)
 		if !ok {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+			apierror.Respond(c, apierror.BadRequest("Invalid status filter"))
 			return
 		}
 
@@ -185,7 +194,7 @@ func AdminListSubscriptions(client *mongo.Client) gin.HandlerFunc {
 
 		createdAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range"})
+			apierror.Respond(c, apierror.BadRequest("Invalid date range"))
 			return
 		}
 		if len(createdAtFilter) > 0 {
@@ -291,14 +300,14 @@ func AdminListSubscriptions(client *mongo.Client) gin.HandlerFunc {
 
 		cursor, err := subscriptionCollection.Aggregate(ctx, pipeline)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscriptions"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch subscriptions"))
 			return
 		}
 		defer cursor.Close(ctx)
 
 		var results []facetResult
 		if err := cursor.All(ctx, &results); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode subscriptions"})
+			apierror.Respond(c, apierror.Internal("Failed to decode subscriptions"))
 			return
 		}
 
@@ -327,13 +336,7 @@ func AdminListSubscriptions(client *mongo.Client) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"items":      items,
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": totalPages,
-		})
+		c.JSON(http.StatusOK, adminSubscriptionList{Items: items, pageInfo: pageInfo{Page: page, Limit: limit, Total: total, TotalPages: totalPages}})
 	}
 }
 
@@ -346,7 +349,7 @@ func AdminCancelSubscription(client *mongo.Client) gin.HandlerFunc {
 		idStr := strings.TrimSpace(c.Param("id"))
 		oid, err := bson.ObjectIDFromHex(idStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription id"})
+			apierror.Respond(c, apierror.BadRequest("Invalid subscription id"))
 			return
 		}
 
@@ -354,23 +357,42 @@ func AdminCancelSubscription(client *mongo.Client) gin.HandlerFunc {
 		now := time.Now()
 
 		filter := bson.D{{Key: "_id", Value: oid}}
+
+		var before bson.M
+		if err := subscriptionCollection.FindOne(ctx, filter).Decode(&before); err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Subscription not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch subscription"))
+			return
+		}
+
 		update := bson.M{"$set": bson.M{
-			"status":     "CANCELED",
-			"auto_renew": false,
-			"updated_at": now,
+			"status":        "CANCELED",
+			"auto_renew":    false,
+			"canceled_at":   now,
+			"cancel_reason": models.CancelReasonAdmin,
+			"updated_at":    now,
 		}}
 
-		result, err := subscriptionCollection.UpdateOne(ctx, filter, update)
+		var after bson.M
+		err = subscriptionCollection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&after)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel subscription"})
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Subscription not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to cancel subscription"))
 			return
 		}
-		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+
+		if !recordAudit(ctx, client, c, "subscription.cancel", "subscription", idStr, before, after) {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Subscription cancelled"})
+		c.JSON(http.StatusOK, messageResponse{Message: "Subscription cancelled"})
 	}
 }
 
@@ -384,7 +406,7 @@ func AdminActivateSubscription(client *mongo.Client) gin.HandlerFunc {
 		idStr := strings.TrimSpace(c.Param("id"))
 		oid, err := bson.ObjectIDFromHex(idStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription id"})
+			apierror.Respond(c, apierror.BadRequest("Invalid subscription id"))
 			return
 		}
 
@@ -394,16 +416,16 @@ func AdminActivateSubscription(client *mongo.Client) gin.HandlerFunc {
 		var sub bson.M
 		if err := subscriptionCollection.FindOne(ctx, bson.D{{Key: "_id", Value: oid}}).Decode(&sub); err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+				apierror.Respond(c, apierror.NotFound("Subscription not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscription"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch subscription"))
 			return
 		}
 
 		userID, _ := sub["user_id"].(string)
 		if userID == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Subscription has no user_id"})
+			apierror.Respond(c, apierror.Internal("Subscription has no user_id"))
 			return
 		}
 
@@ -416,9 +438,11 @@ func AdminActivateSubscription(client *mongo.Client) gin.HandlerFunc {
 			"_id":     bson.M{"$ne": oid},
 		}
 		otherUpdate := bson.M{"$set": bson.M{
-			"status":     "CANCELED",
-			"auto_renew": false,
-			"updated_at": now,
+			"status":        "CANCELED",
+			"auto_renew":    false,
+			"canceled_at":   now,
+			"cancel_reason": models.CancelReasonReplaced,
+			"updated_at":    now,
 		}}
 		_, _ = subscriptionCollection.UpdateMany(ctx, otherFilter, otherUpdate)
 
@@ -445,22 +469,34 @@ func AdminActivateSubscription(client *mongo.Client) gin.HandlerFunc {
 		}
 
 		filter := bson.D{{Key: "_id", Value: oid}}
-		update := bson.M{"$set": setFields}
+		// Reactivation undoes the cancellation, so it no longer counts as churn
+		update := bson.M{"$set": setFields, "$unset": bson.M{"canceled_at": "", "cancel_reason": ""}}
 
-		result, err := subscriptionCollection.UpdateOne(ctx, filter, update)
+		var after bson.M
+		err = subscriptionCollection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&after)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate subscription"})
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Subscription not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to activate subscription"))
 			return
 		}
-		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+
+		if !recordAudit(ctx, client, c, "subscription.activate", "subscription", idStr, sub, after) {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Subscription activated"})
+		c.JSON(http.StatusOK, messageResponse{Message: "Subscription activated"})
 	}
 }
 
+type adminPaymentList struct {
+	Items []bson.M `json:"items"`
+	pageInfo
+}
+
 // AdminListPayments lists payments with joined user + plan information.
 func AdminListPayments(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -477,7 +513,7 @@ func AdminListPayments(client *mongo.Client) gin.HandlerFunc {
 
 		statusFilter, ok := normalizePaymentStatusFilter(statusParam)
 		if !ok {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+			apierror.Respond(c, apierror.BadRequest("Invalid status filter"))
 			return
 		}
 
@@ -511,7 +547,7 @@ func AdminListPayments(client *mongo.Client) gin.HandlerFunc {
 		}
 		createdAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range"})
+			apierror.Respond(c, apierror.BadRequest("Invalid date range"))
 			return
 		}
 		if len(createdAtFilter) > 0 {
@@ -591,14 +627,14 @@ func AdminListPayments(client *mongo.Client) gin.HandlerFunc {
 
 		cursor, err := paymentCollection.Aggregate(ctx, pipeline)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch payments"))
 			return
 		}
 		defer cursor.Close(ctx)
 
 		var results []facetResult
 		if err := cursor.All(ctx, &results); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode payments"})
+			apierror.Respond(c, apierror.Internal("Failed to decode payments"))
 			return
 		}
 
@@ -626,234 +662,381 @@ func AdminListPayments(client *mongo.Client) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"items":      items,
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": totalPages,
+		c.JSON(http.StatusOK, adminPaymentList{Items: items, pageInfo: pageInfo{Page: page, Limit: limit, Total: total, TotalPages: totalPages}})
+	}
+}
+
+// errInvalidDateRange is returned by analytics loaders when from/to cannot be parsed
+var errInvalidDateRange = errors.New("Invalid date range")
+
+// analyticsError maps an analytics loader error to an API error; loader error messages are client-safe
+func analyticsError(err error) *apierror.Error {
+	if errors.Is(err, errInvalidDateRange) {
+		return apierror.BadRequest(err.Error())
+	}
+	return apierror.Internal(err.Error())
+}
+
+type revenuePoint struct {
+	PeriodStart string  `json:"period_start"`
+	Amount      float64 `json:"amount"`
+}
+
+type revenueAnalytics struct {
+	Currency string         `json:"currency"`
+	Series   []revenuePoint `json:"series"`
+	Total    float64        `json:"total"`
+	Source   string         `json:"source"` // "rollup" or "live"
+}
+
+// loadRevenueAnalytics sums SUCCESS payments per period. from/to accept dates or RFC3339 timestamps.
+func loadRevenueAnalytics(ctx context.Context, client *mongo.Client, granularity, fromStr, toStr string) (revenueAnalytics, error) {
+	result := revenueAnalytics{Currency: "USD", Series: []revenuePoint{}, Source: "live"}
+
+	paymentCollection := database.OpenCollection("payments", client)
+
+	and := []bson.M{{"status": "SUCCESS"}}
+
+	createdAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
+	if err != nil {
+		return result, errInvalidDateRange
+	}
+	if len(createdAtFilter) > 0 {
+		and = append(and, createdAtFilter)
+	}
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"$and": and}},
+		{"$group": bson.M{
+			"_id":    periodKeyExpr("$created_at", granularity),
+			"amount": bson.M{"$sum": "$amount"},
+		}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+
+	type aggRow struct {
+		Period string  `bson:"_id"`
+		Amount float64 `bson:"amount"`
+	}
+
+	cursor, err := paymentCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate revenue")
+	}
+	defer cursor.Close(ctx)
+
+	var rows []aggRow
+	if err := cursor.All(ctx, &rows); err != nil {
+		return result, errors.New("Failed to decode revenue analytics")
+	}
+
+	for _, r := range rows {
+		result.Series = append(result.Series, revenuePoint{
+			PeriodStart: r.Period,
+			Amount:      r.Amount,
 		})
+		result.Total += r.Amount
 	}
+
+	return result, nil
 }
 
 // AdminRevenueAnalytics returns revenue grouped by day/week/month for SUCCESS payments.
+// Reads the daily rollups unless fresh=true or from/to carry a time of day.
 func AdminRevenueAnalytics(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		granularityParam := c.Query("granularity")
-		fromStr := c.Query("from")
-		toStr := c.Query("to")
-
-		granularity, ok := normalizeGranularity(granularityParam)
+		granularity, ok := normalizeGranularity(c.Query("granularity"))
 		if !ok {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid granularity"})
+			apierror.Respond(c, apierror.BadRequest("Invalid granularity"))
 			return
 		}
 
-		paymentCollection := database.OpenCollection("payments", client)
-
-		and := []bson.M{{"status": "SUCCESS"}}
-
-		createdAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
+		var result revenueAnalytics
+		var err error
+		if useAnalyticsRollups(c) {
+			result, err = rollupRevenueAnalytics(ctx, client, granularity, c.Query("from"), c.Query("to"))
+		} else {
+			result, err = loadRevenueAnalytics(ctx, client, granularity, c.Query("from"), c.Query("to"))
+		}
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range"})
+			apierror.Respond(c, analyticsError(err))
 			return
 		}
-		if len(createdAtFilter) > 0 {
-			and = append(and, createdAtFilter)
-		}
 
-		pipeline := []bson.M{
-			{"$match": bson.M{"$and": and}},
-			{"$group": bson.M{
-				"_id":   periodKeyExpr("$created_at", granularity),
-				"amount": bson.M{"$sum": "$amount"},
-			}},
-			{"$sort": bson.M{"_id": 1}},
-		}
+		c.JSON(http.StatusOK, result)
+	}
+}
 
-		type aggRow struct {
-			Period string  `bson:"_id"`
-			Amount float64 `bson:"amount"`
-		}
+type subscriptionTrendPoint struct {
+	PeriodStart           string `json:"period_start"`
+	NewSubscriptions      int64  `json:"new_subscriptions"`
+	CanceledSubscriptions int64  `json:"canceled_subscriptions"`
+}
 
-		cursor, err := paymentCollection.Aggregate(ctx, pipeline)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate revenue"})
-			return
-		}
-		defer cursor.Close(ctx)
+type subscriptionTrends struct {
+	Series []subscriptionTrendPoint `json:"series"`
+	Source string                   `json:"source"`
+}
 
-		var rows []aggRow
-		if err := cursor.All(ctx, &rows); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode revenue analytics"})
-			return
-		}
+// loadSubscriptionTrends counts new and canceled subscriptions per period
+func loadSubscriptionTrends(ctx context.Context, client *mongo.Client, granularity, fromStr, toStr string) (subscriptionTrends, error) {
+	result := subscriptionTrends{Series: []subscriptionTrendPoint{}, Source: "live"}
+
+	subscriptionCollection := database.OpenCollection("subscriptions", client)
+
+	createdAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
+	if err != nil {
+		return result, errInvalidDateRange
+	}
+	canceledAtFilter, err := buildTimeRangeFilter("canceled_time", fromStr, toStr)
+	if err != nil {
+		return result, errInvalidDateRange
+	}
+
+	type aggRow struct {
+		Period string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
 
-		series := make([]gin.H, 0, len(rows))
-		total := 0.0
-		for _, r := range rows {
-			series = append(series, gin.H{
-				"period_start": r.Period,
-				"amount":       r.Amount,
-			})
-			total += r.Amount
+	// New subscriptions by created_at
+	newPipeline := []bson.M{}
+	if len(createdAtFilter) > 0 {
+		newPipeline = append(newPipeline, bson.M{"$match": createdAtFilter})
+	}
+	newPipeline = append(newPipeline,
+		bson.M{"$group": bson.M{
+			"_id":   periodKeyExpr("$created_at", granularity),
+			"count": bson.M{"$sum": 1},
+		}},
+	)
+
+	newCursor, err := subscriptionCollection.Aggregate(ctx, newPipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate subscription trends")
+	}
+	var newRows []aggRow
+	if err := newCursor.All(ctx, &newRows); err != nil {
+		newCursor.Close(ctx)
+		return result, errors.New("Failed to decode subscription trends")
+	}
+	newCursor.Close(ctx)
+
+	// Canceled subscriptions by canceled_at; documents canceled before it was recorded fall back to updated_at
+	cancelPipeline := []bson.M{
+		{"$match": bson.M{"status": "CANCELED"}},
+		{"$addFields": bson.M{"canceled_time": bson.M{"$ifNull": []interface{}{"$canceled_at", "$updated_at"}}}},
+	}
+	if len(canceledAtFilter) > 0 {
+		cancelPipeline = append(cancelPipeline, bson.M{"$match": canceledAtFilter})
+	}
+	cancelPipeline = append(cancelPipeline,
+		bson.M{"$group": bson.M{
+			"_id":   periodKeyExpr("$canceled_time", granularity),
+			"count": bson.M{"$sum": 1},
+		}},
+	)
+
+	cancelCursor, err := subscriptionCollection.Aggregate(ctx, cancelPipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate subscription trends")
+	}
+	var cancelRows []aggRow
+	if err := cancelCursor.All(ctx, &cancelRows); err != nil {
+		cancelCursor.Close(ctx)
+		return result, errors.New("Failed to decode subscription trends")
+	}
+	cancelCursor.Close(ctx)
+
+	// Merge rows into a single series
+	merged := map[string]*subscriptionTrendPoint{}
+
+	for _, r := range newRows {
+		p, exists := merged[r.Period]
+		if !exists {
+			p = &subscriptionTrendPoint{PeriodStart: r.Period}
+			merged[r.Period] = p
 		}
+		p.NewSubscriptions = r.Count
+	}
+	for _, r := range cancelRows {
+		p, exists := merged[r.Period]
+		if !exists {
+			p = &subscriptionTrendPoint{PeriodStart: r.Period}
+			merged[r.Period] = p
+		}
+		p.CanceledSubscriptions = r.Count
+	}
 
-		c.JSON(http.StatusOK, gin.H{
-			"currency": "USD",
-			"series":   series,
-			"total":    total,
-		})
+	// Sort keys (period strings)
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		result.Series = append(result.Series, *merged[k])
+	}
+
+	return result, nil
 }
 
 // AdminSubscriptionTrendsAnalytics returns new/canceled subscription counts grouped by day/week/month.
+// Reads the daily rollups unless fresh=true or from/to carry a time of day.
 func AdminSubscriptionTrendsAnalytics(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		granularityParam := c.Query("granularity")
-		fromStr := c.Query("from")
-		toStr := c.Query("to")
-
-		granularity, ok := normalizeGranularity(granularityParam)
+		granularity, ok := normalizeGranularity(c.Query("granularity"))
 		if !ok {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid granularity"})
+			apierror.Respond(c, apierror.BadRequest("Invalid granularity"))
 			return
 		}
 
-		subscriptionCollection := database.OpenCollection("subscriptions", client)
-
-		createdAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range"})
-			return
+		var result subscriptionTrends
+		var err error
+		if useAnalyticsRollups(c) {
+			result, err = rollupSubscriptionTrends(ctx, client, granularity, c.Query("from"), c.Query("to"))
+		} else {
+			result, err = loadSubscriptionTrends(ctx, client, granularity, c.Query("from"), c.Query("to"))
 		}
-		updatedAtFilter, err := buildTimeRangeFilter("updated_at", fromStr, toStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range"})
+			apierror.Respond(c, analyticsError(err))
 			return
 		}
 
-		type aggRow struct {
-			Period string `bson:"_id"`
-			Count  int64  `bson:"count"`
-		}
+		c.JSON(http.StatusOK, result)
+	}
+}
 
-		// New subscriptions by created_at
-		newPipeline := []bson.M{}
-		if len(createdAtFilter) > 0 {
-			newPipeline = append(newPipeline, bson.M{"$match": createdAtFilter})
-		}
-		newPipeline = append(newPipeline,
-			bson.M{"$group": bson.M{
-				"_id":   periodKeyExpr("$created_at", granularity),
-				"count": bson.M{"$sum": 1},
-			}},
-		)
+type popularPlanItem struct {
+	PlanID        string  `json:"plan_id"`
+	PlanName      string  `json:"plan_name"`
+	Subscriptions int64   `json:"subscriptions"`
+	Revenue       float64 `json:"revenue"`
+}
 
-		newCursor, err := subscriptionCollection.Aggregate(ctx, newPipeline)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate subscription trends"})
-			return
-		}
-		var newRows []aggRow
-		if err := newCursor.All(ctx, &newRows); err != nil {
-			newCursor.Close(ctx)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode subscription trends"})
-			return
-		}
-		newCursor.Close(ctx)
+type popularPlans struct {
+	Items  []popularPlanItem `json:"items"`
+	Source string            `json:"source"`
+}
 
-		// Canceled subscriptions by updated_at where status == CANCELED
-		cancelPipeline := []bson.M{
-			{"$match": bson.M{"status": "CANCELED"}},
-		}
-		if len(updatedAtFilter) > 0 {
-			// updatedAtFilter is {"updated_at": {...}}
-			cancelPipeline = append(cancelPipeline, bson.M{"$match": updatedAtFilter})
-		}
-		cancelPipeline = append(cancelPipeline,
-			bson.M{"$group": bson.M{
-				"_id":   periodKeyExpr("$updated_at", granularity),
-				"count": bson.M{"$sum": 1},
-			}},
-		)
+// loadPopularPlans ranks plans by subscriptions created in range, with revenue per plan
+func loadPopularPlans(ctx context.Context, client *mongo.Client, fromStr, toStr string, limit int64) (popularPlans, error) {
+	result := popularPlans{Items: []popularPlanItem{}, Source: "live"}
 
-		cancelCursor, err := subscriptionCollection.Aggregate(ctx, cancelPipeline)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate subscription trends"})
-			return
-		}
-		var cancelRows []aggRow
-		if err := cancelCursor.All(ctx, &cancelRows); err != nil {
-			cancelCursor.Close(ctx)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode subscription trends"})
-			return
-		}
-		cancelCursor.Close(ctx)
+	subscriptionCollection := database.OpenCollection("subscriptions", client)
+	paymentCollection := database.OpenCollection("payments", client)
 
-		// Merge rows into a single series
-		type trendPoint struct {
-			New      int64
-			Canceled int64
-		}
-		merged := map[string]*trendPoint{}
+	createdAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
+	if err != nil {
+		return result, errInvalidDateRange
+	}
 
-		for _, r := range newRows {
-			p, exists := merged[r.Period]
-			if !exists {
-				p = &trendPoint{}
-				merged[r.Period] = p
-			}
-			p.New = r.Count
-		}
-		for _, r := range cancelRows {
-			p, exists := merged[r.Period]
-			if !exists {
-				p = &trendPoint{}
-				merged[r.Period] = p
-			}
-			p.Canceled = r.Count
-		}
+	// Subscriptions per plan (created in range)
+	subPipeline := []bson.M{}
+	if len(createdAtFilter) > 0 {
+		subPipeline = append(subPipeline, bson.M{"$match": createdAtFilter})
+	}
+	subPipeline = append(subPipeline,
+		bson.M{"$group": bson.M{
+			"_id":           "$plan_id",
+			"subscriptions": bson.M{"$sum": 1},
+		}},
+		bson.M{"$sort": bson.M{"subscriptions": -1}},
+		bson.M{"$limit": limit},
+		bson.M{"$lookup": bson.M{
+			"from":         "plans",
+			"localField":   "_id",
+			"foreignField": "plan_id",
+			"as":           "plan",
+		}},
+		bson.M{"$unwind": bson.M{"path": "$plan", "preserveNullAndEmptyArrays": true}},
+		bson.M{"$project": bson.M{
+			"_id":           0,
+			"plan_id":       "$_id",
+			"plan_name":     "$plan.name",
+			"subscriptions": 1,
+		}},
+	)
+
+	type planCountRow struct {
+		PlanID        string `bson:"plan_id"`
+		PlanName      string `bson:"plan_name"`
+		Subscriptions int64  `bson:"subscriptions"`
+	}
 
-		// Sort keys (period strings)
-		keys := make([]string, 0, len(merged))
-		for k := range merged {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
+	subCursor, err := subscriptionCollection.Aggregate(ctx, subPipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate popular plans")
+	}
+	defer subCursor.Close(ctx)
 
-		series := make([]gin.H, 0, len(keys))
-		for _, k := range keys {
-			p := merged[k]
-			series = append(series, gin.H{
-				"period_start":          k,
-				"new_subscriptions":     p.New,
-				"canceled_subscriptions": p.Canceled,
-			})
-		}
+	var planCounts []planCountRow
+	if err := subCursor.All(ctx, &planCounts); err != nil {
+		return result, errors.New("Failed to decode popular plans")
+	}
+
+	// Revenue per plan from successful payments in range
+	paymentAnd := []bson.M{{"status": "SUCCESS"}}
+	if len(createdAtFilter) > 0 {
+		paymentAnd = append(paymentAnd, createdAtFilter)
+	}
+
+	revenuePipeline := []bson.M{
+		{"$match": bson.M{"$and": paymentAnd}},
+		{"$group": bson.M{
+			"_id":     "$plan_id",
+			"revenue": bson.M{"$sum": "$amount"},
+		}},
+	}
+
+	type revenueRow struct {
+		PlanID  string  `bson:"_id"`
+		Revenue float64 `bson:"revenue"`
+	}
+
+	revCursor, err := paymentCollection.Aggregate(ctx, revenuePipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate plan revenue")
+	}
+	defer revCursor.Close(ctx)
+
+	var revenues []revenueRow
+	if err := revCursor.All(ctx, &revenues); err != nil {
+		return result, errors.New("Failed to decode plan revenue")
+	}
+
+	revenueMap := map[string]float64{}
+	for _, r := range revenues {
+		revenueMap[r.PlanID] = r.Revenue
+	}
 
-		c.JSON(http.StatusOK, gin.H{"series": series})
+	for _, p := range planCounts {
+		result.Items = append(result.Items, popularPlanItem{
+			PlanID:        p.PlanID,
+			PlanName:      p.PlanName,
+			Subscriptions: p.Subscriptions,
+			Revenue:       revenueMap[p.PlanID],
+		})
 	}
+
+	return result, nil
 }
 
 // AdminPopularPlansAnalytics returns most popular plans by subscription count in range, with revenue per plan.
+// Reads the daily rollups unless fresh=true or from/to carry a time of day.
 func AdminPopularPlansAnalytics(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		fromStr := c.Query("from")
-		toStr := c.Query("to")
-		limitStr := c.Query("limit")
-
 		var limit int64 = 5
-		if limitStr != "" {
+		if limitStr := c.Query("limit"); limitStr != "" {
 			if parsed, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsed > 0 {
 				limit = parsed
 			}
@@ -862,115 +1045,18 @@ func AdminPopularPlansAnalytics(client *mongo.Client) gin.HandlerFunc {
 			limit = 50
 		}
 
-		subscriptionCollection := database.OpenCollection("subscriptions", client)
-		paymentCollection := database.OpenCollection("payments", client)
-
-		createdAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range"})
-			return
-		}
-
-		// Subscriptions per plan (created in range)
-		subPipeline := []bson.M{}
-		if len(createdAtFilter) > 0 {
-			subPipeline = append(subPipeline, bson.M{"$match": createdAtFilter})
-		}
-		subPipeline = append(subPipeline,
-			bson.M{"$group": bson.M{
-				"_id":           "$plan_id",
-				"subscriptions": bson.M{"$sum": 1},
-			}},
-			bson.M{"$sort": bson.M{"subscriptions": -1}},
-			bson.M{"$limit": limit},
-			bson.M{"$lookup": bson.M{
-				"from":         "plans",
-				"localField":   "_id",
-				"foreignField": "plan_id",
-				"as":           "plan",
-			}},
-			bson.M{"$unwind": bson.M{"path": "$plan", "preserveNullAndEmptyArrays": true}},
-			bson.M{"$project": bson.M{
-				"_id":           0,
-				"plan_id":       "$_id",
-				"plan_name":     "$plan.name",
-				"subscriptions": 1,
-			}},
-		)
-
-		type planCountRow struct {
-			PlanID       string `bson:"plan_id"`
-			PlanName     string `bson:"plan_name"`
-			Subscriptions int64 `bson:"subscriptions"`
-		}
-
-		subCursor, err := subscriptionCollection.Aggregate(ctx, subPipeline)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate popular plans"})
-			return
-		}
-		defer subCursor.Close(ctx)
-
-		var planCounts []planCountRow
-		if err := subCursor.All(ctx, &planCounts); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode popular plans"})
-			return
-		}
-
-		// Revenue per plan from successful payments in range
-		paymentAnd := []bson.M{{"status": "SUCCESS"}}
-		paymentCreatedAtFilter, err := buildTimeRangeFilter("created_at", fromStr, toStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range"})
-			return
-		}
-		if len(paymentCreatedAtFilter) > 0 {
-			paymentAnd = append(paymentAnd, paymentCreatedAtFilter)
-		}
-
-		revenuePipeline := []bson.M{
-			{"$match": bson.M{"$and": paymentAnd}},
-			{"$group": bson.M{
-				"_id":     "$plan_id",
-				"revenue": bson.M{"$sum": "$amount"},
-			}},
-		}
-
-		type revenueRow struct {
-			PlanID  string  `bson:"_id"`
-			Revenue float64 `bson:"revenue"`
+		var result popularPlans
+		var err error
+		if useAnalyticsRollups(c) {
+			result, err = rollupPopularPlans(ctx, client, c.Query("from"), c.Query("to"), limit)
+		} else {
+			result, err = loadPopularPlans(ctx, client, c.Query("from"), c.Query("to"), limit)
 		}
-
-		revCursor, err := paymentCollection.Aggregate(ctx, revenuePipeline)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate plan revenue"})
-			return
-		}
-		defer revCursor.Close(ctx)
-
-		var revenues []revenueRow
-		if err := revCursor.All(ctx, &revenues); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode plan revenue"})
+			apierror.Respond(c, analyticsError(err))
 			return
 		}
 
-		revenueMap := map[string]float64{}
-		for _, r := range revenues {
-			revenueMap[r.PlanID] = r.Revenue
-		}
-
-		items := make([]gin.H, 0, len(planCounts))
-		for _, p := range planCounts {
-			items = append(items, gin.H{
-				"plan_id":       p.PlanID,
-				"plan_name":     p.PlanName,
-				"subscriptions": p.Subscriptions,
-				"revenue":       revenueMap[p.PlanID],
-			})
-		}
-
-		c.JSON(http.StatusOK, gin.H{"items": items})
+		c.JSON(http.StatusOK, result)
 	}
 }
-
-
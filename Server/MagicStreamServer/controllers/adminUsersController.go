@@ -9,24 +9,27 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+// roleNamePattern matches role names such as ADMIN or CONTENT_EDITOR
+var roleNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,49}$`)
+
 func normalizeRole(role string) (string, bool) {
 	if role == "" {
 		return "", true
 	}
 	r := strings.ToUpper(strings.TrimSpace(role))
-	switch r {
-	case "ADMIN", "USER":
-		return r, true
-	default:
+	if !roleNamePattern.MatchString(r) {
 		return "", false
 	}
+	return r, true
 }
 
 func normalizeSubscriptionFilter(s string) (string, bool) {
@@ -60,8 +63,13 @@ func computeSubscriptionStatus(status string, expiresAt time.Time, hasSubscripti
 	}
 }
 
+type adminUserList struct {
+	Items []bson.M `json:"items"`
+	pageInfo
+}
+
 // AdminListUsers returns a paginated list of users with subscription summary and activity counts.
-// Admin-only route (protected by RequireAdmin middleware).
+// Admin-only route (protected by RequirePermission middleware).
 func AdminListUsers(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
@@ -76,13 +84,13 @@ func AdminListUsers(client *mongo.Client) gin.HandlerFunc {
 
 		role, ok := normalizeRole(roleParam)
 		if !ok {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role filter"})
+			apierror.Respond(c, apierror.BadRequest("Invalid role filter"))
 			return
 		}
 
 		subscriptionFilter, ok := normalizeSubscriptionFilter(subParam)
 		if !ok {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription filter"})
+			apierror.Respond(c, apierror.BadRequest("Invalid subscription filter"))
 			return
 		}
 
@@ -275,14 +283,14 @@ func AdminListUsers(client *mongo.Client) gin.HandlerFunc {
 
 		cursor, err := userCollection.Aggregate(ctx, pipeline)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch users"))
 			return
 		}
 		defer cursor.Close(ctx)
 
 		var results []facetResult
 		if err := cursor.All(ctx, &results); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode users"})
+			apierror.Respond(c, apierror.Internal("Failed to decode users"))
 			return
 		}
 
@@ -304,18 +312,32 @@ func AdminListUsers(client *mongo.Client) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"items":      items,
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": totalPages,
-		})
+		c.JSON(http.StatusOK, adminUserList{Items: items, pageInfo: pageInfo{Page: page, Limit: limit, Total: total, TotalPages: totalPages}})
 	}
 }
 
+type adminUserSubscription struct {
+	Status    string    `json:"status"`
+	PlanID    string    `json:"plan_id"`
+	PlanName  string    `json:"plan_name"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CanStream bool      `json:"can_stream"`
+}
+
+type adminUserActivity struct {
+	RatingsCount   int64 `json:"ratings_count"`
+	WatchlistCount int64 `json:"watchlist_count"`
+}
+
+type adminUserDetail struct {
+	// The user document without password and tokens
+	User         bson.M                `json:"user"`
+	Subscription adminUserSubscription `json:"subscription"`
+	Activity     adminUserActivity     `json:"activity"`
+}
+
 // AdminGetUser returns a single user's details for admin management.
-// Admin-only route (protected by RequireAdmin middleware).
+// Admin-only route (protected by RequirePermission middleware).
 func AdminGetUser(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
@@ -323,7 +345,7 @@ func AdminGetUser(client *mongo.Client) gin.HandlerFunc {
 
 		targetUserID := strings.TrimSpace(c.Param("user_id"))
 		if targetUserID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+			apierror.Respond(c, apierror.BadRequest("user_id is required"))
 			return
 		}
 
@@ -346,10 +368,10 @@ func AdminGetUser(client *mongo.Client) gin.HandlerFunc {
 			options.FindOne().SetProjection(userProjection),
 		).Decode(&userDoc); err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				apierror.Respond(c, apierror.NotFound("User not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
 			return
 		}
 
@@ -394,25 +416,34 @@ func AdminGetUser(client *mongo.Client) gin.HandlerFunc {
 		ratingsCount, _ := ratingCollection.CountDocuments(ctx, bson.D{{Key: "user_id", Value: targetUserID}})
 		watchlistCount, _ := watchlistCollection.CountDocuments(ctx, bson.D{{Key: "user_id", Value: targetUserID}})
 
-		c.JSON(http.StatusOK, gin.H{
-			"user": userDoc,
-			"subscription": gin.H{
-				"status":     subStatus,
-				"plan_id":    planID,
-				"plan_name":  planName,
-				"expires_at": expiresAt,
-				"can_stream": canStream,
-			},
-			"activity": gin.H{
-				"ratings_count":   ratingsCount,
-				"watchlist_count": watchlistCount,
+		c.JSON(http.StatusOK, adminUserDetail{
+			User: userDoc,
+			Subscription: adminUserSubscription{
+				Status:    subStatus,
+				PlanID:    planID,
+				PlanName:  planName,
+				ExpiresAt: expiresAt,
+				CanStream: canStream,
 			},
+			Activity: adminUserActivity{RatingsCount: ratingsCount, WatchlistCount: watchlistCount},
 		})
 	}
 }
 
-// AdminUpdateUserRole promotes/demotes a user between USER and ADMIN.
-// Admin-only route (protected by RequireAdmin middleware).
+type adminUpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type adminUpdateUserRoleResponse struct {
+	Message string `json:"message"`
+	// The updated user, left out if it couldn't be read back
+	User bson.M `json:"user,omitempty"`
+}
+
+// AdminUpdateUserRole assigns one of the configured roles to a user. Both the new and the
+// current role must only carry permissions the admin holds. The user's sessions are signed out,
+// since their tokens still carry the old role's permissions.
+// Admin-only route (protected by RequirePermission middleware).
 func AdminUpdateUserRole(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
@@ -420,38 +451,72 @@ func AdminUpdateUserRole(client *mongo.Client) gin.HandlerFunc {
 
 		adminUserID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
 		targetUserID := strings.TrimSpace(c.Param("user_id"))
 		if targetUserID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+			apierror.Respond(c, apierror.BadRequest("user_id is required"))
 			return
 		}
 
 		// Recommended safety: do not allow admins to change their own role
 		if targetUserID == adminUserID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+			apierror.Respond(c, apierror.BadRequest("You cannot change your own role"))
 			return
 		}
 
-		var req struct {
-			Role string `json:"role" binding:"required"`
-		}
+		var req adminUpdateUserRoleRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
 		newRole, ok := normalizeRole(req.Role)
 		if !ok || newRole == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be ADMIN or USER"})
+			apierror.Respond(c, apierror.BadRequest("Invalid role"))
+			return
+		}
+
+		roleCount, err := database.OpenCollection("roles", client).CountDocuments(ctx, bson.D{{Key: "name", Value: newRole}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to verify role"))
+			return
+		}
+		if roleCount == 0 {
+			apierror.Respond(c, apierror.BadRequest("Role does not exist"))
 			return
 		}
 
 		userCollection := database.OpenCollection("users", client)
 		filter := bson.D{{Key: "user_id", Value: targetUserID}}
+
+		var before struct {
+			Role string `bson:"role" json:"role"`
+		}
+		if err := userCollection.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"role": 1})).Decode(&before); err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		// The actor must hold every permission of both the new role and the one it replaces
+		for _, role := range []string{newRole, before.Role} {
+			permissions, err := utils.ResolvePermissions(ctx, client, role)
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to load permissions"))
+				return
+			}
+			if missing := missingPermission(c, permissions); missing != "" {
+				respondMissingPermission(c, missing)
+				return
+			}
+		}
+
 		update := bson.M{
 			"$set": bson.M{
 				"role":      newRole,
@@ -461,11 +526,20 @@ func AdminUpdateUserRole(client *mongo.Client) gin.HandlerFunc {
 
 		result, err := userCollection.UpdateOne(ctx, filter, update)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+			apierror.Respond(c, apierror.Internal("Failed to update user role"))
 			return
 		}
 		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			apierror.Respond(c, apierror.NotFound("User not found"))
+			return
+		}
+
+		if _, err := utils.RevokeSessions(ctx, client, filter); err != nil {
+			apierror.Respond(c, apierror.Internal("Role updated, but the user's sessions could not be signed out"))
+			return
+		}
+
+		if !recordAudit(ctx, client, c, "user.role_update", "user", targetUserID, before, gin.H{"role": newRole}) {
 			return
 		}
 
@@ -477,15 +551,247 @@ func AdminUpdateUserRole(client *mongo.Client) gin.HandlerFunc {
 		}
 		var userDoc bson.M
 		if err := userCollection.FindOne(ctx, filter, options.FindOne().SetProjection(userProjection)).Decode(&userDoc); err != nil {
-			c.JSON(http.StatusOK, gin.H{"message": "User role updated"})
+			c.JSON(http.StatusOK, adminUpdateUserRoleResponse{Message: "User role updated"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message": "User role updated",
-			"user":    userDoc,
-		})
+		c.JSON(http.StatusOK, adminUpdateUserRoleResponse{Message: "User role updated", User: userDoc})
 	}
 }
 
+type adminUpdateUserStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+type adminUpdateUserStatusResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// canManageRole checks that the actor holds every permission of a target user's role, so
+// users:manage alone can't suspend, sign out or impersonate an admin. It answers the request
+// and returns false when they don't.
+func canManageRole(ctx context.Context, client *mongo.Client, c *gin.Context, role string) bool {
+	permissions, err := utils.ResolvePermissions(ctx, client, role)
+	if err != nil {
+		apierror.Respond(c, apierror.Internal("Failed to load permissions"))
+		return false
+	}
+	if missing := missingPermission(c, permissions); missing != "" {
+		apierror.Respond(c, apierror.Forbidden("You can only manage users whose permissions you hold").
+			WithCode(apierror.CodeMissingPermission).
+			WithDetail("permission", missing))
+		return false
+	}
+	return true
+}
+
+// AdminUpdateUserStatus suspends or reactivates a user. Suspending also revokes all of the user's tokens.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminUpdateUserStatus(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		adminUserID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
 
+		targetUserID := strings.TrimSpace(c.Param("user_id"))
+		if targetUserID == "" {
+			apierror.Respond(c, apierror.BadRequest("user_id is required"))
+			return
+		}
+		if targetUserID == adminUserID {
+			apierror.Respond(c, apierror.BadRequest("You cannot change your own status"))
+			return
+		}
+
+		var req adminUpdateUserStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		status := strings.ToUpper(strings.TrimSpace(req.Status))
+		if status != models.UserStatusActive && status != models.UserStatusSuspended {
+			apierror.Respond(c, apierror.BadRequest("Status must be ACTIVE or SUSPENDED"))
+			return
+		}
+		reason := strings.TrimSpace(req.Reason)
+		if len(reason) > 500 {
+			apierror.Respond(c, apierror.BadRequest("Reason must be at most 500 characters"))
+			return
+		}
+
+		userCollection := database.OpenCollection("users", client)
+		filter := bson.D{{Key: "user_id", Value: targetUserID}}
+
+		var before struct {
+			Status           string `bson:"status" json:"status"`
+			SuspensionReason string `bson:"suspension_reason" json:"suspension_reason"`
+			Role             string `bson:"role" json:"-"`
+		}
+		projection := bson.M{"status": 1, "suspension_reason": 1, "role": 1}
+		if err := userCollection.FindOne(ctx, filter, options.FindOne().SetProjection(projection)).Decode(&before); err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+		if !canManageRole(ctx, client, c, before.Role) {
+			return
+		}
+		if before.Status == "" {
+			before.Status = models.UserStatusActive
+		}
+
+		now := time.Now()
+		var update bson.M
+		action := "user.reactivate"
+		if status == models.UserStatusSuspended {
+			action = "user.suspend"
+			update = bson.M{"$set": bson.M{
+				"status":             status,
+				"suspended_at":       now,
+				"suspension_reason":  reason,
+				"tokens_valid_after": utils.RevocationTime(),
+				"token":              "",
+				"refresh_token":      "",
+				"update_at":          now,
+			}}
+		} else {
+			update = bson.M{
+				"$set":   bson.M{"status": status, "update_at": now},
+				"$unset": bson.M{"suspended_at": "", "suspension_reason": ""},
+			}
+		}
+
+		if _, err := userCollection.UpdateOne(ctx, filter, update); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to update user status"))
+			return
+		}
+
+		if !recordAudit(ctx, client, c, action, "user", targetUserID, before, gin.H{
+			"status":            status,
+			"suspension_reason": reason,
+		}) {
+			return
+		}
+
+		c.JSON(http.StatusOK, adminUpdateUserStatusResponse{Message: "User status updated", Status: status})
+	}
+}
+
+// AdminForceLogout revokes every access and refresh token issued to the user.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminForceLogout(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		targetUserID := strings.TrimSpace(c.Param("user_id"))
+		if targetUserID == "" {
+			apierror.Respond(c, apierror.BadRequest("user_id is required"))
+			return
+		}
+
+		var target struct {
+			Role string `bson:"role"`
+		}
+		err := database.OpenCollection("users", client).FindOne(ctx, bson.D{{Key: "user_id", Value: targetUserID}},
+			options.FindOne().SetProjection(bson.M{"role": 1})).Decode(&target)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+		if !canManageRole(ctx, client, c, target.Role) {
+			return
+		}
+
+		if _, err := utils.RevokeAllTokens(ctx, client, targetUserID); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to revoke tokens"))
+			return
+		}
+
+		if !recordAudit(ctx, client, c, "user.force_logout", "user", targetUserID, nil, nil) {
+			return
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "All sessions revoked"})
+	}
+}
+
+type impersonationResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ReadOnly  bool      `json:"read_only"`
+}
+
+// AdminImpersonateUser issues a short-lived, read-only token that lets support see the app as the user.
+// The token is returned in the body (never set as a cookie) and must be sent in the X-Impersonation-Token header.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminImpersonateUser(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		adminUserID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+		if utils.GetImpersonatorIdFromContext(c) != "" {
+			apierror.Respond(c, apierror.Forbidden("Cannot impersonate from an impersonation session"))
+			return
+		}
+
+		targetUserID := strings.TrimSpace(c.Param("user_id"))
+		if targetUserID == "" {
+			apierror.Respond(c, apierror.BadRequest("user_id is required"))
+			return
+		}
+		if targetUserID == adminUserID {
+			apierror.Respond(c, apierror.BadRequest("You cannot impersonate yourself"))
+			return
+		}
+
+		var user models.User
+		if err := database.OpenCollection("users", client).FindOne(ctx, bson.D{{Key: "user_id", Value: targetUserID}}).Decode(&user); err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+		if user.Status == models.UserStatusSuspended {
+			apierror.Respond(c, apierror.Conflict("Cannot impersonate a suspended user"))
+			return
+		}
+		if !canManageRole(ctx, client, c, user.Role) {
+			return
+		}
+
+		token, expiresAt, err := utils.GenerateImpersonationToken(user, adminUserID)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to generate impersonation token"))
+			return
+		}
+
+		if !recordAudit(ctx, client, c, "user.impersonate", "user", targetUserID, nil, gin.H{"expires_at": expiresAt}) {
+			return
+		}
+
+		c.JSON(http.StatusOK, impersonationResponse{Token: token, ExpiresAt: expiresAt, ReadOnly: true})
+	}
+}
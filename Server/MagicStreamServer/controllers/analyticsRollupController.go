@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// useAnalyticsRollups reports whether an analytics request can be answered from analytics_daily.
+// fresh=true forces live aggregation, as do from/to with a time of day since rollups are per UTC day.
+func useAnalyticsRollups(c *gin.Context) bool {
+	if c.Query("fresh") == "true" {
+		return false
+	}
+	return !strings.Contains(c.Query("from"), "T") && !strings.Contains(c.Query("to"), "T")
+}
+
+// rollupRevenueAnalytics is loadRevenueAnalytics computed from the daily rollups
+func rollupRevenueAnalytics(ctx context.Context, client *mongo.Client, granularity, fromStr, toStr string) (revenueAnalytics, error) {
+	result := revenueAnalytics{Currency: "USD", Series: []revenuePoint{}, Source: "rollup"}
+
+	dayFilter, err := buildTimeRangeFilter("day", fromStr, toStr)
+	if err != nil {
+		return result, errInvalidDateRange
+	}
+
+	pipeline := []bson.M{
+		{"$match": dayFilter},
+		{"$group": bson.M{
+			"_id":      periodKeyExpr("$day", granularity),
+			"amount":   bson.M{"$sum": "$revenue"},
+			"payments": bson.M{"$sum": "$payments"},
+		}},
+		// Live aggregation only has periods with payments; match that
+		{"$match": bson.M{"payments": bson.M{"$gt": 0}}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+
+	type aggRow struct {
+		Period string  `bson:"_id"`
+		Amount float64 `bson:"amount"`
+	}
+
+	cursor, err := database.OpenCollection("analytics_daily", client).Aggregate(ctx, pipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate revenue")
+	}
+
+	var rows []aggRow
+	if err := cursor.All(ctx, &rows); err != nil {
+		return result, errors.New("Failed to decode revenue analytics")
+	}
+
+	for _, r := range rows {
+		result.Series = append(result.Series, revenuePoint{PeriodStart: r.Period, Amount: r.Amount})
+		result.Total += r.Amount
+	}
+
+	return result, nil
+}
+
+// rollupSubscriptionTrends is loadSubscriptionTrends computed from the daily rollups
+func rollupSubscriptionTrends(ctx context.Context, client *mongo.Client, granularity, fromStr, toStr string) (subscriptionTrends, error) {
+	result := subscriptionTrends{Series: []subscriptionTrendPoint{}, Source: "rollup"}
+
+	dayFilter, err := buildTimeRangeFilter("day", fromStr, toStr)
+	if err != nil {
+		return result, errInvalidDateRange
+	}
+
+	pipeline := []bson.M{
+		{"$match": dayFilter},
+		{"$group": bson.M{
+			"_id":      periodKeyExpr("$day", granularity),
+			"new":      bson.M{"$sum": "$new_subscriptions"},
+			"canceled": bson.M{"$sum": "$cancellations"},
+		}},
+		{"$match": bson.M{"$or": []bson.M{{"new": bson.M{"$gt": 0}}, {"canceled": bson.M{"$gt": 0}}}}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+
+	type aggRow struct {
+		Period   string `bson:"_id"`
+		New      int64  `bson:"new"`
+		Canceled int64  `bson:"canceled"`
+	}
+
+	cursor, err := database.OpenCollection("analytics_daily", client).Aggregate(ctx, pipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate subscription trends")
+	}
+
+	var rows []aggRow
+	if err := cursor.All(ctx, &rows); err != nil {
+		return result, errors.New("Failed to decode subscription trends")
+	}
+
+	for _, r := range rows {
+		result.Series = append(result.Series, subscriptionTrendPoint{
+			PeriodStart:           r.Period,
+			NewSubscriptions:      r.New,
+			CanceledSubscriptions: r.Canceled,
+		})
+	}
+
+	return result, nil
+}
+
+// rollupPopularPlans is loadPopularPlans computed from the per-plan maps in the daily rollups
+func rollupPopularPlans(ctx context.Context, client *mongo.Client, fromStr, toStr string, limit int64) (popularPlans, error) {
+	result := popularPlans{Items: []popularPlanItem{}, Source: "rollup"}
+
+	dayFilter, err := buildTimeRangeFilter("day", fromStr, toStr)
+	if err != nil {
+		return result, errInvalidDateRange
+	}
+
+	rollupCollection := database.OpenCollection("analytics_daily", client)
+
+	// Flattens a {plan_id: value} map field into one row per plan with the summed value
+	planTotals := func(field string) []bson.M {
+		return []bson.M{
+			{"$match": dayFilter},
+			{"$project": bson.M{"plans": bson.M{"$objectToArray": bson.M{"$ifNull": []interface{}{"$" + field, bson.M{}}}}}},
+			{"$unwind": "$plans"},
+			{"$group": bson.M{"_id": "$plans.k", "value": bson.M{"$sum": "$plans.v"}}},
+		}
+	}
+
+	subPipeline := append(planTotals("subscriptions_by_plan"),
+		bson.M{"$sort": bson.M{"value": -1}},
+		bson.M{"$limit": limit},
+		bson.M{"$lookup": bson.M{
+			"from":         "plans",
+			"localField":   "_id",
+			"foreignField": "plan_id",
+			"as":           "plan",
+		}},
+		bson.M{"$unwind": bson.M{"path": "$plan", "preserveNullAndEmptyArrays": true}},
+		bson.M{"$project": bson.M{
+			"_id":           0,
+			"plan_id":       "$_id",
+			"plan_name":     "$plan.name",
+			"subscriptions": "$value",
+		}},
+	)
+
+	type planCountRow struct {
+		PlanID        string `bson:"plan_id"`
+		PlanName      string `bson:"plan_name"`
+		Subscriptions int64  `bson:"subscriptions"`
+	}
+
+	cursor, err := rollupCollection.Aggregate(ctx, subPipeline)
+	if err != nil {
+		return result, errors.New("Failed to aggregate popular plans")
+	}
+	var planCounts []planCountRow
+	if err := cursor.All(ctx, &planCounts); err != nil {
+		return result, errors.New("Failed to decode popular plans")
+	}
+
+	type revenueRow struct {
+		PlanID  string  `bson:"_id"`
+		Revenue float64 `bson:"value"`
+	}
+
+	cursor, err = rollupCollection.Aggregate(ctx, planTotals("revenue_by_plan"))
+	if err != nil {
+		return result, errors.New("Failed to aggregate plan revenue")
+	}
+	var revenues []revenueRow
+	if err := cursor.All(ctx, &revenues); err != nil {
+		return result, errors.New("Failed to decode plan revenue")
+	}
+
+	revenueMap := map[string]float64{}
+	for _, r := range revenues {
+		revenueMap[r.PlanID] = r.Revenue
+	}
+
+	for _, p := range planCounts {
+		result.Items = append(result.Items, popularPlanItem{
+			PlanID:        p.PlanID,
+			PlanName:      p.PlanName,
+			Subscriptions: p.Subscriptions,
+			Revenue:       revenueMap[p.PlanID],
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,439 @@
+package controllers
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/openapi"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var apiInfo = openapi.Info{
+	Title:   "MagicStream API",
+	Version: "1.0.0",
+	Description: "Movie streaming API. Authentication uses the access_token cookie set by POST /api/v1/auth/login, " +
+		"the same JWT as a Bearer token, or a personal API key from POST /api/v1/me/api-keys; " +
+		"errors use the ErrorResponse envelope with a stable code. Unversioned paths are deprecated aliases " +
+		"that answer with Deprecation, Sunset and Link headers.",
+}
+
+// Query parameters shared by paginated admin lists
+var (
+	pageParam  = openapi.Param{Name: "page", Type: "integer", Description: "Page number, starting at 1"}
+	limitParam = openapi.Param{Name: "limit", Type: "integer", Description: "Items per page"}
+	fromParam  = openapi.Param{Name: "from", Description: "Start date, YYYY-MM-DD"}
+	toParam    = openapi.Param{Name: "to", Description: "End date, YYYY-MM-DD"}
+	freshParam = openapi.Param{Name: "fresh", Type: "boolean", Description: "Compute from source collections instead of the daily rollups"}
+	granParam  = openapi.Param{Name: "granularity", Description: "day, week or month"}
+)
+
+var movieListParams = []openapi.Param{
+	{Name: "q", Description: "Title search"},
+	{Name: "genre_id", Type: "integer"},
+	{Name: "ranking_max", Type: "integer"},
+	{Name: "min_rating", Type: "number"},
+	{Name: "sort", Description: "top_ranked, az, za or user_rating"},
+	{Name: "page", Type: "integer", Description: "Page number, starting at 1"},
+	{Name: "limit", Description: "Items per page, up to 500, or \"all\""},
+}
+
+// apiOperations documents each route, keyed by "METHOD /gin/path". Request and Response are the
+// types the handler binds and writes, so renaming a field shows up in the spec; a handler that starts
+// writing a different type still needs its entry updated, which the contract test in routes catches.
+// Legacy aliases reuse the entry of their /api/v1 route unless they have one of their own.
+var apiOperations = map[string]openapi.Operation{
+	// Catalog
+	"GET /api/v1/movies": {
+		Summary: "List movies", Tag: "Movies", Auth: openapi.AuthOptional,
+		Description: "Filtered by the parental controls of the selected profile when logged in.",
+		Query:       movieListParams, Response: movieList{},
+	},
+	"GET /movies": {
+		Summary: "List movies", Tag: "Movies", Auth: openapi.AuthOptional,
+		Description: "Returns a bare array of movies when no query parameters are given, otherwise the same object as GET /api/v1/movies.",
+		Query:       movieListParams, Response: openapi.OneOf{[]models.Movie{}, movieList{}},
+	},
+	"GET /api/v1/movies/:imdb_id":         {Summary: "Get a movie", Tag: "Movies", Auth: openapi.AuthRequired, Response: models.Movie{}},
+	"GET /api/v1/me/recommendations":      {Summary: "Movies recommended from favourite genres", Tag: "Movies", Auth: openapi.AuthRequired, Response: []models.Movie{}},
+	"GET /api/v1/genres":                  {Summary: "List genres", Tag: "Movies", Response: []models.Genre{}},
+	"GET /api/v1/movies/:imdb_id/ratings": {Summary: "Rating summary and recent ratings of a movie", Tag: "Ratings", Response: models.RatingAggregate{}},
+	"GET /api/v1/movies/:imdb_id/reviews": {
+		Summary: "List visible reviews of a movie", Tag: "Ratings", Auth: openapi.AuthOptional,
+		Query: []openapi.Param{
+			{Name: "sort", Description: "newest (default), highest, lowest or helpful"},
+			{Name: "cursor", Description: "Cursor from the previous page"},
+			limitParam,
+		},
+		Response: movieReviewList{},
+	},
+	"POST /api/v1/admin/movies": {
+		Summary: "Add a movie", Tag: "Admin: movies", Auth: openapi.AuthRequired, Permission: models.PermissionMoviesWrite,
+		Request: models.Movie{}, Response: mongo.InsertOneResult{}, Status: http.StatusCreated,
+	},
+	"PATCH /api/v1/admin/movies/:imdb_id": {
+		Summary: "Update movie fields", Tag: "Admin: movies", Auth: openapi.AuthRequired, Permission: models.PermissionMoviesWrite,
+		Description: "Returns the updated movie, or only the matched count if it can't be read back.",
+		Request:     updateMovieRequest{}, Response: models.Movie{},
+	},
+	"PATCH /api/v1/admin/movies/:imdb_id/review": {
+		Summary: "Set the admin review and rank it with the LLM", Tag: "Admin: movies", Auth: openapi.AuthRequired,
+		Permission: models.PermissionReviewsRank, Request: adminReviewUpdateRequest{}, Response: adminReviewUpdateResponse{},
+	},
+
+	// Authentication
+	"POST /api/v1/auth/register": {
+		Summary: "Create an account", Tag: "Auth", Request: models.User{}, Response: mongo.InsertOneResult{}, Status: http.StatusCreated,
+	},
+	"POST /api/v1/auth/login": {
+		Summary: "Log in and set the auth cookies", Tag: "Auth", Request: models.UserLogin{}, Response: models.UserResponse{},
+		Description: "Set return_tokens to also get the tokens in the body, for clients that authenticate with a Bearer token.",
+	},
+	"POST /api/v1/auth/logout": {Summary: "Clear the auth cookies", Tag: "Auth", Request: logoutRequest{}, Response: messageResponse{}},
+	"POST /api/v1/auth/refresh": {
+		Summary: "Renew the access token", Tag: "Auth",
+		Description: "Uses the refresh_token cookie, or the refresh token in the body, in which case the new tokens are returned in the body instead of cookies.",
+		Request:     refreshTokenRequest{}, RequestOptional: true, Response: refreshTokenResponse{},
+	},
+	"GET /api/v1/auth/csrf": {
+		Summary: "Get the CSRF token", Tag: "Auth", Response: csrfTokenResponse{},
+		Description: "Cookie-authenticated POST, PUT, PATCH and DELETE requests must send this token in the X-CSRF-Token header. " +
+			"Requests authenticated with a Bearer token or API key don't need it.",
+	},
+	"POST /api/v1/auth/forgot-password": {
+		Summary: "Request a password reset token", Tag: "Auth", Request: forgotPasswordRequest{}, Response: tokenIssuedResponse{},
+	},
+	"POST /api/v1/auth/reset-password": {
+		Summary: "Reset the password with a reset token", Tag: "Auth", Request: resetPasswordRequest{}, Response: messageResponse{},
+	},
+	"POST /api/v1/me/email/verification": {
+		Summary: "Request an email verification token", Tag: "Auth", Auth: openapi.AuthRequired,
+		Description: "Only the message is returned when the address is already verified.",
+		Response:    tokenIssuedResponse{},
+	},
+	"POST /api/v1/me/email/verification/confirm": {
+		Summary: "Confirm the email address", Tag: "Auth", Auth: openapi.AuthRequired,
+		Request: confirmEmailVerificationRequest{}, Response: messageResponse{},
+	},
+
+	// Account
+	"GET /api/v1/me": {Summary: "Current user, subscription and profile", Tag: "Account", Auth: openapi.AuthRequired, Response: meResponse{}},
+	"PUT /api/v1/me/preferences": {
+		Summary: "Update favourite genres", Tag: "Account", Auth: openapi.AuthRequired,
+		Request: updatePreferencesRequest{}, Response: updatePreferencesResponse{},
+	},
+	"POST /api/v1/me/password": {
+		Summary: "Change password", Tag: "Account", Auth: openapi.AuthRequired,
+		Description: "Signs out every other session and revokes the account's API keys. Bearer clients get replacement tokens in the body; cookie sessions get new cookies.",
+		Request:     changePasswordRequest{}, Response: refreshTokenResponse{},
+	},
+	"POST /api/v1/me/email": {
+		Summary: "Request an email change", Tag: "Account", Auth: openapi.AuthRequired,
+		Description: "The confirmation token is emailed to the new address and expires after 24 hours.",
+		Request:     requestEmailChangeRequest{}, Response: messageResponse{},
+	},
+	"POST /api/v1/me/email/confirm": {
+		Summary: "Confirm an email change", Tag: "Account", Auth: openapi.AuthRequired,
+		Request: confirmEmailChangeRequest{}, Response: confirmEmailChangeResponse{},
+	},
+	"GET /api/v1/me/export": {Summary: "Download all personal data", Tag: "Account", Auth: openapi.AuthRequired, ContentType: "application/zip"},
+	"DELETE /api/v1/me": {
+		Summary: "Schedule account deletion", Tag: "Account", Auth: openapi.AuthRequired,
+		Description: "Deletes the account right away, answering 200, when no grace period is configured.",
+		Request:     deleteMeRequest{}, Response: deleteMeResponse{}, Status: http.StatusAccepted, AlsoStatus: []int{http.StatusOK},
+	},
+	"POST /api/v1/me/cancel-deletion": {Summary: "Cancel a scheduled account deletion", Tag: "Account", Auth: openapi.AuthRequired, Response: messageResponse{}},
+
+	// API keys
+	"GET /api/v1/me/api-keys": {Summary: "List active API keys", Tag: "API keys", Auth: openapi.AuthRequired, Response: []models.APIKey{}},
+	"POST /api/v1/me/api-keys": {
+		Summary: "Create an API key", Tag: "API keys", Auth: openapi.AuthRequired,
+		Description: "The key is only returned in this response. It can be granted any of the caller's permissions " +
+			"and loses those the owner's role no longer has. Keys are read-only unless created with write: true; " +
+			"without it they can only write on routes guarded by one of their permissions. " +
+			"API keys cannot create further keys, and are revoked when the password changes or an admin signs the owner out.",
+		Request: createAPIKeyRequest{}, Response: createAPIKeyResponse{}, Status: http.StatusCreated,
+	},
+	"DELETE /api/v1/me/api-keys/:id": {Summary: "Revoke an API key", Tag: "API keys", Auth: openapi.AuthRequired, Response: messageResponse{}},
+
+	// Profiles and parental controls
+	"GET /api/v1/me/profiles": {Summary: "List viewer profiles", Tag: "Profiles", Auth: openapi.AuthRequired, Response: profileList{}},
+	"POST /api/v1/me/profiles": {
+		Summary: "Create a viewer profile", Tag: "Profiles", Auth: openapi.AuthRequired,
+		Request: createProfileRequest{}, Response: models.ProfileResponse{}, Status: http.StatusCreated,
+	},
+	"PATCH /api/v1/me/profiles/:profile_id": {
+		Summary: "Update a viewer profile", Tag: "Profiles", Auth: openapi.AuthRequired,
+		Request: updateProfileRequest{}, Response: models.ProfileResponse{},
+	},
+	"DELETE /api/v1/me/profiles/:profile_id": {Summary: "Delete a viewer profile", Tag: "Profiles", Auth: openapi.AuthRequired, Response: messageResponse{}},
+	"POST /api/v1/me/profiles/:profile_id/select": {
+		Summary: "Switch the session to a profile", Tag: "Profiles", Auth: openapi.AuthRequired,
+		Description: "Selecting a kids profile locks the session to it; leaving a locked session requires the parental PIN.",
+		Request:     selectProfileRequest{}, Response: selectProfileResponse{},
+	},
+	"GET /api/v1/me/parental-controls": {
+		Summary: "Parental controls of every profile", Tag: "Profiles", Auth: openapi.AuthRequired, Response: parentalControlsResponse{},
+	},
+	"PUT /api/v1/me/parental-controls": {
+		Summary: "Set a profile's maximum content rating", Tag: "Profiles", Auth: openapi.AuthRequired,
+		Request: updateParentalControlsRequest{}, Response: updateParentalControlsResponse{},
+	},
+	"PUT /api/v1/me/parental-controls/pin": {
+		Summary: "Set or change the parental PIN", Tag: "Profiles", Auth: openapi.AuthRequired,
+		Request: setParentalPinRequest{}, Response: messageResponse{},
+	},
+
+	// My list and watch progress
+	"GET /api/v1/me/list": {Summary: "Movies in my list", Tag: "My list", Auth: openapi.AuthRequired, Response: []models.Movie{}},
+	"POST /api/v1/me/list/:imdb_id": {
+		Summary: "Add a movie to my list", Tag: "My list", Auth: openapi.AuthRequired,
+		Description: "Answers 200 when the movie is already in the list.",
+		Response:    messageResponse{}, Status: http.StatusCreated, AlsoStatus: []int{http.StatusOK},
+	},
+	"DELETE /api/v1/me/list/:imdb_id": {Summary: "Remove a movie from my list", Tag: "My list", Auth: openapi.AuthRequired, Response: messageResponse{}},
+	"PUT /api/v1/me/progress/:imdb_id": {
+		Summary: "Save playback position", Tag: "Watch progress", Auth: openapi.AuthRequired,
+		Request: updateProgressRequest{}, Response: models.WatchProgress{},
+	},
+	"GET /api/v1/me/continue-watching": {
+		Summary: "Unfinished titles, most recent first", Tag: "Watch progress", Auth: openapi.AuthRequired,
+		Query: []openapi.Param{limitParam}, Response: []models.WatchProgressWithMovie{},
+	},
+	"GET /api/v1/me/history": {
+		Summary: "Watch history", Tag: "Watch progress", Auth: openapi.AuthRequired,
+		Query:    []openapi.Param{{Name: "finished", Type: "boolean"}, limitParam},
+		Response: []models.WatchProgressWithMovie{},
+	},
+
+	// Subscriptions
+	"GET /api/v1/plans": {Summary: "List subscription plans", Tag: "Subscriptions", Auth: openapi.AuthRequired, Response: []models.Plan{}},
+	"POST /api/v1/me/subscription": {
+		Summary: "Subscribe to a plan", Tag: "Subscriptions", Auth: openapi.AuthRequired,
+		Request: subscribeRequest{}, Response: subscribeResponse{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/me/subscription": {Summary: "Current subscription", Tag: "Subscriptions", Auth: openapi.AuthRequired, Response: subscriptionResponse{}},
+	"POST /api/v1/me/subscription/cancel": {
+		Summary: "Cancel at the end of the billing period", Tag: "Subscriptions", Auth: openapi.AuthRequired, Response: cancelSubscriptionResponse{},
+	},
+	"GET /api/v1/me/payments": {Summary: "Payment history", Tag: "Subscriptions", Auth: openapi.AuthRequired, Response: []models.Payment{}},
+
+	// Ratings and reviews
+	"PUT /api/v1/movies/:imdb_id/rating": {
+		Summary: "Rate and review a movie", Tag: "Ratings", Auth: openapi.AuthRequired,
+		Request: upsertRatingRequest{}, Response: upsertRatingResponse{},
+	},
+	"DELETE /api/v1/movies/:imdb_id/rating": {Summary: "Delete my rating", Tag: "Ratings", Auth: openapi.AuthRequired, Response: messageResponse{}},
+	"GET /api/v1/me/ratings":                {Summary: "My ratings", Tag: "Ratings", Auth: openapi.AuthRequired, Response: []map[string]interface{}{}},
+	"POST /api/v1/reviews/:id/report": {
+		Summary: "Report a review", Tag: "Ratings", Auth: openapi.AuthRequired,
+		Description: "Once enough users report a review it goes back to the moderation queue; its rating keeps counting until a moderator hides it.",
+		Request:     reportReviewRequest{}, Response: messageResponse{}, Status: http.StatusCreated,
+	},
+	"POST /api/v1/reviews/:id/helpful": {
+		Summary: "Mark a review as helpful", Tag: "Ratings", Auth: openapi.AuthRequired, Response: messageResponse{}, Status: http.StatusCreated,
+	},
+	"DELETE /api/v1/reviews/:id/helpful": {Summary: "Remove a helpful vote", Tag: "Ratings", Auth: openapi.AuthRequired, Response: messageResponse{}},
+
+	// Admin: users and roles
+	"GET /api/v1/admin/users": {
+		Summary: "Search users", Tag: "Admin: users", Auth: openapi.AuthRequired, Permission: models.PermissionUsersManage,
+		Query: []openapi.Param{
+			{Name: "q", Description: "Name or email search"},
+			{Name: "role"},
+			{Name: "subscription", Description: "Subscription status"},
+			pageParam, limitParam,
+		},
+		Response: adminUserList{},
+	},
+	"GET /api/v1/admin/users/:user_id": {
+		Summary: "User details", Tag: "Admin: users", Auth: openapi.AuthRequired, Permission: models.PermissionUsersManage,
+		Response: adminUserDetail{},
+	},
+	"PATCH /api/v1/admin/users/:user_id/role": {
+		Summary: "Change a user's role", Tag: "Admin: users", Auth: openapi.AuthRequired, Permission: models.PermissionUsersManage,
+		Description: "Signs the user out, so they log in again with the new role's permissions.",
+		Request:     adminUpdateUserRoleRequest{}, Response: adminUpdateUserRoleResponse{},
+	},
+	"PATCH /api/v1/admin/users/:user_id/status": {
+		Summary: "Suspend or reactivate a user", Tag: "Admin: users", Auth: openapi.AuthRequired, Permission: models.PermissionUsersManage,
+		Description: "The admin must hold every permission of the user's role.",
+		Request:     adminUpdateUserStatusRequest{}, Response: adminUpdateUserStatusResponse{},
+	},
+	"POST /api/v1/admin/users/:user_id/logout": {
+		Summary: "Revoke a user's sessions", Tag: "Admin: users", Auth: openapi.AuthRequired, Permission: models.PermissionUsersManage,
+		Description: "Invalidates every token issued to the user and revokes their API keys. The admin must hold every permission of the user's role.",
+		Response:    messageResponse{},
+	},
+	"POST /api/v1/admin/users/:user_id/impersonate": {
+		Summary: "Issue a read-only token acting as the user", Tag: "Admin: users", Auth: openapi.AuthRequired, Permission: models.PermissionUsersManage,
+		Description: "The admin must hold every permission of the user's role.",
+		Response:    impersonationResponse{},
+	},
+	"GET /api/v1/admin/roles": {
+		Summary: "List roles and permissions", Tag: "Admin: users", Auth: openapi.AuthRequired, Permission: models.PermissionUsersManage,
+		Response: roleList{},
+	},
+	"PUT /api/v1/admin/roles/:name": {
+		Summary: "Create or update a role", Tag: "Admin: users", Auth: openapi.AuthRequired, Permission: models.PermissionUsersManage,
+		Description: "Answers 201 when the role is created. Removing permissions signs out every user holding the role.",
+		Request:     adminUpsertRoleRequest{}, Response: models.Role{}, AlsoStatus: []int{http.StatusCreated},
+	},
+	"DELETE /api/v1/admin/roles/:name": {
+		Summary: "Delete a custom role", Tag: "Admin: users", Auth: openapi.AuthRequired, Permission: models.PermissionUsersManage,
+		Response: messageResponse{},
+	},
+
+	// Admin: billing
+	"GET /api/v1/admin/subscriptions": {
+		Summary: "Search subscriptions", Tag: "Admin: billing", Auth: openapi.AuthRequired, Permission: models.PermissionBillingManage,
+		Query:    []openapi.Param{{Name: "q"}, {Name: "status"}, {Name: "plan_id"}, fromParam, toParam, pageParam, limitParam},
+		Response: adminSubscriptionList{},
+	},
+	"PATCH /api/v1/admin/subscriptions/:id/cancel": {
+		Summary: "Cancel a subscription", Tag: "Admin: billing", Auth: openapi.AuthRequired, Permission: models.PermissionBillingManage,
+		Response: messageResponse{},
+	},
+	"PATCH /api/v1/admin/subscriptions/:id/activate": {
+		Summary: "Reactivate a subscription", Tag: "Admin: billing", Auth: openapi.AuthRequired, Permission: models.PermissionBillingManage,
+		Response: messageResponse{},
+	},
+	"GET /api/v1/admin/payments": {
+		Summary: "Search payments", Tag: "Admin: billing", Auth: openapi.AuthRequired, Permission: models.PermissionBillingManage,
+		Query:    []openapi.Param{{Name: "q"}, {Name: "status"}, {Name: "plan_id"}, fromParam, toParam, pageParam, limitParam},
+		Response: adminPaymentList{},
+	},
+
+	// Admin: analytics and reports
+	"GET /api/v1/admin/stats": {
+		Summary: "Dashboard totals", Tag: "Admin: analytics", Auth: openapi.AuthRequired, Permission: models.PermissionAnalyticsRead,
+		Query: []openapi.Param{freshParam}, Response: adminStatsSnapshot{},
+	},
+	"GET /api/v1/admin/analytics/revenue": {
+		Summary: "Revenue over time", Tag: "Admin: analytics", Auth: openapi.AuthRequired, Permission: models.PermissionAnalyticsRead,
+		Query: []openapi.Param{granParam, fromParam, toParam, freshParam}, Response: revenueAnalytics{},
+	},
+	"GET /api/v1/admin/analytics/subscriptions": {
+		Summary: "New and cancelled subscriptions over time", Tag: "Admin: analytics", Auth: openapi.AuthRequired, Permission: models.PermissionAnalyticsRead,
+		Query: []openapi.Param{granParam, fromParam, toParam, freshParam}, Response: subscriptionTrends{},
+	},
+	"GET /api/v1/admin/analytics/plans/popular": {
+		Summary: "Plans by subscriptions and revenue", Tag: "Admin: analytics", Auth: openapi.AuthRequired, Permission: models.PermissionAnalyticsRead,
+		Query: []openapi.Param{fromParam, toParam, limitParam, freshParam}, Response: popularPlans{},
+	},
+	"GET /api/v1/admin/analytics/retention": {
+		Summary: "Cohort retention, churn and MRR movements", Tag: "Admin: analytics", Auth: openapi.AuthRequired, Permission: models.PermissionAnalyticsRead,
+		Query: []openapi.Param{fromParam, toParam}, Response: retentionAnalytics{},
+	},
+	"GET /api/v1/admin/analytics/content": {
+		Summary: "Most watched and rated titles, genre trends, zero-result searches", Tag: "Admin: analytics", Auth: openapi.AuthRequired,
+		Permission: models.PermissionAnalyticsRead,
+		Query: []openapi.Param{
+			granParam, fromParam, toParam, limitParam,
+			{Name: "min_ratings", Type: "integer", Description: "Minimum ratings for the top-rated list"},
+		},
+		Response: contentAnalytics{},
+	},
+	"GET /api/v1/admin/reports": {
+		Summary: "List scheduled reports", Tag: "Admin: reports", Auth: openapi.AuthRequired, Permission: models.PermissionAnalyticsRead,
+		Response: reportList{},
+	},
+	"POST /api/v1/admin/reports": {
+		Summary: "Create a scheduled report", Tag: "Admin: reports", Auth: openapi.AuthRequired, Permission: models.PermissionReportsManage,
+		Description: "Recipients must be verified accounts whose role can read analytics.",
+		Request:     adminCreateReportRequest{}, Response: models.ScheduledReport{}, Status: http.StatusCreated,
+	},
+	"PATCH /api/v1/admin/reports/:id": {
+		Summary: "Update a scheduled report", Tag: "Admin: reports", Auth: openapi.AuthRequired, Permission: models.PermissionReportsManage,
+		Description: "Recipients must be verified accounts whose role can read analytics.",
+		Request:     adminUpdateReportRequest{}, Response: models.ScheduledReport{},
+	},
+	"DELETE /api/v1/admin/reports/:id": {
+		Summary: "Delete a scheduled report", Tag: "Admin: reports", Auth: openapi.AuthRequired, Permission: models.PermissionReportsManage,
+		Response: messageResponse{},
+	},
+	"POST /api/v1/admin/reports/:id/run": {
+		Summary: "Generate a report now", Tag: "Admin: reports", Auth: openapi.AuthRequired, Permission: models.PermissionReportsManage,
+		Response: reportRunResponse{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/admin/reports/:id/files": {
+		Summary: "List generated files of a report", Tag: "Admin: reports", Auth: openapi.AuthRequired, Permission: models.PermissionAnalyticsRead,
+		Query: []openapi.Param{pageParam, limitParam}, Response: generatedReportList{},
+	},
+	"GET /api/v1/admin/reports/:id/files/:file_id": {
+		Summary: "Download a generated file", Tag: "Admin: reports", Auth: openapi.AuthRequired, Permission: models.PermissionAnalyticsRead,
+		ContentType: "application/octet-stream",
+	},
+
+	// Admin: audit and moderation
+	"GET /api/v1/admin/audit": {
+		Summary: "Search the audit log", Tag: "Admin: audit", Auth: openapi.AuthRequired, Permission: models.PermissionAuditRead,
+		Query:    []openapi.Param{{Name: "actor"}, {Name: "action"}, {Name: "target_type"}, {Name: "target_id"}, fromParam, toParam, pageParam, limitParam},
+		Response: auditEventList{},
+	},
+	"GET /api/v1/admin/audit/verify": {
+		Summary: "Verify the audit log hash chain", Tag: "Admin: audit", Auth: openapi.AuthRequired, Permission: models.PermissionAuditRead,
+		Response: auditVerifyResponse{},
+	},
+	"GET /api/v1/admin/reviews/queue": {
+		Summary: "Reviews awaiting moderation", Tag: "Admin: reviews", Auth: openapi.AuthRequired, Permission: models.PermissionReviewsModerate,
+		Query:    []openapi.Param{{Name: "status", Description: "pending (default, includes reported reviews), visible or hidden"}, pageParam, limitParam},
+		Response: reviewQueueList{},
+	},
+	"PATCH /api/v1/admin/reviews/:id/approve": {
+		Summary: "Approve a review", Tag: "Admin: reviews", Auth: openapi.AuthRequired, Permission: models.PermissionReviewsModerate,
+		Request: moderateReviewRequest{}, Response: models.Rating{},
+	},
+	"PATCH /api/v1/admin/reviews/:id/hide": {
+		Summary: "Hide a review", Tag: "Admin: reviews", Auth: openapi.AuthRequired, Permission: models.PermissionReviewsModerate,
+		Request: moderateReviewRequest{}, Response: models.Rating{},
+	},
+
+	// Operations
+	"GET /hello":   {Summary: "Greeting", Tag: "Operations", ContentType: "text/plain"},
+	"GET /healthz": {Summary: "Liveness probe", Tag: "Operations", Response: healthResponse{}},
+	"GET /readyz": {
+		Summary: "Readiness probe; 503 when a critical dependency is down", Tag: "Operations",
+		Response: healthResponse{}, AlsoStatus: []int{http.StatusServiceUnavailable},
+	},
+	"GET /metrics":             {Summary: "Prometheus metrics, bearer METRICS_TOKEN", Tag: "Operations", ContentType: "text/plain"},
+	"GET /api/v1/openapi.json": {Summary: "This document", Tag: "Operations", Response: openapi.Document{}},
+	"GET /api/v1/docs":         {Summary: "API browser", Tag: "Operations", ContentType: "text/html"},
+}
+
+// legacyAliases maps "METHOD /legacy/path" to the "METHOD /api/v1/path" it is an alias of
+var legacyAliases = map[string]string{}
+
+// DocumentLegacyAlias records that a deprecated unversioned route serves the same operation as a v1 route
+func DocumentLegacyAlias(method, legacyPath, successor string) {
+	legacyAliases[method+" "+legacyPath] = method + " " + successor
+}
+
+var openAPIDoc struct {
+	once sync.Once
+	doc  *openapi.Document
+}
+
+// OpenAPIDocument builds the spec from the router's table on first use; routes are all
+// registered before the server starts, so the result never changes afterwards
+func OpenAPIDocument(router *gin.Engine) *openapi.Document {
+	openAPIDoc.once.Do(func() {
+		openAPIDoc.doc = openapi.Build(apiInfo, router.Routes(), apiOperations, legacyAliases)
+	})
+	return openAPIDoc.doc
+}
+
+// OpenAPISpec serves the OpenAPI 3 document
+func OpenAPISpec(router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, OpenAPIDocument(router))
+	}
+}
+
+// APIDocs serves the embedded API browser, which reads openapi.json from the same directory
+func APIDocs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+	}
+}
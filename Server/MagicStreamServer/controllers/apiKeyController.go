@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// maxActiveAPIKeys caps the keys a user can hold at once; revoked and expired keys don't count
+const maxActiveAPIKeys = 25
+
+// activeAPIKeysFilter matches a user's keys that can still be used
+func activeAPIKeysFilter(userID string) bson.D {
+	return bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}}},
+		}},
+	}
+}
+
+type createAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+	// Permissions the key may use, each of which the caller must currently hold
+	Permissions []string `json:"permissions" validate:"max=20"`
+	// Write lets the key change the account; keys are read-only otherwise
+	Write         bool `json:"write"`
+	ExpiresInDays int  `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+type createAPIKeyResponse struct {
+	models.APIKey
+	// Key is only ever returned here; store it now, it can't be shown again
+	Key string `json:"key"`
+}
+
+// CreateAPIKey issues a personal API key for scripts and integrations.
+// API keys can't create further keys, and impersonation sessions are read-only.
+func CreateAPIKey(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		if utils.GetAPIKeyIdFromContext(c) != "" {
+			apierror.Respond(c, apierror.Forbidden("API keys cannot create API keys"))
+			return
+		}
+
+		if utils.IsKidsProfileFromContext(c) {
+			apierror.Respond(c, apierror.Forbidden("Kids profiles cannot manage API keys").WithCode(apierror.CodeKidsProfileRestricted))
+			return
+		}
+
+		var req createAPIKeyRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		permissions := []string{}
+		seen := map[string]bool{}
+		for _, permission := range req.Permissions {
+			permission = strings.TrimSpace(permission)
+			if !models.IsValidPermission(permission) {
+				apierror.Respond(c, apierror.BadRequest("Unknown permission").WithDetail("permission", permission))
+				return
+			}
+			if !utils.HasPermission(c, permission) {
+				apierror.Respond(c, apierror.Forbidden("You can only grant permissions you have").
+					WithCode(apierror.CodeMissingPermission).
+					WithDetail("permission", permission))
+				return
+			}
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+
+		apiKeyCollection := database.OpenCollection("api_keys", client)
+		count, err := apiKeyCollection.CountDocuments(ctx, activeAPIKeysFilter(userID))
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to count API keys"))
+			return
+		}
+		if count >= maxActiveAPIKeys {
+			apierror.Respond(c, apierror.Forbidden("API key limit reached, revoke an unused key first").
+				WithCode(apierror.CodeAPIKeyLimitReached).
+				WithDetail("max_api_keys", maxActiveAPIKeys))
+			return
+		}
+
+		key, prefix, hash, err := utils.GenerateAPIKey()
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to generate API key"))
+			return
+		}
+
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		apiKey := models.APIKey{
+			ID:          bson.NewObjectID(),
+			UserID:      userID,
+			Name:        strings.TrimSpace(req.Name),
+			Prefix:      prefix,
+			KeyHash:     hash,
+			Permissions: permissions,
+			Write:       req.Write,
+			CreatedAt:   now,
+		}
+		if req.ExpiresInDays > 0 {
+			expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+			apiKey.ExpiresAt = &expiresAt
+		}
+
+		if err := validate.Struct(apiKey); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		if _, err := apiKeyCollection.InsertOne(ctx, apiKey); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to create API key"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, createAPIKeyResponse{APIKey: apiKey, Key: key})
+	}
+}
+
+// ListAPIKeys returns the user's keys that haven't been revoked, newest first, with when they were last used
+func ListAPIKeys(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		filter := bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		}
+		cursor, err := database.OpenCollection("api_keys", client).Find(ctx, filter,
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch API keys"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		apiKeys := []models.APIKey{}
+		if err := cursor.All(ctx, &apiKeys); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode API keys"))
+			return
+		}
+
+		c.JSON(http.StatusOK, apiKeys)
+	}
+}
+
+// RevokeAPIKey stops a key from authenticating. The record is kept so the key can't be reused.
+func RevokeAPIKey(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		keyID, err := bson.ObjectIDFromHex(strings.TrimSpace(c.Param("id")))
+		if err != nil {
+			apierror.Respond(c, apierror.BadRequest("Invalid API key id"))
+			return
+		}
+
+		filter := bson.D{
+			{Key: "_id", Value: keyID},
+			{Key: "user_id", Value: userID},
+			{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		}
+		result, err := database.OpenCollection("api_keys", client).UpdateOne(ctx, filter, bson.M{
+			"$set": bson.M{"revoked_at": time.Now()},
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to revoke API key"))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Respond(c, apierror.NotFound("API key not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "API key revoked"})
+	}
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// recordAudit appends an audit event. Admin changes must not go unaudited, so when the write fails
+// it answers the request with an error and returns false, even though the change itself was saved.
+func recordAudit(ctx context.Context, client *mongo.Client, c *gin.Context, action, targetType, targetID string, before, after interface{}) bool {
+	if err := utils.RecordAuditEvent(ctx, client, c, action, targetType, targetID, before, after); err != nil {
+		utils.RequestLogger(c).Error("Failed to record audit event", "action", action, "target_type", targetType, "target_id", targetID, "error", err)
+		apierror.Respond(c, apierror.Internal("The change was saved, but it could not be recorded in the audit log"))
+		return false
+	}
+	return true
+}
+
+type auditEventList struct {
+	Items []models.AuditEvent `json:"items"`
+	pageInfo
+}
+
+// AdminListAuditEvents lists audit events, newest first, filtered by actor, target type, action and date range.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminListAuditEvents(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if actor := strings.TrimSpace(c.Query("actor")); actor != "" {
+			filter["actor_id"] = actor
+		}
+		if targetType := strings.TrimSpace(c.Query("target_type")); targetType != "" {
+			filter["target_type"] = targetType
+		}
+		if targetID := strings.TrimSpace(c.Query("target_id")); targetID != "" {
+			filter["target_id"] = targetID
+		}
+		if action := strings.TrimSpace(c.Query("action")); action != "" {
+			filter["action"] = action
+		}
+
+		timeFilter, err := buildTimeRangeFilter("created_at", c.Query("from"), c.Query("to"))
+		if err != nil {
+			apierror.Respond(c, apierror.BadRequest("Invalid date range. Use YYYY-MM-DD or RFC3339"))
+			return
+		}
+		for k, v := range timeFilter {
+			filter[k] = v
+		}
+
+		// Pagination defaults
+		var limit int64 = 50
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if parsed, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		if limit > 200 {
+			limit = 200
+		}
+
+		var page int64 = 1
+		if pageStr := c.Query("page"); pageStr != "" {
+			if parsed, err := strconv.ParseInt(pageStr, 10, 64); err == nil && parsed > 0 {
+				page = parsed
+			}
+		}
+
+		auditCollection := database.OpenCollection("audit_events", client)
+
+		total, err := auditCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to count audit events"))
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "sequence", Value: -1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+
+		cursor, err := auditCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch audit events"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		items := []models.AuditEvent{}
+		if err := cursor.All(ctx, &items); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode audit events"))
+			return
+		}
+
+		totalPages := int64(0)
+		if total > 0 {
+			totalPages = (total + limit - 1) / limit
+		}
+
+		c.JSON(http.StatusOK, auditEventList{Items: items, pageInfo: pageInfo{Page: page, Limit: limit, Total: total, TotalPages: totalPages}})
+	}
+}
+
+type auditVerifyResponse struct {
+	Valid   bool  `json:"valid"`
+	Checked int64 `json:"checked"`
+	// Sequence number of the first event whose hash doesn't match, when the chain is broken
+	BrokenAt int64 `json:"broken_at,omitempty"`
+}
+
+// AdminVerifyAuditLog recomputes the audit hash chain and reports the first tampered event, if any.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminVerifyAuditLog(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 120*time.Second)
+		defer cancel()
+
+		checked, brokenAt, err := utils.VerifyAuditChain(ctx, client)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to verify audit log"))
+			return
+		}
+
+		c.JSON(http.StatusOK, auditVerifyResponse{Valid: brokenAt == 0, Checked: checked, BrokenAt: brokenAt})
+	}
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+)
+
+type csrfTokenResponse struct {
+	Token string `json:"csrf_token" validate:"required"`
+	// Header to send the token in on POST, PUT, PATCH and DELETE requests
+	Header string `json:"header" validate:"required"`
+}
+
+// GetCSRFToken returns the token cookie-authenticated writes must send in X-CSRF-Token.
+// The current token is reused while valid so tabs sharing the cookie don't invalidate each other.
+func GetCSRFToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := c.Cookie(utils.CSRFCookieName)
+		if err != nil || !utils.ValidCSRFToken(token) {
+			token, err = utils.GenerateCSRFToken()
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to generate CSRF token"))
+				return
+			}
+		}
+
+		// Refresh the cookie either way so an active session's token doesn't expire
+		utils.SetCSRFCookie(c, token)
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, csrfTokenResponse{Token: token, Header: utils.CSRFHeaderName})
+	}
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	healthStatusOK       = "ok"
+	healthStatusFail     = "fail"
+	healthStatusDegraded = "degraded"
+)
+
+type healthCheck struct {
+	Status    string      `json:"status"`
+	Critical  bool        `json:"critical"`
+	LatencyMs int64       `json:"latency_ms,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+	// Individual checks, keyed by dependency; only reported by Readyz
+	Checks map[string]healthCheck `json:"checks,omitempty"`
+}
+
+// Healthz reports that the process is up and serving requests; it never touches dependencies
+func Healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, healthResponse{Status: healthStatusOK})
+	}
+}
+
+// Readyz reports whether the server can take traffic: MongoDB answers, startup index sync has finished
+// and every background worker is running. Setting READYZ_CHECK_LLM=true adds a non-critical check
+// that the OpenAI API is reachable. Responds 503 when any critical check fails.
+func Readyz(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 5*time.Second)
+		defer cancel()
+
+		checks := map[string]healthCheck{
+			"mongo":   checkMongo(ctx, client),
+			"indexes": checkIndexSync(),
+			"workers": checkWorkers(),
+		}
+		if os.Getenv("READYZ_CHECK_LLM") == "true" {
+			checks["llm"] = checkLLMProvider(ctx)
+		}
+
+		status, code := healthStatusOK, http.StatusOK
+		for _, check := range checks {
+			if check.Status == healthStatusOK {
+				continue
+			}
+			if check.Critical {
+				status, code = healthStatusFail, http.StatusServiceUnavailable
+				break
+			}
+			status = healthStatusDegraded
+		}
+
+		c.JSON(code, healthResponse{Status: status, Checks: checks})
+	}
+}
+
+func checkMongo(ctx context.Context, client *mongo.Client) healthCheck {
+	start := time.Now()
+	err := client.Ping(ctx, nil)
+	check := healthCheck{Status: healthStatusOK, Critical: true, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		check.Status = healthStatusFail
+		check.Error = "MongoDB ping failed"
+	}
+	return check
+}
+
+// Failed index groups degrade rather than fail readiness; queries still work, only slower
+func checkIndexSync() healthCheck {
+	status := database.GetIndexSyncStatus()
+	check := healthCheck{Status: healthStatusOK, Critical: true, Details: status}
+	switch {
+	case !status.Completed:
+		check.Status = healthStatusFail
+		check.Error = "Index sync has not finished"
+	case len(status.Failures) > 0:
+		check.Status = healthStatusDegraded
+		check.Critical = false
+		check.Error = "Some indexes could not be created"
+	}
+	return check
+}
+
+func checkWorkers() healthCheck {
+	statuses := workers.WorkerStatuses()
+	check := healthCheck{Status: healthStatusOK, Critical: true, Details: statuses}
+	if len(statuses) == 0 {
+		check.Status = healthStatusFail
+		check.Error = "Background workers have not started"
+		return check
+	}
+
+	var unhealthy []string
+	for _, w := range statuses {
+		if !w.Healthy {
+			unhealthy = append(unhealthy, w.Name)
+		}
+	}
+	if len(unhealthy) > 0 {
+		check.Status = healthStatusFail
+		check.Error = "Unhealthy workers: " + strings.Join(unhealthy, ", ")
+	}
+	return check
+}
+
+// The provider check is cached so frequent probes don't turn into a steady stream of API calls
+const llmCheckCacheTTL = time.Minute
+
+var llmCheckCache struct {
+	sync.Mutex
+	check     healthCheck
+	checkedAt time.Time
+}
+
+func checkLLMProvider(ctx context.Context) healthCheck {
+	llmCheckCache.Lock()
+	defer llmCheckCache.Unlock()
+	if !llmCheckCache.checkedAt.IsZero() && time.Since(llmCheckCache.checkedAt) < llmCheckCacheTTL {
+		return llmCheckCache.check
+	}
+
+	check := healthCheck{Status: healthStatusOK, Critical: false}
+	start := time.Now()
+	if err := pingOpenAI(ctx); err != nil {
+		check.Status = healthStatusDegraded
+		check.Error = err.Error()
+	}
+	check.LatencyMs = time.Since(start).Milliseconds()
+
+	llmCheckCache.check = check
+	llmCheckCache.checkedAt = time.Now()
+	return check
+}
+
+func pingOpenAI(ctx context.Context) error {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return fmt.Errorf("OPENAI_API_KEY not configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.openai.com/v1/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to build OpenAI request")
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("OpenAI API unreachable")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OpenAI API returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -3,7 +3,7 @@ package controllers
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
@@ -11,175 +11,253 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
 	"github.com/tmc/langchaingo/llms/openai"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/metrics"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/tracing"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var validate = validator.New()
+var validate = apierror.NewValidator()
 
 // Rate limiter for OpenAI review ranking calls (5 requests per minute per user)
-var reviewRankingLimiter = utils.NewRateLimiter(5, time.Minute)
+var reviewRankingLimiter = utils.NewRateLimiter("review_ranking", 5, time.Minute)
 
+// movieList is the paginated response of GET /api/v1/movies
+type movieList struct {
+	Items []models.Movie `json:"items"`
+	pageInfo
+}
+
+// GetMovies lists the catalog as a paginated envelope, filtered by the viewer's parental controls
 func GetMovies(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 
-		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		list, err := listMovies(ctx, client, c)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
 
-		// Parse query parameters
-		query := c.Query("q")
-		genreIDStr := c.Query("genre_id")
-		rankingMaxStr := c.Query("ranking_max")
-		sortParam := c.Query("sort")
-		limitStr := c.Query("limit")
-		pageStr := c.Query("page")
+// GetMoviesLegacy serves the unversioned /movies, which returns a bare array when no
+// search, filter, sort or pagination parameter is given
+func GetMoviesLegacy(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
 
-		// Check if pagination params were explicitly provided
-		hasPaginationParams := limitStr != "" || pageStr != ""
+		list, err := listMovies(ctx, client, c)
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
 
-		// Set defaults if not provided
-		if limitStr == "" {
-			limitStr = "20"
+		hasParams := false
+		// Any documented list parameter switches to the envelope
+		for _, param := range movieListParams {
+			if c.Query(param.Name) != "" {
+				hasParams = true
+				break
+			}
 		}
-		if pageStr == "" {
-			pageStr = "1"
+		if !hasParams {
+			c.JSON(http.StatusOK, list.Items)
+			return
 		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+func listMovies(ctx context.Context, client *mongo.Client, c *gin.Context) (movieList, error) {
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+	// Parse query parameters
+	query := c.Query("q")
+	genreIDStr := c.Query("genre_id")
+	rankingMaxStr := c.Query("ranking_max")
+	minRatingStr := c.Query("min_rating")
+	sortParam := c.Query("sort")
+	limitStr := c.Query("limit")
+	pageStr := c.Query("page")
+
+	// Set defaults if not provided
+	if limitStr == "" {
+		limitStr = "20"
+	}
+	if pageStr == "" {
+		pageStr = "1"
+	}
 
-		// Parse limit (default 20, allow larger exports up to 500; "all" fetches everything)
-		var limit int64
-		if strings.EqualFold(limitStr, "all") {
-			limit = 0 // 0 means no limit in Mongo
-		} else {
-			parsedLimit, err := strconv.ParseInt(limitStr, 10, 64)
-			if err != nil || parsedLimit < 1 {
-				parsedLimit = 20
+	// Parse limit (default 20, allow larger exports up to 500; "all" fetches everything)
+	var limit int64
+	if strings.EqualFold(limitStr, "all") {
+		limit = 0 // 0 means no limit in Mongo
+	} else {
+		parsedLimit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsedLimit < 1 {
+			parsedLimit = 20
 		}
-			if parsedLimit > 500 {
-				parsedLimit = 500
-			}
-			limit = parsedLimit
+		if parsedLimit > 500 {
+			parsedLimit = 500
 		}
+		limit = parsedLimit
+	}
 
-		// Parse page (default 1)
-		page, err := strconv.ParseInt(pageStr, 10, 64)
-		if err != nil || page < 1 {
-			page = 1
-		}
+	// Parse page (default 1)
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
 
-		// Build filter
-		filter := bson.D{}
+	// Build filter
+	filter := bson.D{}
+
+	// Title search (case-insensitive regex)
+	var titleFilter bson.D
+	if query != "" {
+		// Escape special regex characters
+		escapedQuery := strings.ReplaceAll(strings.ReplaceAll(query, "\\", "\\\\"), ".", "\\.")
+		titleFilter = bson.D{{
+			Key: "title",
+			Value: bson.D{
+				{Key: "$regex", Value: escapedQuery},
+				{Key: "$options", Value: "i"},
+			},
+		}}
+		filter = append(filter, titleFilter...)
+	}
+
+	// Genre filter (by genre_id)
+	if genreIDStr != "" {
+		genreID, err := strconv.Atoi(genreIDStr)
+		if err == nil {
+			filter = append(filter, bson.E{
+				Key:   "genre.genre_id",
+				Value: genreID,
+			})
+		}
+	}
 
-		// Title search (case-insensitive regex)
-		if query != "" {
-			// Escape special regex characters
-			escapedQuery := strings.ReplaceAll(strings.ReplaceAll(query, "\\", "\\\\"), ".", "\\.")
+	// Ranking filter (ranking_value <= ranking_max)
+	if rankingMaxStr != "" {
+		rankingMax, err := strconv.Atoi(rankingMaxStr)
+		if err == nil && rankingMax > 0 {
 			filter = append(filter, bson.E{
-				Key: "title",
+				Key: "ranking.ranking_value",
 				Value: bson.D{
-					{Key: "$regex", Value: escapedQuery},
-					{Key: "$options", Value: "i"},
+					{Key: "$lte", Value: rankingMax},
 				},
 			})
 		}
+	}
 
-		// Genre filter (by genre_id)
-		if genreIDStr != "" {
-			genreID, err := strconv.Atoi(genreIDStr)
-			if err == nil {
-				filter = append(filter, bson.E{
-					Key:   "genre.genre_id",
-					Value: genreID,
-				})
-			}
+	// User rating filter (avg_rating >= min_rating)
+	if minRatingStr != "" {
+		minRating, err := strconv.ParseFloat(minRatingStr, 64)
+		if err != nil || minRating < 0 || minRating > 5 {
+			return movieList{}, apierror.BadRequest("min_rating must be a number between 0 and 5.")
 		}
+		filter = append(filter, bson.E{
+			Key: "avg_rating",
+			Value: bson.D{
+				{Key: "$gte", Value: minRating},
+			},
+		})
+	}
 
-		// Ranking filter (ranking_value <= ranking_max)
-		if rankingMaxStr != "" {
-			rankingMax, err := strconv.Atoi(rankingMaxStr)
-			if err == nil && rankingMax > 0 {
-				filter = append(filter, bson.E{
-					Key: "ranking.ranking_value",
-					Value: bson.D{
-						{Key: "$lte", Value: rankingMax},
-					},
-				})
-			}
-		}
+	// Parental controls: hide titles above the viewer's maximum rating
+	maxRating, err := effectiveMaxContentRating(ctx, client, c)
+	if err != nil {
+		return movieList{}, apierror.Internal("Failed to load parental controls.")
+	}
+	if ratingFilter, ok := contentRatingFilter(maxRating); ok {
+		filter = append(filter, ratingFilter)
+	}
 
-		// Build sort options
-		findOptions := options.Find()
-		switch sortParam {
-		case "top_ranked":
-			// Best ranking first (lower ranking_value = better)
-			findOptions.SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}})
-		case "az":
-			// Title A-Z (case-insensitive)
-			findOptions.SetSort(bson.D{{Key: "title", Value: 1}})
-		case "za":
-			// Title Z-A (case-insensitive)
-			findOptions.SetSort(bson.D{{Key: "title", Value: -1}})
-		default:
-			// Default: no sort (or maintain existing behavior)
-		}
-
-		// Pagination
-		if limit > 0 {
+	// Build sort options
+	findOptions := options.Find()
+	switch sortParam {
+	case "top_ranked":
+		// Best ranking first (lower ranking_value = better)
+		findOptions.SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}})
+	case "az":
+		// Title A-Z (case-insensitive)
+		findOptions.SetSort(bson.D{{Key: "title", Value: 1}})
+	case "za":
+		// Title Z-A (case-insensitive)
+		findOptions.SetSort(bson.D{{Key: "title", Value: -1}})
+	case "user_rating":
+		// Highest average user rating first; more ratings wins ties
+		findOptions.SetSort(bson.D{{Key: "avg_rating", Value: -1}, {Key: "rating_count", Value: -1}})
+	default:
+		// Default: no sort (or maintain existing behavior)
+	}
+
+	// Pagination
+	if limit > 0 {
 		skip := (page - 1) * limit
 		findOptions.SetSkip(skip)
 		findOptions.SetLimit(limit)
-		}
+	}
 
-		// Count total matching documents for pagination
-		total, err := movieCollection.CountDocuments(ctx, filter)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count movies."})
-			return
-		}
+	// Count total matching documents for pagination
+	total, err := movieCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return movieList{}, apierror.Internal("Failed to count movies.")
+	}
 
-		// Find movies
-		cursor, err := movieCollection.Find(ctx, filter, findOptions)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movies."})
-			return
-		}
-		defer cursor.Close(ctx)
+	// Find movies
+	cursor, err := movieCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return movieList{}, apierror.Internal("Failed to fetch movies.")
+	}
+	defer cursor.Close(ctx)
 
-		var movies []models.Movie
-		if err = cursor.All(ctx, &movies); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode movies."})
-			return
-		}
+	var movies []models.Movie
+	if err = cursor.All(ctx, &movies); err != nil {
+		return movieList{}, apierror.Internal("Failed to decode movies.")
+	}
 
-		// Calculate total pages
-		totalPages := int64(1)
-		if limit > 0 {
-			totalPages = (total + limit - 1) / limit
+	// Searches for titles the catalog doesn't have tell the content team what to add. Only the
+	// title counts: genre, rating and parental filters can empty a search for a title we carry.
+	if query != "" && total == 0 {
+		titleMatches := int64(0)
+		if len(filter) > len(titleFilter) {
+			titleMatches, err = movieCollection.CountDocuments(ctx, titleFilter, options.Count().SetLimit(1))
+			if err != nil {
+				utils.RequestLogger(c).Warn("Failed to count title matches for search analytics", "error", err)
+				titleMatches = 1
+			}
 		}
-
-		// Backward compatibility: if no params provided, return array directly
-		hasParams := query != "" || genreIDStr != "" || rankingMaxStr != "" || sortParam != "" || hasPaginationParams
-		if !hasParams {
-			c.JSON(http.StatusOK, movies)
-			return
+		if titleMatches == 0 {
+			recordZeroResultSearch(ctx, client, query)
 		}
+	}
 
-		// Return paginated response
-		c.JSON(http.StatusOK, gin.H{
-			"items":      movies,
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": totalPages,
-		})
+	// Calculate total pages
+	totalPages := int64(1)
+	if limit > 0 {
+		totalPages = (total + limit - 1) / limit
 	}
+
+	if movies == nil {
+		movies = []models.Movie{}
+	}
+	return movieList{Items: movies, pageInfo: pageInfo{Page: page, Limit: limit, Total: total, TotalPages: totalPages}}, nil
 }
 
 func GetMovie(client *mongo.Client) gin.HandlerFunc {
@@ -190,7 +268,7 @@ func GetMovie(client *mongo.Client) gin.HandlerFunc {
 		movieID := c.Param("imdb_id")
 
 		if movieID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+			apierror.Respond(c, apierror.BadRequest("Movie ID is required"))
 			return
 		}
 
@@ -201,7 +279,18 @@ func GetMovie(client *mongo.Client) gin.HandlerFunc {
 		err := movieCollection.FindOne(ctx, bson.D{{Key: "imdb_id", Value: movieID}}).Decode(&movie)
 
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			apierror.Respond(c, apierror.NotFound("Movie not found"))
+			return
+		}
+
+		// Restricted titles are reported as missing, same as in listings
+		maxRating, err := effectiveMaxContentRating(ctx, client, c)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to load parental controls"))
+			return
+		}
+		if !isContentRatingAllowed(maxRating, movie.ContentRating) {
+			apierror.Respond(c, apierror.NotFound("Movie not found"))
 			return
 		}
 
@@ -217,20 +306,30 @@ func AddMovie(client *mongo.Client) gin.HandlerFunc {
 
 		var movie models.Movie
 		if err := c.ShouldBindJSON(&movie); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
 		if err := validate.Struct(movie); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
+		// Rating aggregates are maintained by the server, never taken from the request
+		movie.AvgRating = 0
+		movie.RatingCount = 0
+		movie.RatingSum = 0
+		movie.RatingHistogram = nil
+
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
 		result, err := movieCollection.InsertOne(ctx, movie)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add movie"})
+			apierror.Respond(c, apierror.Internal("Failed to add movie"))
+			return
+		}
+
+		if !recordAudit(ctx, client, c, "movie.create", "movie", movie.ImdbID, nil, movie) {
 			return
 		}
 
@@ -239,6 +338,17 @@ func AddMovie(client *mongo.Client) gin.HandlerFunc {
 	}
 }
 
+type updateMovieRequest struct {
+	Title       *string         `json:"title"`
+	PosterPath  *string         `json:"poster_path"`
+	YouTubeID   *string         `json:"youtube_id"`
+	Genre       *[]models.Genre `json:"genre"`
+	AdminReview *string         `json:"admin_review"`
+	Ranking     *models.Ranking `json:"ranking"`
+	// "" clears the rating
+	ContentRating *string `json:"content_rating"`
+}
+
 func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 100*time.Second)
@@ -246,22 +356,15 @@ func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 
 		movieID := c.Param("imdb_id")
 		if movieID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+			apierror.Respond(c, apierror.BadRequest("Movie ID is required"))
 			return
 		}
 
 		// Define update struct with optional fields
-		var updateData struct {
-			Title       *string         `json:"title"`
-			PosterPath  *string         `json:"poster_path"`
-			YouTubeID   *string         `json:"youtube_id"`
-			Genre       *[]models.Genre `json:"genre"`
-			AdminReview *string         `json:"admin_review"`
-			Ranking     *models.Ranking `json:"ranking"`
-		}
+		var updateData updateMovieRequest
 
 		if err := c.ShouldBindJSON(&updateData); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
@@ -272,10 +375,10 @@ func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 		err := movieCollection.FindOne(ctx, bson.D{{Key: "imdb_id", Value: movieID}}).Decode(&existingMovie)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+				apierror.Respond(c, apierror.NotFound("Movie not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movie"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch movie"))
 			return
 		}
 
@@ -284,7 +387,7 @@ func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 
 		if updateData.Title != nil {
 			if len(*updateData.Title) < 2 || len(*updateData.Title) > 500 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Title must be between 2 and 500 characters"})
+				apierror.Respond(c, apierror.BadRequest("Title must be between 2 and 500 characters"))
 				return
 			}
 			update["$set"].(bson.M)["title"] = *updateData.Title
@@ -292,7 +395,7 @@ func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 
 		if updateData.PosterPath != nil {
 			if len(*updateData.PosterPath) == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Poster path cannot be empty"})
+				apierror.Respond(c, apierror.BadRequest("Poster path cannot be empty"))
 				return
 			}
 			update["$set"].(bson.M)["poster_path"] = *updateData.PosterPath
@@ -300,7 +403,7 @@ func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 
 		if updateData.YouTubeID != nil {
 			if len(*updateData.YouTubeID) == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "YouTube ID cannot be empty"})
+				apierror.Respond(c, apierror.BadRequest("YouTube ID cannot be empty"))
 				return
 			}
 			update["$set"].(bson.M)["youtube_id"] = *updateData.YouTubeID
@@ -308,7 +411,7 @@ func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 
 		if updateData.Genre != nil {
 			if len(*updateData.Genre) == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "At least one genre is required"})
+				apierror.Respond(c, apierror.BadRequest("At least one genre is required"))
 				return
 			}
 			update["$set"].(bson.M)["genre"] = *updateData.Genre
@@ -321,36 +424,45 @@ func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 		if updateData.Ranking != nil {
 			// Validate ranking
 			if updateData.Ranking.RankingValue < 1 || updateData.Ranking.RankingValue > 5 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Ranking value must be between 1 and 5"})
+				apierror.Respond(c, apierror.BadRequest("Ranking value must be between 1 and 5"))
 				return
 			}
 			update["$set"].(bson.M)["ranking"] = *updateData.Ranking
 		}
 
+		if updateData.ContentRating != nil {
+			contentRating := strings.ToUpper(strings.TrimSpace(*updateData.ContentRating))
+			if contentRating == "" {
+				update["$unset"] = bson.M{"content_rating": ""}
+			} else if models.ContentRatingRank(contentRating) < 0 {
+				apierror.Respond(c, apierror.BadRequest("Content rating must be one of G, PG, PG-13, R, NC-17"))
+				return
+			} else {
+				update["$set"].(bson.M)["content_rating"] = contentRating
+			}
+		}
+
 		// Only update if there are fields to update
-		if len(update["$set"].(bson.M)) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields provided to update"})
+		if len(update["$set"].(bson.M)) == 0 && update["$unset"] == nil {
+			apierror.Respond(c, apierror.BadRequest("No fields provided to update"))
 			return
 		}
 
+		// The updated movie comes back with the write, so the audit event always has both states
 		filter := bson.D{{Key: "imdb_id", Value: movieID}}
-		result, err := movieCollection.UpdateOne(ctx, filter, update)
-
+		var updatedMovie models.Movie
+		err = movieCollection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updatedMovie)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update movie"})
-			return
-		}
-
-		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Movie not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to update movie"))
 			return
 		}
 
-		// Fetch and return updated movie
-		var updatedMovie models.Movie
-		err = movieCollection.FindOne(ctx, filter).Decode(&updatedMovie)
-		if err != nil {
-			c.JSON(http.StatusOK, gin.H{"message": "Movie updated successfully", "matched_count": result.MatchedCount})
+		if !recordAudit(ctx, client, c, "movie.update", "movie", movieID, existingMovie, updatedMovie) {
 			return
 		}
 
@@ -358,48 +470,48 @@ func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 	}
 }
 
+type adminReviewUpdateResponse struct {
+	RankingName string `json:"ranking_name"`
+	AdminReview string `json:"admin_review"`
+}
+
+type adminReviewUpdateRequest struct {
+	AdminReview string `json:"admin_review"`
+}
+
 func AdminReviewUpdate(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Role check is now handled by RequireAdmin middleware, but keep for extra safety
+		// Permission check is handled by RequirePermission middleware, but keep for extra safety
 		userId, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+			apierror.Respond(c, apierror.Unauthorized("User ID not found").WithCode(apierror.CodeNotAuthenticated))
 			return
 		}
 
 		// Rate limiting: check if user has exceeded limit
 		if !reviewRankingLimiter.Allow(userId) {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded. Please wait before submitting another review ranking update.",
-			})
+			apierror.Respond(c, apierror.TooManyRequests("Rate limit exceeded. Please wait before submitting another review ranking update."))
 			return
 		}
 
 		movieId := c.Param("imdb_id")
 		if movieId == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie Id required"})
+			apierror.Respond(c, apierror.BadRequest("Movie Id required"))
 			return
 		}
-		var req struct {
-			AdminReview string `json:"admin_review"`
-		}
-		var resp struct {
-			RankingName string `json:"ranking_name"`
-			AdminReview string `json:"admin_review"`
-		}
+		var req adminReviewUpdateRequest
+		var resp adminReviewUpdateResponse
 
 		if err := c.ShouldBind(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 		
 		// Get review ranking with timeout and error handling
 		sentiment, rankVal, err := GetReviewRanking(req.AdminReview, client, c)
 		if err != nil {
-			log.Printf("Error getting review ranking: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to process review ranking. Please try again later.",
-			})
+			utils.RequestLogger(c).Error("Failed to get review ranking", "error", err)
+			apierror.Respond(c, apierror.New(http.StatusBadGateway, apierror.CodeUpstreamUnavailable, "Failed to process review ranking. Please try again later."))
 			return
 		}
 
@@ -419,15 +531,35 @@ func AdminReviewUpdate(client *mongo.Client) gin.HandlerFunc {
 
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
+		var before struct {
+			AdminReview string         `bson:"admin_review" json:"admin_review"`
+			Ranking     models.Ranking `bson:"ranking" json:"ranking"`
+		}
+		if err := movieCollection.FindOne(ctx, filter).Decode(&before); err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Movie not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Error fetching movie"))
+			return
+		}
+
 		result, err := movieCollection.UpdateOne(ctx, filter, update)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
+			apierror.Respond(c, apierror.Internal("Error updating movie"))
 			return
 		}
 
 		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			apierror.Respond(c, apierror.NotFound("Movie not found"))
+			return
+		}
+
+		if !recordAudit(ctx, client, c, "movie.review_update", "movie", movieId, before, gin.H{
+			"admin_review": req.AdminReview,
+			"ranking":      models.Ranking{RankingValue: rankVal, RankingName: sentiment},
+		}) {
 			return
 		}
 		resp.RankingName = sentiment
@@ -458,7 +590,7 @@ func GetReviewRanking(admin_review string, client *mongo.Client, c *gin.Context)
 	err = godotenv.Load(".env")
 
 	if err != nil {
-		log.Println("Warning: .env file not found")
+		slog.Debug(".env file not found")
 	}
 
 	OpenAiApiKey := os.Getenv("OPENAI_API_KEY")
@@ -484,15 +616,28 @@ func GetReviewRanking(admin_review string, client *mongo.Client, c *gin.Context)
 	ctx, cancel := context.WithTimeout(c, 30*time.Second)
 	defer cancel()
 
+	ctx, span := tracing.Tracer().Start(ctx, "openai.call review_ranking",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("gen_ai.system", "openai")),
+	)
+	defer span.End()
+
+	llmStart := time.Now()
 	response, err := llm.Call(ctx, base_prompt+admin_review)
 
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "OpenAI call failed")
+		metrics.LLMRequestDuration.WithLabelValues("review_ranking", "error").Observe(time.Since(llmStart).Seconds())
 		// Check if timeout occurred
 		if ctx.Err() == context.DeadlineExceeded {
+			metrics.LLMFailures.WithLabelValues("review_ranking", "timeout").Inc()
 			return "", 0, errors.New("OpenAI request timed out")
 		}
+		metrics.LLMFailures.WithLabelValues("review_ranking", "api_error").Inc()
 		return "", 0, errors.New("OpenAI API error: " + err.Error())
 	}
+	metrics.LLMRequestDuration.WithLabelValues("review_ranking", "success").Observe(time.Since(llmStart).Seconds())
 
 	// Normalize response: trim whitespace and handle case-insensitive matching
 	response = strings.TrimSpace(response)
@@ -515,7 +660,8 @@ func GetReviewRanking(admin_review string, client *mongo.Client, c *gin.Context)
 	}
 
 	// Fallback: if no match found, return "Okay" (middle ranking)
-	log.Printf("Warning: OpenAI returned unknown ranking '%s', defaulting to 'Okay'", response)
+	metrics.LLMFailures.WithLabelValues("review_ranking", "unknown_ranking").Inc()
+	utils.RequestLogger(c).Warn("OpenAI returned unknown ranking, defaulting to 'Okay'", "response", response)
 	for _, ranking := range rankings {
 		if ranking.RankingValue == 3 { // Default to "Okay"
 			return ranking.RankingName, ranking.RankingValue, nil
@@ -562,13 +708,14 @@ func GetRecommendedMovies(client *mongo.Client) gin.HandlerFunc {
 		userId, err := utils.GetUserIdFromContext(c)
 
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
 		}
 
-		favouriteGenreIds, err := GetUsersFavouriteGenreIds(userId, client, c)
+		favouriteGenreIds, err := GetProfileFavouriteGenreIds(userId, utils.GetProfileIdFromContext(c), client, c)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierror.Respond(c, apierror.Internal("Failed to load favourite genres"))
 			return
 		}
 
@@ -580,7 +727,7 @@ func GetRecommendedMovies(client *mongo.Client) gin.HandlerFunc {
 
 		err = godotenv.Load(".env")
 		if err != nil {
-			log.Println("Warning: .env file not found")
+			slog.Debug(".env file not found")
 		}
 		var recommendedMovieLimitVal int64 = 5
 
@@ -606,12 +753,35 @@ func GetRecommendedMovies(client *mongo.Client) gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 
+		maxRating, err := effectiveMaxContentRating(ctx, client, c)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to load parental controls"))
+			return
+		}
+		if ratingFilter, ok := contentRatingFilter(maxRating); ok {
+			filter = append(filter, ratingFilter)
+		}
+
+		// Skip titles the viewer has already finished
+		watched, err := GetWatchHistory(ctx, client, userId, utils.GetProfileIdFromContext(c), true, 0)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Error fetching watch history"))
+			return
+		}
+		if len(watched) > 0 {
+			watchedIds := make([]string, 0, len(watched))
+			for _, entry := range watched {
+				watchedIds = append(watchedIds, entry.ImdbID)
+			}
+			filter = append(filter, bson.E{Key: "imdb_id", Value: bson.D{{Key: "$nin", Value: watchedIds}}})
+		}
+
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
 		cursor, err := movieCollection.Find(ctx, filter, findOptions)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recommended movies"})
+			apierror.Respond(c, apierror.Internal("Error fetching recommended movies"))
 			return
 		}
 		defer cursor.Close(ctx)
@@ -619,7 +789,7 @@ func GetRecommendedMovies(client *mongo.Client) gin.HandlerFunc {
 		var recommendedMovies []models.Movie
 
 		if err := cursor.All(ctx, &recommendedMovies); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierror.Respond(c, apierror.Internal("Failed to decode recommended movies"))
 			return
 		}
 		c.JSON(http.StatusOK, recommendedMovies)
@@ -738,14 +908,14 @@ func GetGenres(client *mongo.Client) gin.HandlerFunc {
 
 		cursor, err := genreCollection.Find(ctx, bson.D{})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movie genres"})
+			apierror.Respond(c, apierror.Internal("Error fetching movie genres"))
 			return
 		}
 		defer cursor.Close(ctx)
 
 		var genres []models.Genre
 		if err := cursor.All(ctx, &genres); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierror.Respond(c, apierror.Internal("Failed to decode genres"))
 			return
 		}
 		c.JSON(http.StatusOK, genres)
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
@@ -23,13 +24,13 @@ func AddToMyList(client *mongo.Client) gin.HandlerFunc {
 		// Get user ID from context (set by auth middleware)
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
 		imdbID := c.Param("imdb_id")
 		if imdbID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+			apierror.Respond(c, apierror.BadRequest("Movie ID is required"))
 			return
 		}
 
@@ -39,29 +40,27 @@ func AddToMyList(client *mongo.Client) gin.HandlerFunc {
 		err = movieCollection.FindOne(ctx, bson.D{{Key: "imdb_id", Value: imdbID}}).Decode(&movie)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+				apierror.Respond(c, apierror.NotFound("Movie not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify movie"})
+			apierror.Respond(c, apierror.Internal("Failed to verify movie"))
 			return
 		}
 
-		// Check if already in watchlist
+		// Check if already in the selected profile's watchlist
+		profileID := utils.GetProfileIdFromContext(c)
 		watchlistCollection := database.OpenCollection("watchlists", client)
-		filter := bson.D{
-			{Key: "user_id", Value: userID},
-			{Key: "imdb_id", Value: imdbID},
-		}
+		filter := append(profileScopedFilter(userID, profileID), bson.E{Key: "imdb_id", Value: imdbID})
 
 		var existingWatchlist models.Watchlist
 		err = watchlistCollection.FindOne(ctx, filter).Decode(&existingWatchlist)
 		if err == nil {
 			// Already exists
-			c.JSON(http.StatusOK, gin.H{"message": "Movie already in your list"})
+			c.JSON(http.StatusOK, messageResponse{Message: "Movie already in your list"})
 			return
 		}
 		if err != mongo.ErrNoDocuments {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check watchlist"})
+			apierror.Respond(c, apierror.Internal("Failed to check watchlist"))
 			return
 		}
 
@@ -69,6 +68,7 @@ func AddToMyList(client *mongo.Client) gin.HandlerFunc {
 		watchlist := models.Watchlist{
 			ID:        bson.NewObjectID(),
 			UserID:    userID,
+			ProfileID: profileID,
 			ImdbID:    imdbID,
 			CreatedAt: time.Now(),
 		}
@@ -77,14 +77,14 @@ func AddToMyList(client *mongo.Client) gin.HandlerFunc {
 		if err != nil {
 			// Check if duplicate key error (unique constraint violation)
 			if mongo.IsDuplicateKeyError(err) {
-				c.JSON(http.StatusOK, gin.H{"message": "Movie already in your list"})
+				c.JSON(http.StatusOK, messageResponse{Message: "Movie already in your list"})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add movie to list"})
+			apierror.Respond(c, apierror.Internal("Failed to add movie to list"))
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "Movie added to your list"})
+		c.JSON(http.StatusCreated, messageResponse{Message: "Movie added to your list"})
 	}
 }
 
@@ -97,34 +97,31 @@ func RemoveFromMyList(client *mongo.Client) gin.HandlerFunc {
 		// Get user ID from context
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
 		imdbID := c.Param("imdb_id")
 		if imdbID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+			apierror.Respond(c, apierror.BadRequest("Movie ID is required"))
 			return
 		}
 
 		watchlistCollection := database.OpenCollection("watchlists", client)
-		filter := bson.D{
-			{Key: "user_id", Value: userID},
-			{Key: "imdb_id", Value: imdbID},
-		}
+		filter := append(profileScopedFilter(userID, utils.GetProfileIdFromContext(c)), bson.E{Key: "imdb_id", Value: imdbID})
 
 		result, err := watchlistCollection.DeleteOne(ctx, filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove movie from list"})
+			apierror.Respond(c, apierror.Internal("Failed to remove movie from list"))
 			return
 		}
 
 		if result.DeletedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found in your list"})
+			apierror.Respond(c, apierror.NotFound("Movie not found in your list"))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Movie removed from your list"})
+		c.JSON(http.StatusOK, messageResponse{Message: "Movie removed from your list"})
 	}
 }
 
@@ -137,27 +134,27 @@ func GetMyList(client *mongo.Client) gin.HandlerFunc {
 		// Get user ID from context
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
 		watchlistCollection := database.OpenCollection("watchlists", client)
 		movieCollection := database.OpenCollection("movies", client)
 
-		// Find all watchlist entries for user
-		filter := bson.D{{Key: "user_id", Value: userID}}
+		// Find all watchlist entries for the selected profile
+		filter := profileScopedFilter(userID, utils.GetProfileIdFromContext(c))
 		findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
 
 		cursor, err := watchlistCollection.Find(ctx, filter, findOptions)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch watchlist"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch watchlist"))
 			return
 		}
 		defer cursor.Close(ctx)
 
 		var watchlistItems []models.Watchlist
 		if err = cursor.All(ctx, &watchlistItems); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode watchlist"})
+			apierror.Respond(c, apierror.Internal("Failed to decode watchlist"))
 			return
 		}
 
@@ -172,18 +169,26 @@ func GetMyList(client *mongo.Client) gin.HandlerFunc {
 			return
 		}
 
-		// Fetch full movie details
+		// Fetch full movie details, hiding titles restricted by parental controls
 		movieFilter := bson.D{{Key: "imdb_id", Value: bson.D{{Key: "$in", Value: imdbIDs}}}}
+		maxRating, err := effectiveMaxContentRating(ctx, client, c)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to load parental controls"))
+			return
+		}
+		if ratingFilter, ok := contentRatingFilter(maxRating); ok {
+			movieFilter = append(movieFilter, ratingFilter)
+		}
 		movieCursor, err := movieCollection.Find(ctx, movieFilter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movies"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch movies"))
 			return
 		}
 		defer movieCursor.Close(ctx)
 
 		var movies []models.Movie
 		if err = movieCursor.All(ctx, &movies); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode movies"})
+			apierror.Respond(c, apierror.Internal("Failed to decode movies"))
 			return
 		}
 
@@ -0,0 +1,363 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var parentalPinPattern = regexp.MustCompile(`^[0-9]{4,6}$`)
+
+var (
+	errInvalidParentalPin = errors.New("invalid parental control PIN")
+	errParentalPinNotSet  = errors.New("parental control PIN is not set")
+)
+
+// effectiveMaxContentRating returns the maximum content rating for the current viewer.
+// Anonymous requests and unrestricted profiles return "".
+func effectiveMaxContentRating(ctx context.Context, client *mongo.Client, c *gin.Context) (string, error) {
+	userID, err := utils.GetUserIdFromContext(c)
+	if err != nil {
+		return "", nil
+	}
+
+	profileID := utils.GetProfileIdFromContext(c)
+	if profileID == "" {
+		var user models.User
+		err := database.OpenCollection("users", client).FindOne(ctx,
+			bson.D{{Key: "user_id", Value: userID}},
+			options.FindOne().SetProjection(bson.M{"max_content_rating": 1}),
+		).Decode(&user)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return "", err
+		}
+		return user.MaxContentRating, nil
+	}
+
+	var profile models.Profile
+	err = database.OpenCollection("profiles", client).FindOne(ctx, bson.D{
+		{Key: "profile_id", Value: profileID},
+		{Key: "user_id", Value: userID},
+	}).Decode(&profile)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return "", err
+	}
+	return profile.MaxContentRating, nil
+}
+
+// contentRatingFilter returns a movie filter element allowing only ratings up to maxRating.
+// Unrated movies are excluded for restricted viewers.
+func contentRatingFilter(maxRating string) (bson.E, bool) {
+	rank := models.ContentRatingRank(maxRating)
+	if rank < 0 {
+		return bson.E{}, false
+	}
+	return bson.E{
+		Key:   "content_rating",
+		Value: bson.D{{Key: "$in", Value: models.ContentRatings[:rank+1]}},
+	}, true
+}
+
+// isContentRatingAllowed reports whether a movie rating is visible under maxRating
+func isContentRatingAllowed(maxRating, rating string) bool {
+	maxRank := models.ContentRatingRank(maxRating)
+	if maxRank < 0 {
+		return true
+	}
+	rank := models.ContentRatingRank(rating)
+	return rank >= 0 && rank <= maxRank
+}
+
+// verifyParentalPin checks pin against the account's PIN; it always fails until a PIN has been set
+func verifyParentalPin(user models.User, pin string) error {
+	if user.ParentalPin == "" {
+		return errParentalPinNotSet
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.ParentalPin), []byte(pin)); err != nil {
+		return errInvalidParentalPin
+	}
+	return nil
+}
+
+type profileParentalControls struct {
+	ProfileID        string `json:"profile_id"`
+	Name             string `json:"name"`
+	IsPrimary        bool   `json:"is_primary"`
+	IsKids           bool   `json:"is_kids"`
+	MaxContentRating string `json:"max_content_rating"` // "" = unrestricted
+}
+
+type parentalControlsResponse struct {
+	PinSet         bool                      `json:"pin_set"`
+	ContentRatings []string                  `json:"content_ratings"`
+	Profiles       []profileParentalControls `json:"profiles"`
+}
+
+// GetParentalControls returns the PIN status and maximum content rating of every profile
+func GetParentalControls(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		var user models.User
+		err = database.OpenCollection("users", client).FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		cursor, err := database.OpenCollection("profiles", client).Find(ctx, bson.D{{Key: "user_id", Value: userID}},
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch profiles"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var profiles []models.Profile
+		if err = cursor.All(ctx, &profiles); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode profiles"))
+			return
+		}
+
+		items := make([]profileParentalControls, 0, len(profiles)+1)
+		items = append(items, profileParentalControls{
+			ProfileID:        user.UserID,
+			Name:             user.FirstName,
+			IsPrimary:        true,
+			MaxContentRating: user.MaxContentRating,
+		})
+		for _, profile := range profiles {
+			items = append(items, profileParentalControls{
+				ProfileID:        profile.ProfileID,
+				Name:             profile.Name,
+				IsKids:           profile.IsKids,
+				MaxContentRating: profile.MaxContentRating,
+			})
+		}
+
+		c.JSON(http.StatusOK, parentalControlsResponse{
+			PinSet:         user.ParentalPin != "",
+			ContentRatings: models.ContentRatings,
+			Profiles:       items,
+		})
+	}
+}
+
+type setParentalPinRequest struct {
+	Password string `json:"password" validate:"required"`
+	NewPin   string `json:"new_pin" validate:"required"`
+}
+
+// SetParentalPin sets or changes the parental control PIN after re-checking the account password
+func SetParentalPin(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		if utils.IsKidsProfileFromContext(c) {
+			apierror.Respond(c, apierror.Forbidden("Kids profiles cannot manage parental controls").WithCode(apierror.CodeKidsProfileRestricted))
+			return
+		}
+
+		var req setParentalPinRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		if !parentalPinPattern.MatchString(req.NewPin) {
+			apierror.Respond(c, apierror.BadRequest("PIN must be 4 to 6 digits"))
+			return
+		}
+
+		userCollection := database.OpenCollection("users", client)
+		var user models.User
+		err = userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			apierror.Respond(c, apierror.Unauthorized("Password is incorrect").WithCode(apierror.CodeInvalidCredentials))
+			return
+		}
+
+		hashedPin, err := utils.HashPassword(req.NewPin)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to hash PIN"))
+			return
+		}
+
+		_, err = userCollection.UpdateOne(ctx, bson.D{{Key: "user_id", Value: userID}}, bson.M{
+			"$set": bson.M{
+				"parental_pin": hashedPin,
+				"update_at":    time.Now(),
+			},
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to update PIN"))
+			return
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "Parental control PIN updated"})
+	}
+}
+
+type updateParentalControlsRequest struct {
+	Pin              string `json:"pin"`
+	ProfileID        string `json:"profile_id"`
+	MaxContentRating string `json:"max_content_rating"` // "" removes the restriction
+}
+
+type updateParentalControlsResponse struct {
+	Message          string `json:"message"`
+	ProfileID        string `json:"profile_id"`
+	MaxContentRating string `json:"max_content_rating"`
+}
+
+// UpdateParentalControls changes the maximum content rating of a profile. Requires the PIN.
+func UpdateParentalControls(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		if utils.IsKidsProfileFromContext(c) {
+			apierror.Respond(c, apierror.Forbidden("Kids profiles cannot manage parental controls").WithCode(apierror.CodeKidsProfileRestricted))
+			return
+		}
+
+		var req updateParentalControlsRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		req.MaxContentRating = strings.ToUpper(strings.TrimSpace(req.MaxContentRating))
+		if req.MaxContentRating != "" && models.ContentRatingRank(req.MaxContentRating) < 0 {
+			apierror.Respond(c, apierror.BadRequest("Invalid content rating").WithDetail("allowed", models.ContentRatings))
+			return
+		}
+
+		userCollection := database.OpenCollection("users", client)
+		var user models.User
+		err = userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		if user.ParentalPin == "" {
+			apierror.Respond(c, apierror.BadRequest("Set a parental control PIN first").WithCode(apierror.CodeParentalPinRequired))
+			return
+		}
+		if err := verifyParentalPin(user, req.Pin); err != nil {
+			apierror.Respond(c, apierror.Forbidden("Incorrect PIN").WithCode(apierror.CodeIncorrectPin))
+			return
+		}
+
+		update := bson.M{}
+		if req.MaxContentRating == "" {
+			update["$unset"] = bson.M{"max_content_rating": ""}
+		} else {
+			update["$set"] = bson.M{"max_content_rating": req.MaxContentRating}
+		}
+
+		profileID := strings.TrimSpace(req.ProfileID)
+		if profileID == "" || profileID == userID {
+			if _, ok := update["$set"]; !ok {
+				update["$set"] = bson.M{}
+			}
+			update["$set"].(bson.M)["update_at"] = time.Now()
+
+			if _, err := userCollection.UpdateOne(ctx, bson.D{{Key: "user_id", Value: userID}}, update); err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to update parental controls"))
+				return
+			}
+
+			c.JSON(http.StatusOK, updateParentalControlsResponse{Message: "Parental controls updated", ProfileID: userID, MaxContentRating: req.MaxContentRating})
+			return
+		}
+
+		profileCollection := database.OpenCollection("profiles", client)
+		filter := bson.D{
+			{Key: "profile_id", Value: profileID},
+			{Key: "user_id", Value: userID},
+		}
+
+		var profile models.Profile
+		if err := profileCollection.FindOne(ctx, filter).Decode(&profile); err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Profile not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch profile"))
+			return
+		}
+
+		// Kids profiles can be tightened but never opened beyond the kids limit
+		if profile.IsKids && (req.MaxContentRating == "" ||
+			models.ContentRatingRank(req.MaxContentRating) > models.ContentRatingRank(models.KidsMaxContentRating)) {
+			apierror.Respond(c, apierror.BadRequest("Kids profiles are limited to "+models.KidsMaxContentRating))
+			return
+		}
+
+		if _, ok := update["$set"]; !ok {
+			update["$set"] = bson.M{}
+		}
+		update["$set"].(bson.M)["updated_at"] = time.Now()
+
+		if _, err := profileCollection.UpdateOne(ctx, filter, update); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to update parental controls"))
+			return
+		}
+
+		c.JSON(http.StatusOK, updateParentalControlsResponse{Message: "Parental controls updated", ProfileID: profileID, MaxContentRating: req.MaxContentRating})
+	}
+}
@@ -0,0 +1,590 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// profileScopedFilter matches documents owned by a profile; the primary profile's data has no profile_id
+func profileScopedFilter(userID, profileID string) bson.D {
+	if profileID == "" {
+		return bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "profile_id", Value: nil},
+		}
+	}
+	return bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "profile_id", Value: profileID},
+	}
+}
+
+// maxProfilesForUser returns how many profiles (including the primary one) the user's plan allows.
+// Users without a streamable subscription only get the primary profile.
+func maxProfilesForUser(ctx context.Context, client *mongo.Client, userID string) (int, error) {
+	subscriptionCollection := database.OpenCollection("subscriptions", client)
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: []string{"ACTIVE", "CANCELED"}}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}
+
+	var subscription models.Subscription
+	err := subscriptionCollection.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})).Decode(&subscription)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 1, nil
+		}
+		return 0, err
+	}
+
+	var plan models.Plan
+	err = database.OpenCollection("plans", client).FindOne(ctx, bson.D{{Key: "plan_id", Value: subscription.PlanID}}).Decode(&plan)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 1, nil
+		}
+		return 0, err
+	}
+
+	if plan.MaxStreams < 1 {
+		return 1, nil
+	}
+	return plan.MaxStreams, nil
+}
+
+// GetProfileFavouriteGenreIds returns favourite genre IDs for the selected profile
+func GetProfileFavouriteGenreIds(userID, profileID string, client *mongo.Client, c *gin.Context) ([]int, error) {
+	if profileID == "" {
+		return GetUsersFavouriteGenreIds(userID, client, c)
+	}
+
+	ctx, cancel := context.WithTimeout(c, 30*time.Second)
+	defer cancel()
+
+	var profile models.Profile
+	err := database.OpenCollection("profiles", client).FindOne(ctx, bson.D{
+		{Key: "profile_id", Value: profileID},
+		{Key: "user_id", Value: userID},
+	}).Decode(&profile)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return []int{}, nil
+		}
+		return []int{}, err
+	}
+
+	genreIds := make([]int, 0, len(profile.FavouriteGenres))
+	for _, genre := range profile.FavouriteGenres {
+		genreIds = append(genreIds, genre.GenreID)
+	}
+	return genreIds, nil
+}
+
+func primaryProfileResponse(user models.User) models.ProfileResponse {
+	return models.ProfileResponse{
+		ProfileID:        user.UserID,
+		Name:             user.FirstName,
+		IsPrimary:        true,
+		MaxContentRating: user.MaxContentRating,
+		FavouriteGenres:  user.FavouriteGenres,
+	}
+}
+
+func profileResponse(profile models.Profile) models.ProfileResponse {
+	genres := profile.FavouriteGenres
+	if genres == nil {
+		genres = []models.Genre{}
+	}
+	return models.ProfileResponse{
+		ProfileID:        profile.ProfileID,
+		Name:             profile.Name,
+		AvatarURL:        profile.AvatarURL,
+		IsKids:           profile.IsKids,
+		MaxContentRating: profile.MaxContentRating,
+		FavouriteGenres:  genres,
+	}
+}
+
+type profileList struct {
+	Items []models.ProfileResponse `json:"items"`
+	// Profiles the current plan allows, including the primary one
+	MaxProfiles int `json:"max_profiles"`
+}
+
+// ListProfiles returns the primary profile followed by the account's additional profiles
+func ListProfiles(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		var user models.User
+		err = database.OpenCollection("users", client).FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		profileCollection := database.OpenCollection("profiles", client)
+		findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+		cursor, err := profileCollection.Find(ctx, bson.D{{Key: "user_id", Value: userID}}, findOptions)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch profiles"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var profiles []models.Profile
+		if err = cursor.All(ctx, &profiles); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode profiles"))
+			return
+		}
+
+		maxProfiles, err := maxProfilesForUser(ctx, client, userID)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to check plan limits"))
+			return
+		}
+
+		items := make([]models.ProfileResponse, 0, len(profiles)+1)
+		items = append(items, primaryProfileResponse(user))
+		for _, profile := range profiles {
+			items = append(items, profileResponse(profile))
+		}
+
+		c.JSON(http.StatusOK, profileList{Items: items, MaxProfiles: maxProfiles})
+	}
+}
+
+type createProfileRequest struct {
+	Name            string         `json:"name"`
+	AvatarURL       string         `json:"avatar_url"`
+	IsKids          bool           `json:"is_kids"`
+	FavouriteGenres []models.Genre `json:"favourite_genres"`
+}
+
+// CreateProfile adds a profile to the account, up to the limit of the user's plan
+func CreateProfile(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		if utils.IsKidsProfileFromContext(c) {
+			apierror.Respond(c, apierror.Forbidden("Kids profiles cannot manage profiles").WithCode(apierror.CodeKidsProfileRestricted))
+			return
+		}
+
+		var req createProfileRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		now := time.Now()
+		profile := models.Profile{
+			ProfileID:       bson.NewObjectID().Hex(),
+			UserID:          userID,
+			Name:            strings.TrimSpace(req.Name),
+			AvatarURL:       strings.TrimSpace(req.AvatarURL),
+			IsKids:          req.IsKids,
+			FavouriteGenres: req.FavouriteGenres,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		if profile.FavouriteGenres == nil {
+			profile.FavouriteGenres = []models.Genre{}
+		}
+		if profile.IsKids {
+			profile.MaxContentRating = models.KidsMaxContentRating
+
+			// Without a PIN nothing would stop a kids session from switching profiles
+			var user models.User
+			err := database.OpenCollection("users", client).FindOne(ctx, bson.D{{Key: "user_id", Value: userID}},
+				options.FindOne().SetProjection(bson.M{"parental_pin": 1})).Decode(&user)
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+				return
+			}
+			if user.ParentalPin == "" {
+				apierror.Respond(c, apierror.BadRequest("Set a parental control PIN before creating a kids profile").WithCode(apierror.CodeParentalPinRequired))
+				return
+			}
+		}
+
+		if err := validate.Struct(profile); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		maxProfiles, err := maxProfilesForUser(ctx, client, userID)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to check plan limits"))
+			return
+		}
+
+		profileCollection := database.OpenCollection("profiles", client)
+		count, err := profileCollection.CountDocuments(ctx, bson.D{{Key: "user_id", Value: userID}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to count profiles"))
+			return
+		}
+
+		// +1 for the primary profile
+		if int(count)+1 >= maxProfiles {
+			apierror.Respond(c, apierror.Forbidden("Profile limit reached for your plan").
+				WithCode(apierror.CodeProfileLimitReached).
+				WithDetail("max_profiles", maxProfiles))
+			return
+		}
+
+		if _, err := profileCollection.InsertOne(ctx, profile); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to create profile"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, profileResponse(profile))
+	}
+}
+
+type updateProfileRequest struct {
+	Name            *string         `json:"name"`
+	AvatarURL       *string         `json:"avatar_url"`
+	IsKids          *bool           `json:"is_kids"`
+	FavouriteGenres *[]models.Genre `json:"favourite_genres"`
+	Pin             string          `json:"pin"` // required to change is_kids
+}
+
+// UpdateProfile updates name, avatar, kids flag or favourite genres of an additional profile
+func UpdateProfile(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		if utils.IsKidsProfileFromContext(c) {
+			apierror.Respond(c, apierror.Forbidden("Kids profiles cannot manage profiles").WithCode(apierror.CodeKidsProfileRestricted))
+			return
+		}
+
+		profileID := strings.TrimSpace(c.Param("profile_id"))
+		if profileID == "" || profileID == userID {
+			apierror.Respond(c, apierror.BadRequest("The primary profile is managed from account settings"))
+			return
+		}
+
+		var updateData updateProfileRequest
+
+		if err := c.ShouldBindJSON(&updateData); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		if updateData.IsKids != nil {
+			var user models.User
+			err := database.OpenCollection("users", client).FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
+			if err != nil {
+				apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+				return
+			}
+			if err := verifyParentalPin(user, updateData.Pin); err != nil {
+				if err == errParentalPinNotSet {
+					apierror.Respond(c, apierror.BadRequest("Set a parental control PIN first").WithCode(apierror.CodeParentalPinRequired))
+					return
+				}
+				apierror.Respond(c, apierror.Forbidden("Incorrect PIN").WithCode(apierror.CodeIncorrectPin))
+				return
+			}
+		}
+
+		setFields := bson.M{}
+		unsetFields := bson.M{}
+
+		if updateData.Name != nil {
+			name := strings.TrimSpace(*updateData.Name)
+			if len(name) < 1 || len(name) > 50 {
+				apierror.Respond(c, apierror.BadRequest("Name must be between 1 and 50 characters"))
+				return
+			}
+			setFields["name"] = name
+		}
+
+		if updateData.AvatarURL != nil {
+			setFields["avatar_url"] = strings.TrimSpace(*updateData.AvatarURL)
+		}
+
+		if updateData.IsKids != nil {
+			setFields["is_kids"] = *updateData.IsKids
+			if *updateData.IsKids {
+				setFields["max_content_rating"] = models.KidsMaxContentRating
+			} else {
+				unsetFields["max_content_rating"] = ""
+			}
+		}
+
+		if updateData.FavouriteGenres != nil {
+			setFields["favourite_genres"] = *updateData.FavouriteGenres
+		}
+
+		if len(setFields) == 0 && len(unsetFields) == 0 {
+			apierror.Respond(c, apierror.BadRequest("No fields provided to update"))
+			return
+		}
+
+		setFields["updated_at"] = time.Now()
+		update := bson.M{"$set": setFields}
+		if len(unsetFields) > 0 {
+			update["$unset"] = unsetFields
+		}
+
+		profileCollection := database.OpenCollection("profiles", client)
+		filter := bson.D{
+			{Key: "profile_id", Value: profileID},
+			{Key: "user_id", Value: userID},
+		}
+
+		var updated models.Profile
+		err = profileCollection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Profile not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to update profile"))
+			return
+		}
+
+		c.JSON(http.StatusOK, profileResponse(updated))
+	}
+}
+
+// DeleteProfile removes an additional profile along with its watchlist, ratings (and the reports and
+// helpful votes on them) and watch progress
+func DeleteProfile(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		if utils.IsKidsProfileFromContext(c) {
+			apierror.Respond(c, apierror.Forbidden("Kids profiles cannot manage profiles").WithCode(apierror.CodeKidsProfileRestricted))
+			return
+		}
+
+		profileID := strings.TrimSpace(c.Param("profile_id"))
+		if profileID == "" || profileID == userID {
+			apierror.Respond(c, apierror.BadRequest("The primary profile cannot be deleted"))
+			return
+		}
+
+		profileCollection := database.OpenCollection("profiles", client)
+		profileFilter := bson.D{
+			{Key: "profile_id", Value: profileID},
+			{Key: "user_id", Value: userID},
+		}
+		count, err := profileCollection.CountDocuments(ctx, profileFilter)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch profile"))
+			return
+		}
+		if count == 0 {
+			apierror.Respond(c, apierror.NotFound("Profile not found"))
+			return
+		}
+
+		// The profile's data goes first, so a failed delete can be retried
+		scoped := profileScopedFilter(userID, profileID)
+		ratingCollection := database.OpenCollection("ratings", client)
+		cursor, err := ratingCollection.Find(ctx, scoped, options.Find().SetProjection(bson.M{"_id": 1, "imdb_id": 1}))
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch profile ratings"))
+			return
+		}
+		var ratings []models.Rating
+		if err := cursor.All(ctx, &ratings); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch profile ratings"))
+			return
+		}
+		ratingIDs := make([]bson.ObjectID, 0, len(ratings))
+		ratedMovies := make([]string, 0, len(ratings))
+		for _, rating := range ratings {
+			ratingIDs = append(ratingIDs, rating.ID)
+			ratedMovies = append(ratedMovies, rating.ImdbID)
+		}
+
+		reviewFilter := bson.D{{Key: "rating_id", Value: bson.D{{Key: "$in", Value: ratingIDs}}}}
+		cleanups := []struct {
+			collection string
+			filter     bson.D
+		}{
+			{"review_reports", reviewFilter},
+			{"review_votes", reviewFilter},
+			{"watchlists", scoped},
+			{"ratings", scoped},
+			{"watch_progress", scoped},
+		}
+		for _, cleanup := range cleanups {
+			if _, err := database.OpenCollection(cleanup.collection, client).DeleteMany(ctx, cleanup.filter); err != nil {
+				utils.RequestLogger(c).Error("Failed to delete profile data", "profile_id", profileID, "collection", cleanup.collection, "error", err)
+				apierror.Respond(c, apierror.Internal("Failed to delete profile data"))
+				return
+			}
+		}
+		if err := workers.RecomputeMovieRatings(ctx, client, ratedMovies...); err != nil {
+			utils.RequestLogger(c).Warn("Failed to update rating aggregates after deleting profile", "profile_id", profileID, "error", err)
+		}
+
+		if _, err := profileCollection.DeleteOne(ctx, profileFilter); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to delete profile"))
+			return
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "Profile deleted"})
+	}
+}
+
+type selectProfileRequest struct {
+	Pin string `json:"pin"` // required to leave a session locked to a kids profile
+}
+
+type selectProfileResponse struct {
+	Profile models.ProfileResponse `json:"profile"`
+	// Locked sessions always use Profile; X-Profile-ID can't select another one
+	Locked       bool   `json:"locked"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// SelectProfile reissues the session's tokens for a profile. Selecting a kids profile locks the
+// session to it, and only the parental PIN unlocks it again. Cookie sessions get new cookies,
+// Bearer clients get the new tokens in the response.
+func SelectProfile(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		if utils.GetImpersonatorIdFromContext(c) != "" || utils.GetAPIKeyIdFromContext(c) != "" {
+			apierror.Respond(c, apierror.BadRequest("Profiles can only be selected from a signed-in session"))
+			return
+		}
+
+		var req selectProfileRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		var user models.User
+		err = database.OpenCollection("users", client).FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("User not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch user"))
+			return
+		}
+
+		profileID := strings.TrimSpace(c.Param("profile_id"))
+		selected := primaryProfileResponse(user)
+		if profileID != userID {
+			var profile models.Profile
+			err := database.OpenCollection("profiles", client).FindOne(ctx, bson.D{
+				{Key: "profile_id", Value: profileID},
+				{Key: "user_id", Value: userID},
+			}).Decode(&profile)
+			if err != nil {
+				if err == mongo.ErrNoDocuments {
+					apierror.Respond(c, apierror.NotFound("Profile not found"))
+					return
+				}
+				apierror.Respond(c, apierror.Internal("Failed to fetch profile"))
+				return
+			}
+			selected = profileResponse(profile)
+		}
+
+		if lockedID := utils.GetLockedProfileIdFromContext(c); lockedID != "" && lockedID != selected.ProfileID {
+			if err := verifyParentalPin(user, req.Pin); err != nil {
+				apierror.Respond(c, apierror.Forbidden("Incorrect PIN").WithCode(apierror.CodeIncorrectPin))
+				return
+			}
+		}
+
+		lockID := ""
+		if selected.IsKids {
+			// A lock nobody can lift would only end at the next login
+			if user.ParentalPin == "" {
+				apierror.Respond(c, apierror.BadRequest("Set a parental control PIN first").WithCode(apierror.CodeParentalPinRequired))
+				return
+			}
+			lockID = selected.ProfileID
+		}
+
+		permissions, err := utils.ResolvePermissions(ctx, client, user.Role)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to load permissions"))
+			return
+		}
+
+		token, refreshToken, err := reissueSession(c, client, user, lockID, permissions, time.Now())
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to issue tokens"))
+			return
+		}
+
+		c.JSON(http.StatusOK, selectProfileResponse{
+			Profile:      selected,
+			Locked:       lockID != "",
+			Token:        token,
+			RefreshToken: refreshToken,
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// watchFinishedThreshold returns the fraction of a title that counts as finished.
+// Configured via WATCH_FINISHED_THRESHOLD (default 0.9).
+func watchFinishedThreshold() float64 {
+	threshold := 0.9
+	if v := os.Getenv("WATCH_FINISHED_THRESHOLD"); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil && parsed > 0 && parsed <= 1 {
+			threshold = parsed
+		}
+	}
+	return threshold
+}
+
+// GetWatchHistory returns the viewer's watch progress entries, most recently watched first.
+// finishedOnly limits the result to titles watched past the finished threshold; limit 0 means no limit.
+func GetWatchHistory(ctx context.Context, client *mongo.Client, userID, profileID string, finishedOnly bool, limit int64) ([]models.WatchProgress, error) {
+	filter := profileScopedFilter(userID, profileID)
+	if finishedOnly {
+		filter = append(filter, bson.E{Key: "finished", Value: true})
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "last_watched_at", Value: -1}})
+	if limit > 0 {
+		findOptions.SetLimit(limit)
+	}
+
+	cursor, err := database.OpenCollection("watch_progress", client).Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	history := []models.WatchProgress{}
+	if err := cursor.All(ctx, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// attachMovies joins progress entries with movie details, dropping titles hidden by parental controls
+func attachMovies(ctx context.Context, client *mongo.Client, c *gin.Context, entries []models.WatchProgress) ([]models.WatchProgressWithMovie, error) {
+	items := make([]models.WatchProgressWithMovie, 0, len(entries))
+	if len(entries) == 0 {
+		return items, nil
+	}
+
+	imdbIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		imdbIDs = append(imdbIDs, entry.ImdbID)
+	}
+
+	movieFilter := bson.D{{Key: "imdb_id", Value: bson.D{{Key: "$in", Value: imdbIDs}}}}
+	maxRating, err := effectiveMaxContentRating(ctx, client, c)
+	if err != nil {
+		return nil, err
+	}
+	if ratingFilter, ok := contentRatingFilter(maxRating); ok {
+		movieFilter = append(movieFilter, ratingFilter)
+	}
+
+	cursor, err := database.OpenCollection("movies", client).Find(ctx, movieFilter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var movies []models.Movie
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+
+	movieMap := make(map[string]models.Movie, len(movies))
+	for _, movie := range movies {
+		movieMap[movie.ImdbID] = movie
+	}
+
+	for _, entry := range entries {
+		movie, exists := movieMap[entry.ImdbID]
+		if !exists {
+			continue
+		}
+		percent := 0.0
+		if entry.DurationSeconds > 0 {
+			percent = entry.PositionSeconds / entry.DurationSeconds * 100
+		}
+		items = append(items, models.WatchProgressWithMovie{
+			WatchProgress:   entry,
+			ProgressPercent: percent,
+			Movie:           &movie,
+		})
+	}
+	return items, nil
+}
+
+type updateProgressRequest struct {
+	PositionSeconds float64 `json:"position_seconds" validate:"min=0"`
+	DurationSeconds float64 `json:"duration_seconds" validate:"gt=0"`
+}
+
+// UpdateProgress stores the playback position for a movie and marks it finished past the threshold
+func UpdateProgress(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		imdbID := c.Param("imdb_id")
+		if imdbID == "" {
+			apierror.Respond(c, apierror.BadRequest("Movie ID is required"))
+			return
+		}
+
+		var req updateProgressRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		if req.PositionSeconds > req.DurationSeconds {
+			req.PositionSeconds = req.DurationSeconds
+		}
+
+		// Verify movie exists
+		movieCollection := database.OpenCollection("movies", client)
+		count, err := movieCollection.CountDocuments(ctx, bson.D{{Key: "imdb_id", Value: imdbID}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to verify movie"))
+			return
+		}
+		if count == 0 {
+			apierror.Respond(c, apierror.NotFound("Movie not found"))
+			return
+		}
+
+		now := time.Now()
+		profileID := utils.GetProfileIdFromContext(c)
+		finished := req.PositionSeconds/req.DurationSeconds >= watchFinishedThreshold()
+
+		setFields := bson.M{
+			"position_seconds": req.PositionSeconds,
+			"duration_seconds": req.DurationSeconds,
+			"last_watched_at":  now,
+		}
+		setOnInsert := bson.M{
+			"user_id":    userID,
+			"imdb_id":    imdbID,
+			"created_at": now,
+		}
+		if profileID != "" {
+			setOnInsert["profile_id"] = profileID
+		}
+
+		// Re-watching a finished title from the start moves it back into continue-watching
+		if finished {
+			setFields["finished"] = true
+			setFields["finished_at"] = now
+		} else {
+			setFields["finished"] = false
+		}
+
+		filter := append(profileScopedFilter(userID, profileID), bson.E{Key: "imdb_id", Value: imdbID})
+		update := bson.M{
+			"$set":         setFields,
+			"$setOnInsert": setOnInsert,
+		}
+
+		progressCollection := database.OpenCollection("watch_progress", client)
+		var progress models.WatchProgress
+		err = progressCollection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&progress)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to save progress"))
+			return
+		}
+
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
+// GetContinueWatching returns partially watched titles, most recently watched first
+func GetContinueWatching(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		var limit int64 = 20
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if parsed, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		filter := append(profileScopedFilter(userID, utils.GetProfileIdFromContext(c)),
+			bson.E{Key: "finished", Value: false},
+			bson.E{Key: "position_seconds", Value: bson.D{{Key: "$gt", Value: 0}}},
+		)
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "last_watched_at", Value: -1}}).
+			SetLimit(limit)
+
+		cursor, err := database.OpenCollection("watch_progress", client).Find(ctx, filter, findOptions)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch progress"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var entries []models.WatchProgress
+		if err := cursor.All(ctx, &entries); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode progress"))
+			return
+		}
+
+		items, err := attachMovies(ctx, client, c, entries)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch movies"))
+			return
+		}
+
+		c.JSON(http.StatusOK, items)
+	}
+}
+
+// GetMyWatchHistory returns every title the viewer has started, with a finished flag
+func GetMyWatchHistory(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		var limit int64 = 50
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if parsed, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		if limit > 500 {
+			limit = 500
+		}
+
+		finishedOnly := c.Query("finished") == "true"
+
+		history, err := GetWatchHistory(ctx, client, userID, utils.GetProfileIdFromContext(c), finishedOnly, limit)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch watch history"))
+			return
+		}
+
+		items, err := attachMovies(ctx, client, c, history)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch movies"))
+			return
+		}
+
+		c.JSON(http.StatusOK, items)
+	}
+}
@@ -3,17 +3,47 @@ package controllers
 import (
 	"context"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/metrics"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+// reviewReportThreshold returns how many users must report a visible review to send it back to the queue.
+// Configured via REVIEW_REPORT_THRESHOLD (default 3).
+func reviewReportThreshold() int {
+	if v := os.Getenv("REVIEW_REPORT_THRESHOLD"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return 3
+}
+
+type upsertRatingRequest struct {
+	Rating     int    `json:"rating" validate:"required,min=1,max=5"`
+	ReviewText string `json:"review_text,omitempty"`
+}
+
+type upsertRatingResponse struct {
+	Message    string `json:"message"`
+	Rating     int    `json:"rating"`
+	ReviewText string `json:"review_text"`
+	// pending while the review awaits moderation
+	ModerationStatus string `json:"moderation_status"`
+}
+
 // UpsertRating creates or updates user's rating for a movie
 func UpsertRating(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -22,13 +52,13 @@ func UpsertRating(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
 		imdbID := c.Param("imdb_id")
 		if imdbID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+			apierror.Respond(c, apierror.BadRequest("Movie ID is required"))
 			return
 		}
 
@@ -38,66 +68,109 @@ func UpsertRating(client *mongo.Client) gin.HandlerFunc {
 		err = movieCollection.FindOne(ctx, bson.D{{Key: "imdb_id", Value: imdbID}}).Decode(&movie)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+				apierror.Respond(c, apierror.NotFound("Movie not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify movie"})
+			apierror.Respond(c, apierror.Internal("Failed to verify movie"))
 			return
 		}
 
-		var req struct {
-			Rating     int    `json:"rating" validate:"required,min=1,max=5"`
-			ReviewText string `json:"review_text,omitempty"`
-		}
+		var req upsertRatingRequest
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
-		ratingCollection := database.OpenCollection("ratings", client)
-		filter := bson.D{
-			{Key: "user_id", Value: userID},
-			{Key: "imdb_id", Value: imdbID},
+		if err := validate.Struct(req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
 		}
 
+		profileID := utils.GetProfileIdFromContext(c)
+		ratingCollection := database.OpenCollection("ratings", client)
+		filter := append(profileScopedFilter(userID, profileID), bson.E{Key: "imdb_id", Value: imdbID})
+
 		// Check if rating exists
 		var existingRating models.Rating
 		err = ratingCollection.FindOne(ctx, filter).Decode(&existingRating)
 		
+		// Suspicious reviews are held for moderation instead of being published
+		moderationStatus := models.ReviewStatusVisible
+		moderationReason := utils.CheckReviewText(req.ReviewText)
+		if moderationReason != "" {
+			moderationStatus = models.ReviewStatusPending
+		}
+
 		now := time.Now()
 		if err == nil {
 			// Update existing
-			update := bson.M{
-				"$set": bson.M{
-					"rating":      req.Rating,
-					"review_text": req.ReviewText,
-					"updated_at":  now,
-				},
+			setFields := bson.M{
+				"rating":      req.Rating,
+				"review_text": req.ReviewText,
+				"updated_at":  now,
+			}
+			update := bson.M{"$set": setFields}
+
+			if existingRating.ReviewText == req.ReviewText {
+				// Unchanged text keeps its moderation decision
+				moderationStatus = existingRating.ModerationStatus
+				if moderationStatus == "" {
+					moderationStatus = models.ReviewStatusVisible
+				}
+			} else {
+				// New text is moderated from scratch; reports against the old text no longer apply
+				setFields["moderation_status"] = moderationStatus
+				setFields["moderation_reason"] = moderationReason
+				update["$unset"] = bson.M{"report_count": "", "moderated_by": "", "moderated_at": ""}
+				if _, err := database.OpenCollection("review_reports", client).DeleteMany(ctx, bson.D{{Key: "rating_id", Value: existingRating.ID}}); err != nil {
+					utils.RequestLogger(c).Warn("Failed to clear reports of edited review", "rating_id", existingRating.ID.Hex(), "error", err)
+				}
 			}
 			_, err = ratingCollection.UpdateOne(ctx, filter, update)
 		} else if err == mongo.ErrNoDocuments {
 			// Insert new
 			rating := models.Rating{
-				UserID:     userID,
-				ImdbID:     imdbID,
-				Rating:     req.Rating,
-				ReviewText: req.ReviewText,
-				CreatedAt:  now,
-				UpdatedAt:  now,
+				UserID:           userID,
+				ProfileID:        profileID,
+				ImdbID:           imdbID,
+				Rating:           req.Rating,
+				ReviewText:       req.ReviewText,
+				CreatedAt:        now,
+				UpdatedAt:        now,
+				ModerationStatus: moderationStatus,
+				ModerationReason: moderationReason,
 			}
 			_, err = ratingCollection.InsertOne(ctx, rating)
 		}
 		
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rating"})
+			apierror.Respond(c, apierror.Internal("Failed to save rating"))
 			return
 		}
+		if existingRating.ID != (bson.ObjectID{}) {
+			metrics.RatingsSubmitted.WithLabelValues("updated").Inc()
+		} else {
+			metrics.RatingsSubmitted.WithLabelValues("new").Inc()
+		}
+
+		// Keep the movie's materialized aggregates in step with this rating
+		removeStar, addStar := 0, 0
+		if existingRating.ID != (bson.ObjectID{}) && workers.RatingCounted(existingRating.ModerationStatus) {
+			removeStar = existingRating.Rating
+		}
+		if workers.RatingCounted(moderationStatus) {
+			addStar = req.Rating
+		}
+		if err := workers.ApplyRatingDelta(ctx, client, imdbID, removeStar, addStar); err != nil {
+			utils.RequestLogger(c).Warn("Failed to update rating aggregates", "imdb_id", imdbID, "error", err)
+		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":    "Rating saved successfully",
-			"rating":     req.Rating,
-			"review_text": req.ReviewText,
+		c.JSON(http.StatusOK, upsertRatingResponse{
+			Message:          "Rating saved successfully",
+			Rating:           req.Rating,
+			ReviewText:       req.ReviewText,
+			ModerationStatus: moderationStatus,
 		})
 	}
 }
@@ -110,44 +183,24 @@ func GetMovieRatings(client *mongo.Client) gin.HandlerFunc {
 
 		imdbID := c.Param("imdb_id")
 		if imdbID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+			apierror.Respond(c, apierror.BadRequest("Movie ID is required"))
 			return
 		}
 
 		ratingCollection := database.OpenCollection("ratings", client)
-		filter := bson.D{{Key: "imdb_id", Value: imdbID}}
 
-		// Get aggregate stats
-		pipeline := []bson.M{
-			{"$match": bson.M{"imdb_id": imdbID}},
-			{"$group": bson.M{
-				"_id":   nil,
-				"avg":   bson.M{"$avg": "$rating"},
-				"count": bson.M{"$sum": 1},
-			}},
+		// Only approved reviews are published; pending and hidden ones stay private
+		filter := bson.D{
+			{Key: "imdb_id", Value: imdbID},
+			{Key: "moderation_status", Value: bson.D{{Key: "$nin", Value: models.UnpublishedReviewStatuses}}},
 		}
 
-		cursor, err := ratingCollection.Aggregate(ctx, pipeline)
+		// Aggregates are materialized on the movie document
+		avg, count, _, err := movieRatingAggregates(ctx, client, imdbID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate ratings"})
+			apierror.Respond(c, apierror.Internal("Failed to aggregate ratings"))
 			return
 		}
-		defer cursor.Close(ctx)
-
-		var avg float64
-		var count int64
-
-		if cursor.Next(ctx) {
-			var result bson.M
-			if err = cursor.Decode(&result); err == nil {
-				if avgVal, ok := result["avg"].(float64); ok {
-					avg = avgVal
-				}
-				if countVal, ok := result["count"].(int64); ok {
-					count = countVal
-				}
-			}
-		}
 
 		// Get recent reviews (last 10, sorted by created_at desc)
 		findOptions := options.Find().
@@ -156,20 +209,24 @@ func GetMovieRatings(client *mongo.Client) gin.HandlerFunc {
 
 		reviewCursor, err := ratingCollection.Find(ctx, filter, findOptions)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch reviews"))
 			return
 		}
 		defer reviewCursor.Close(ctx)
 
 		var recent []models.Rating
 		if err = reviewCursor.All(ctx, &recent); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode reviews"})
+			apierror.Respond(c, apierror.Internal("Failed to decode reviews"))
 			return
 		}
 
 		// Remove user_id from response for privacy (or keep it if you want to show usernames)
 		for i := range recent {
 			recent[i].UserID = "" // Clear user_id for privacy
+			recent[i].ProfileID = ""
+			recent[i].ModerationStatus = ""
+			recent[i].ModerationReason = ""
+			recent[i].ReportCount = 0
 		}
 
 		response := models.RatingAggregate{
@@ -190,7 +247,7 @@ func GetUserRatings(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
@@ -198,7 +255,7 @@ func GetUserRatings(client *mongo.Client) gin.HandlerFunc {
 
 		// Aggregation pipeline to join with movies collection
 		pipeline := []bson.M{
-			{"$match": bson.M{"user_id": userID}},
+			{"$match": profileScopedFilter(userID, utils.GetProfileIdFromContext(c))},
 			{"$sort": bson.M{"updated_at": -1}},
 			{"$lookup": bson.M{
 				"from":         "movies",
@@ -211,28 +268,30 @@ func GetUserRatings(client *mongo.Client) gin.HandlerFunc {
 				"preserveNullAndEmptyArrays": true,
 			}},
 			{"$project": bson.M{
-				"_id":          1,
-				"user_id":      1,
-				"imdb_id":      1,
-				"rating":       1,
-				"review_text":  1,
-				"created_at":   1,
-				"updated_at":   1,
-				"movie_title":  "$movie.title",
-				"movie_poster": "$movie.poster_path",
+				"_id":               1,
+				"user_id":           1,
+				"profile_id":        1,
+				"imdb_id":           1,
+				"rating":            1,
+				"review_text":       1,
+				"moderation_status": 1,
+				"created_at":        1,
+				"updated_at":        1,
+				"movie_title":       "$movie.title",
+				"movie_poster":      "$movie.poster_path",
 			}},
 		}
 
 		cursor, err := ratingCollection.Aggregate(ctx, pipeline)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ratings"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch ratings"))
 			return
 		}
 		defer cursor.Close(ctx)
 
 		var results []bson.M
 		if err = cursor.All(ctx, &results); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode ratings"})
+			apierror.Respond(c, apierror.Internal("Failed to decode ratings"))
 			return
 		}
 
@@ -253,34 +312,148 @@ func DeleteRating(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
 		imdbID := c.Param("imdb_id")
 		if imdbID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Movie ID is required"})
+			apierror.Respond(c, apierror.BadRequest("Movie ID is required"))
 			return
 		}
 
 		ratingCollection := database.OpenCollection("ratings", client)
-		filter := bson.D{
-			{Key: "user_id", Value: userID},
-			{Key: "imdb_id", Value: imdbID},
+		filter := append(profileScopedFilter(userID, utils.GetProfileIdFromContext(c)), bson.E{Key: "imdb_id", Value: imdbID})
+
+		var deleted models.Rating
+		err = ratingCollection.FindOneAndDelete(ctx, filter).Decode(&deleted)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Rating not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to delete rating"))
+			return
+		}
+
+		for _, name := range []string{"review_reports", "review_votes"} {
+			if _, err := database.OpenCollection(name, client).DeleteMany(ctx, bson.D{{Key: "rating_id", Value: deleted.ID}}); err != nil {
+				utils.RequestLogger(c).Warn("Failed to clean up after deleted rating", "collection", name, "rating_id", deleted.ID.Hex(), "error", err)
+			}
 		}
 
-		result, err := ratingCollection.DeleteOne(ctx, filter)
+		if workers.RatingCounted(deleted.ModerationStatus) {
+			if err := workers.ApplyRatingDelta(ctx, client, imdbID, deleted.Rating, 0); err != nil {
+				utils.RequestLogger(c).Warn("Failed to update rating aggregates", "imdb_id", imdbID, "error", err)
+			}
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "Rating deleted successfully"})
+	}
+}
+
+
+type reportReviewRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// ReportReview lets a user flag someone else's review; enough reports send it to the moderation queue
+func ReportReview(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rating"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
-		if result.DeletedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Rating not found"})
+		ratingID, err := bson.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			apierror.Respond(c, apierror.BadRequest("Invalid rating id"))
+			return
+		}
+
+		var req reportReviewRequest
+		// The reason is optional, so an empty body is fine
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				apierror.Respond(c, apierror.Invalid(err))
+				return
+			}
+		}
+		if err := validate.Struct(req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Rating deleted successfully"})
+		ratingCollection := database.OpenCollection("ratings", client)
+		var rating models.Rating
+		if err := ratingCollection.FindOne(ctx, bson.D{{Key: "_id", Value: ratingID}}).Decode(&rating); err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Review not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch review"))
+			return
+		}
+
+		if rating.ReviewText == "" || rating.ModerationStatus == models.ReviewStatusHidden {
+			apierror.Respond(c, apierror.NotFound("Review not found"))
+			return
+		}
+		if rating.UserID == userID {
+			apierror.Respond(c, apierror.BadRequest("You cannot report your own review"))
+			return
+		}
+
+		report := models.ReviewReport{
+			RatingID:  ratingID,
+			UserID:    userID,
+			Reason:    strings.TrimSpace(req.Reason),
+			CreatedAt: time.Now(),
+		}
+		if _, err := database.OpenCollection("review_reports", client).InsertOne(ctx, report); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				apierror.Respond(c, apierror.Conflict("You have already reported this review"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to report review"))
+			return
+		}
+
+		// Count reporters rather than reports, so one user can't push a review over the threshold
+		reporters, err := database.OpenCollection("review_reports", client).Distinct(ctx, "user_id",
+			bson.D{{Key: "rating_id", Value: ratingID}}).Raw()
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to report review"))
+			return
+		}
+		reporterIDs, _ := reporters.Values()
+		reportCount := len(reporterIDs)
+
+		filter := bson.D{{Key: "_id", Value: ratingID}}
+		if _, err := ratingCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"report_count": reportCount}}); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to report review"))
+			return
+		}
+
+		// A published review goes back to the queue once enough users reported it, including one
+		// an admin already approved. Its stars keep counting until a moderator hides it.
+		if reportCount >= reviewReportThreshold() {
+			_, err := ratingCollection.UpdateOne(ctx, append(filter, bson.E{
+				Key: "moderation_status", Value: bson.D{{Key: "$nin", Value: models.UnpublishedReviewStatuses}},
+			}), bson.M{"$set": bson.M{
+				"moderation_status": models.ReviewStatusReported,
+				"moderation_reason": "reported",
+			}})
+			if err != nil {
+				// The report itself is stored; the next one retries the threshold
+				utils.RequestLogger(c).Error("Failed to queue reported review for moderation", "rating_id", ratingID.Hex(), "error", err)
+			}
+		}
+
+		c.JSON(http.StatusCreated, messageResponse{Message: "Review reported"})
 	}
 }
-
@@ -0,0 +1,688 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// reportsDir is where generated report files are written. Configured via REPORTS_DIR (default ./reports).
+func reportsDir() string {
+	if dir := strings.TrimSpace(os.Getenv("REPORTS_DIR")); dir != "" {
+		return dir
+	}
+	return "reports"
+}
+
+var reportContentTypes = map[string]string{
+	models.ReportFormatCSV: "text/csv",
+	models.ReportFormatPDF: "application/pdf",
+}
+
+// normalizeReportList lowercases, trims and de-duplicates section/format names
+func normalizeReportList(values []string) []string {
+	out := []string{}
+	seen := map[string]struct{}{}
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if _, dup := seen[v]; dup || v == "" {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	return out
+}
+
+// normalizeRecipients trims and de-duplicates recipient addresses
+func normalizeRecipients(values []string) []string {
+	out := []string{}
+	seen := map[string]struct{}{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		key := strings.ToLower(v)
+		if _, dup := seen[key]; dup || v == "" {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, v)
+	}
+	return out
+}
+
+// staffRecipients returns which of recipients are the verified email of an account whose role
+// can read analytics, so reports never leave the staff that could view them in the app
+func staffRecipients(ctx context.Context, client *mongo.Client, recipients []string) (map[string]bool, error) {
+	staff := map[string]bool{}
+	if len(recipients) == 0 {
+		return staff, nil
+	}
+
+	cursor, err := database.OpenCollection("users", client).Find(ctx,
+		bson.D{{Key: "email", Value: bson.D{{Key: "$in", Value: recipients}}}},
+		options.Find().SetProjection(bson.M{"email": 1, "email_verified": 1, "role": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	rolePermissions := map[string][]string{}
+	for _, user := range users {
+		if !user.EmailVerified {
+			continue
+		}
+		permissions, ok := rolePermissions[user.Role]
+		if !ok {
+			if permissions, err = utils.ResolvePermissions(ctx, client, user.Role); err != nil {
+				return nil, err
+			}
+			rolePermissions[user.Role] = permissions
+		}
+		if containsString(permissions, models.PermissionAnalyticsRead) {
+			staff[user.Email] = true
+		}
+	}
+	return staff, nil
+}
+
+// checkReportRecipients answers the request and returns false unless every recipient is staff
+func checkReportRecipients(ctx context.Context, client *mongo.Client, c *gin.Context, recipients []string) bool {
+	staff, err := staffRecipients(ctx, client, recipients)
+	if err != nil {
+		apierror.Respond(c, apierror.Internal("Failed to check recipients"))
+		return false
+	}
+	for _, recipient := range recipients {
+		if !staff[recipient] {
+			apierror.Respond(c, apierror.BadRequest("Recipients must be verified accounts that can read analytics").
+				WithDetail("recipient", recipient))
+			return false
+		}
+	}
+	return true
+}
+
+// buildReportDocument runs the analytics selected by the report for [start, end).
+// The stats section is an all-time snapshot taken at generation time.
+func buildReportDocument(ctx context.Context, client *mongo.Client, report models.ScheduledReport, start, end time.Time) (utils.ReportDocument, error) {
+	// Analytics loaders treat a date-only "to" as inclusive
+	fromStr := start.Format("2006-01-02")
+	toStr := end.AddDate(0, 0, -1).Format("2006-01-02")
+
+	doc := utils.ReportDocument{
+		Title: "MagicStream report: " + report.Name,
+		Summary: []string{
+			fmt.Sprintf("Period: %s to %s (UTC)", fromStr, toStr),
+			"Generated at: " + time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	count := func(v int64) string { return strconv.FormatInt(v, 10) }
+
+	for _, section := range models.AllReportSections {
+		if !containsString(report.Sections, section) {
+			continue
+		}
+
+		switch section {
+		case models.ReportSectionStats:
+			stats, err := loadAdminStats(ctx, client, true)
+			if err != nil {
+				return doc, err
+			}
+			doc.Tables = append(doc.Tables, utils.ReportTable{
+				Title:   "Overview (all time)",
+				Headers: []string{"metric", "value"},
+				Rows: [][]string{
+					{"total_movies", count(stats.TotalMovies)},
+					{"total_users", count(stats.TotalUsers)},
+					{"active_subscriptions", count(stats.ActiveSubscriptions)},
+					{"total_ratings", count(stats.TotalRatings)},
+					{"total_watchlist_items", count(stats.TotalWatchlistItems)},
+					{"revenue_" + strings.ToLower(stats.Revenue.Currency), money(stats.Revenue.Amount)},
+				},
+			})
+
+		case models.ReportSectionRevenue:
+			revenue, err := loadRevenueAnalytics(ctx, client, "day", fromStr, toStr)
+			if err != nil {
+				return doc, err
+			}
+			table := utils.ReportTable{Title: "Revenue (" + revenue.Currency + ")", Headers: []string{"period_start", "amount"}}
+			for _, p := range revenue.Series {
+				table.Rows = append(table.Rows, []string{p.PeriodStart, money(p.Amount)})
+			}
+			table.Rows = append(table.Rows, []string{"total", money(revenue.Total)})
+			doc.Tables = append(doc.Tables, table)
+
+		case models.ReportSectionSubscriptions:
+			trends, err := loadSubscriptionTrends(ctx, client, "day", fromStr, toStr)
+			if err != nil {
+				return doc, err
+			}
+			table := utils.ReportTable{Title: "Subscription trends", Headers: []string{"period_start", "new_subscriptions", "canceled_subscriptions"}}
+			for _, p := range trends.Series {
+				table.Rows = append(table.Rows, []string{p.PeriodStart, count(p.NewSubscriptions), count(p.CanceledSubscriptions)})
+			}
+			doc.Tables = append(doc.Tables, table)
+
+		case models.ReportSectionPlans:
+			plans, err := loadPopularPlans(ctx, client, fromStr, toStr, 10)
+			if err != nil {
+				return doc, err
+			}
+			table := utils.ReportTable{Title: "Popular plans", Headers: []string{"plan_id", "plan_name", "subscriptions", "revenue"}}
+			for _, p := range plans.Items {
+				table.Rows = append(table.Rows, []string{p.PlanID, p.PlanName, count(p.Subscriptions), money(p.Revenue)})
+			}
+			doc.Tables = append(doc.Tables, table)
+		}
+	}
+
+	return doc, nil
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+// generateReport renders every requested format, stores the files under reportsDir,
+// records them in generated_reports and emails them to the report's recipients
+func generateReport(ctx context.Context, client *mongo.Client, report models.ScheduledReport, start, end time.Time, trigger string) ([]models.GeneratedReport, error) {
+	doc, err := buildReportDocument(ctx, client, report, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := reportsDir()
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create reports dir: %w", err)
+	}
+
+	now := time.Now()
+	files := []models.GeneratedReport{}
+	attachments := []utils.MailAttachment{}
+
+	for _, format := range report.Formats {
+		var data []byte
+		switch format {
+		case models.ReportFormatCSV:
+			data, err = utils.RenderReportCSV(doc)
+			if err != nil {
+				return nil, fmt.Errorf("render csv: %w", err)
+			}
+		case models.ReportFormatPDF:
+			data = utils.RenderReportPDF(doc)
+		default:
+			continue
+		}
+
+		file := models.GeneratedReport{
+			ID:          bson.NewObjectID(),
+			ReportID:    report.ID,
+			ReportName:  report.Name,
+			Format:      format,
+			SizeBytes:   int64(len(data)),
+			PeriodStart: start,
+			PeriodEnd:   end,
+			Trigger:     trigger,
+			CreatedAt:   now,
+		}
+		file.FileName = fmt.Sprintf("%s_%s_%s.%s", report.ID.Hex(), start.Format("20060102"), file.ID.Hex(), format)
+
+		if err := os.WriteFile(filepath.Join(dir, file.FileName), data, 0o640); err != nil {
+			return nil, fmt.Errorf("write report file: %w", err)
+		}
+
+		files = append(files, file)
+		attachments = append(attachments, utils.MailAttachment{
+			FileName:    file.FileName,
+			ContentType: reportContentTypes[format],
+			Data:        data,
+		})
+	}
+
+	// Delivery failures don't discard the generated files; they stay downloadable
+	emailedTo := []string{}
+	if len(attachments) > 0 {
+		// Recipients were staff when they were added, but may have lost access since
+		staff, err := staffRecipients(ctx, client, report.Recipients)
+		if err != nil {
+			return nil, fmt.Errorf("check recipients: %w", err)
+		}
+		subject := fmt.Sprintf("MagicStream %s report: %s", report.Frequency, report.Name)
+		body := strings.Join(append([]string{doc.Title}, doc.Summary...), "\n")
+		for _, to := range report.Recipients {
+			if !staff[to] {
+				slog.Warn("Skipped report recipient that is no longer staff", "report_id", report.ID.Hex(), "recipient", to)
+				continue
+			}
+			if err := utils.SendMailWithAttachments(to, subject, body, attachments); err != nil {
+				slog.Warn("Failed to email report", "report_id", report.ID.Hex(), "recipient", to, "error", err)
+				continue
+			}
+			emailedTo = append(emailedTo, to)
+		}
+	}
+
+	generatedCollection := database.OpenCollection("generated_reports", client)
+	for i := range files {
+		files[i].EmailedTo = emailedTo
+		if _, err := generatedCollection.InsertOne(ctx, files[i]); err != nil {
+			return nil, fmt.Errorf("record generated report: %w", err)
+		}
+	}
+
+	return files, nil
+}
+
+// RunScheduledReport generates a report for the scheduler; it matches workers.ReportRunner
+func RunScheduledReport(ctx context.Context, client *mongo.Client, report models.ScheduledReport, start, end time.Time) error {
+	_, err := generateReport(ctx, client, report, start, end, "schedule")
+	return err
+}
+
+// findScheduledReport loads a report definition by the :id path param, writing the error response on failure
+func findScheduledReport(ctx context.Context, client *mongo.Client, c *gin.Context) (models.ScheduledReport, bool) {
+	var report models.ScheduledReport
+
+	reportID, err := bson.ObjectIDFromHex(strings.TrimSpace(c.Param("id")))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid report id"))
+		return report, false
+	}
+
+	err = database.OpenCollection("scheduled_reports", client).FindOne(ctx, bson.D{{Key: "_id", Value: reportID}}).Decode(&report)
+	if err == mongo.ErrNoDocuments {
+		apierror.Respond(c, apierror.NotFound("Report not found"))
+		return report, false
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Internal("Failed to fetch report"))
+		return report, false
+	}
+
+	return report, true
+}
+
+type reportList struct {
+	Reports []models.ScheduledReport `json:"reports"`
+	// Sections a report can include
+	Sections []string `json:"sections"`
+}
+
+// AdminListReports lists scheduled report definitions.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminListReports(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		cursor, err := database.OpenCollection("scheduled_reports", client).Find(ctx, bson.D{},
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch reports"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		reports := []models.ScheduledReport{}
+		if err := cursor.All(ctx, &reports); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode reports"))
+			return
+		}
+
+		c.JSON(http.StatusOK, reportList{Reports: reports, Sections: models.AllReportSections})
+	}
+}
+
+type adminCreateReportRequest struct {
+	Name       string   `json:"name"`
+	Frequency  string   `json:"frequency"`
+	Sections   []string `json:"sections"`
+	Formats    []string `json:"formats"`
+	Recipients []string `json:"recipients"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// AdminCreateReport defines a new scheduled report. The first run happens when the current period closes.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminCreateReport(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		adminUserID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		var req adminCreateReportRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		now := time.Now()
+		report := models.ScheduledReport{
+			Name:       strings.TrimSpace(req.Name),
+			Frequency:  strings.ToLower(strings.TrimSpace(req.Frequency)),
+			Sections:   normalizeReportList(req.Sections),
+			Formats:    normalizeReportList(req.Formats),
+			Recipients: normalizeRecipients(req.Recipients),
+			Enabled:    req.Enabled == nil || *req.Enabled,
+			CreatedBy:  adminUserID,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if len(report.Sections) == 0 {
+			report.Sections = append([]string{}, models.AllReportSections...)
+		}
+		if len(report.Formats) == 0 {
+			report.Formats = []string{models.ReportFormatCSV}
+		}
+
+		if err := validate.Struct(report); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+		if !checkReportRecipients(ctx, client, c, report.Recipients) {
+			return
+		}
+		report.NextRunAt = workers.NextReportRun(report.Frequency, now)
+
+		result, err := database.OpenCollection("scheduled_reports", client).InsertOne(ctx, report)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to create report"))
+			return
+		}
+		report.ID = result.InsertedID.(bson.ObjectID)
+
+		if !recordAudit(ctx, client, c, "report.create", "report", report.ID.Hex(), nil, report) {
+			return
+		}
+
+		c.JSON(http.StatusCreated, report)
+	}
+}
+
+type adminUpdateReportRequest struct {
+	Name       *string   `json:"name"`
+	Frequency  *string   `json:"frequency"`
+	Sections   *[]string `json:"sections"`
+	Formats    *[]string `json:"formats"`
+	Recipients *[]string `json:"recipients"`
+	Enabled    *bool     `json:"enabled"`
+}
+
+// AdminUpdateReport edits a scheduled report. Changing the frequency or re-enabling
+// the report reschedules it from the current period.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminUpdateReport(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		before, ok := findScheduledReport(ctx, client, c)
+		if !ok {
+			return
+		}
+
+		var req adminUpdateReportRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		now := time.Now()
+		report := before
+		if req.Name != nil {
+			report.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.Frequency != nil {
+			report.Frequency = strings.ToLower(strings.TrimSpace(*req.Frequency))
+		}
+		if req.Sections != nil {
+			report.Sections = normalizeReportList(*req.Sections)
+		}
+		if req.Formats != nil {
+			report.Formats = normalizeReportList(*req.Formats)
+		}
+		if req.Recipients != nil {
+			report.Recipients = normalizeRecipients(*req.Recipients)
+		}
+		if req.Enabled != nil {
+			report.Enabled = *req.Enabled
+		}
+		report.UpdatedAt = now
+
+		if err := validate.Struct(report); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+		if req.Recipients != nil && !checkReportRecipients(ctx, client, c, report.Recipients) {
+			return
+		}
+
+		// Don't backfill periods that elapsed while the report was paused
+		if report.Frequency != before.Frequency || (report.Enabled && !before.Enabled) {
+			report.NextRunAt = workers.NextReportRun(report.Frequency, now)
+		}
+
+		_, err := database.OpenCollection("scheduled_reports", client).UpdateOne(ctx, bson.D{{Key: "_id", Value: report.ID}}, bson.M{"$set": bson.M{
+			"name":        report.Name,
+			"frequency":   report.Frequency,
+			"sections":    report.Sections,
+			"formats":     report.Formats,
+			"recipients":  report.Recipients,
+			"enabled":     report.Enabled,
+			"next_run_at": report.NextRunAt,
+			"updated_at":  report.UpdatedAt,
+		}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to update report"))
+			return
+		}
+
+		if !recordAudit(ctx, client, c, "report.update", "report", report.ID.Hex(), before, report) {
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// AdminDeleteReport removes a report definition. Files it already generated stay downloadable.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminDeleteReport(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		report, ok := findScheduledReport(ctx, client, c)
+		if !ok {
+			return
+		}
+
+		if _, err := database.OpenCollection("scheduled_reports", client).DeleteOne(ctx, bson.D{{Key: "_id", Value: report.ID}}); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to delete report"))
+			return
+		}
+
+		if !recordAudit(ctx, client, c, "report.delete", "report", report.ID.Hex(), report, nil) {
+			return
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "Report deleted"})
+	}
+}
+
+type reportRunResponse struct {
+	Files []models.GeneratedReport `json:"files"`
+}
+
+// AdminRunReport generates a report immediately for its last complete period, without
+// affecting the schedule.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminRunReport(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 2*time.Minute)
+		defer cancel()
+
+		report, ok := findScheduledReport(ctx, client, c)
+		if !ok {
+			return
+		}
+
+		start, end := workers.LastCompleteReportPeriod(report.Frequency, time.Now())
+		files, err := generateReport(ctx, client, report, start, end, "manual")
+		if err != nil {
+			utils.RequestLogger(c).Warn("Manual report run failed", "report_id", report.ID.Hex(), "error", err)
+			apierror.Respond(c, apierror.Internal("Failed to generate report"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, reportRunResponse{Files: files})
+	}
+}
+
+type generatedReportList struct {
+	Items []models.GeneratedReport `json:"items"`
+	pageInfo
+}
+
+// AdminListReportFiles lists files generated for a report, newest first.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminListReportFiles(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		reportID, err := bson.ObjectIDFromHex(strings.TrimSpace(c.Param("id")))
+		if err != nil {
+			apierror.Respond(c, apierror.BadRequest("Invalid report id"))
+			return
+		}
+
+		var limit int64 = 20
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if parsed, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		var page int64 = 1
+		if pageStr := c.Query("page"); pageStr != "" {
+			if parsed, err := strconv.ParseInt(pageStr, 10, 64); err == nil && parsed > 0 {
+				page = parsed
+			}
+		}
+
+		generatedCollection := database.OpenCollection("generated_reports", client)
+		filter := bson.D{{Key: "report_id", Value: reportID}}
+
+		total, err := generatedCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to count report files"))
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+
+		cursor, err := generatedCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch report files"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		items := []models.GeneratedReport{}
+		if err := cursor.All(ctx, &items); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode report files"))
+			return
+		}
+
+		totalPages := int64(0)
+		if total > 0 {
+			totalPages = (total + limit - 1) / limit
+		}
+
+		c.JSON(http.StatusOK, generatedReportList{Items: items, pageInfo: pageInfo{Page: page, Limit: limit, Total: total, TotalPages: totalPages}})
+	}
+}
+
+// AdminDownloadReportFile streams a generated report file as an attachment.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminDownloadReportFile(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		reportID, err := bson.ObjectIDFromHex(strings.TrimSpace(c.Param("id")))
+		if err != nil {
+			apierror.Respond(c, apierror.BadRequest("Invalid report id"))
+			return
+		}
+		fileID, err := bson.ObjectIDFromHex(strings.TrimSpace(c.Param("file_id")))
+		if err != nil {
+			apierror.Respond(c, apierror.BadRequest("Invalid file id"))
+			return
+		}
+
+		var file models.GeneratedReport
+		err = database.OpenCollection("generated_reports", client).FindOne(ctx, bson.D{
+			{Key: "_id", Value: fileID},
+			{Key: "report_id", Value: reportID},
+		}).Decode(&file)
+		if err == mongo.ErrNoDocuments {
+			apierror.Respond(c, apierror.NotFound("Report file not found"))
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch report file"))
+			return
+		}
+
+		// Stored names are generated server-side, but never let one escape the reports directory
+		path := filepath.Join(reportsDir(), filepath.Base(file.FileName))
+		if _, err := os.Stat(path); err != nil {
+			apierror.Respond(c, apierror.Gone("Report file is no longer available"))
+			return
+		}
+
+		c.Header("Content-Type", reportContentTypes[file.Format])
+		c.FileAttachment(path, file.FileName)
+	}
+}
@@ -0,0 +1,14 @@
+package controllers
+
+// messageResponse is the body of writes that have nothing to return but a confirmation
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// pageInfo is embedded in paginated list responses
+type pageInfo struct {
+	Page       int64 `json:"page"`
+	Limit      int64 `json:"limit"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"totalPages"`
+}
@@ -0,0 +1,415 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// reviewCursor marks the last review of a page; Key is the sort value (rating or helpful count)
+type reviewCursor struct {
+	Key       int    `json:"k"`
+	CreatedAt int64  `json:"t"`
+	ID        string `json:"id"`
+}
+
+func encodeReviewCursor(cur reviewCursor) string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeReviewCursor(s string) (reviewCursor, bson.ObjectID, error) {
+	var cur reviewCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, bson.ObjectID{}, err
+	}
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return cur, bson.ObjectID{}, err
+	}
+	oid, err := bson.ObjectIDFromHex(cur.ID)
+	return cur, oid, err
+}
+
+// reviewerDisplayName returns "First L." for a reviewer, or the profile name for secondary profiles
+func reviewerDisplayName(firstName, lastName, profileName string) string {
+	if profileName != "" {
+		return profileName
+	}
+	if firstName == "" {
+		return "Former member"
+	}
+	if lastName == "" {
+		return firstName
+	}
+	return firstName + " " + strings.ToUpper(string([]rune(lastName)[:1])) + "."
+}
+
+// movieRatingAggregates returns the materialized average, total and per-star counts of a movie's ratings
+func movieRatingAggregates(ctx context.Context, client *mongo.Client, imdbID string) (float64, int64, map[string]int64, error) {
+	var movie models.Movie
+	projection := bson.M{"avg_rating": 1, "rating_count": 1, "rating_histogram": 1}
+	err := database.OpenCollection("movies", client).FindOne(ctx, bson.D{{Key: "imdb_id", Value: imdbID}},
+		options.FindOne().SetProjection(projection)).Decode(&movie)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, 0, nil, err
+	}
+
+	histogram := map[string]int64{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}
+	for star, count := range movie.RatingHistogram {
+		if _, ok := histogram[star]; ok {
+			histogram[star] = count
+		}
+	}
+	return movie.AvgRating, movie.RatingCount, histogram, nil
+}
+
+// publicReview is a published review without the reviewer's ids
+type publicReview struct {
+	ID           bson.ObjectID `json:"_id"`
+	Rating       int           `json:"rating"`
+	ReviewText   string        `json:"review_text"`
+	ReviewerName string        `json:"reviewer_name"`
+	HelpfulCount int           `json:"helpful_count"`
+	HelpfulByMe  bool          `json:"helpful_by_me"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+type movieReviewList struct {
+	Items []publicReview `json:"items"`
+	// Cursor for the next page, empty on the last one
+	NextCursor string  `json:"next_cursor"`
+	Sort       string  `json:"sort"`
+	Avg        float64 `json:"avg"`
+	Count      int64   `json:"count"`
+	// Number of ratings per star, keyed "1" to "5"
+	Histogram map[string]int64 `json:"histogram"`
+}
+
+// GetMovieReviews returns a page of published reviews for a movie with a rating histogram.
+// Sort with ?sort=newest|highest|lowest|helpful and page with the next_cursor of the previous response.
+func GetMovieReviews(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		imdbID := c.Param("imdb_id")
+		if imdbID == "" {
+			apierror.Respond(c, apierror.BadRequest("Movie ID is required"))
+			return
+		}
+
+		sortBy := strings.ToLower(strings.TrimSpace(c.DefaultQuery("sort", "newest")))
+		keyDirection := -1
+		var sortKeyExpr interface{}
+		switch sortBy {
+		case "newest":
+		case "highest":
+			sortKeyExpr = "$rating"
+		case "lowest":
+			sortKeyExpr = "$rating"
+			keyDirection = 1
+		case "helpful":
+			sortKeyExpr = bson.M{"$ifNull": []interface{}{"$helpful_count", 0}}
+		default:
+			apierror.Respond(c, apierror.BadRequest("Sort must be newest, highest, lowest or helpful"))
+			return
+		}
+
+		var limit int64 = 20
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if parsed, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		if limit > 50 {
+			limit = 50
+		}
+
+		// Only published reviews with text are listed
+		match := bson.M{
+			"imdb_id":           imdbID,
+			"review_text":       bson.M{"$nin": []interface{}{nil, ""}},
+			"moderation_status": bson.M{"$nin": models.UnpublishedReviewStatuses},
+		}
+
+		pipeline := []bson.M{{"$match": match}}
+		if sortKeyExpr != nil {
+			pipeline = append(pipeline, bson.M{"$addFields": bson.M{"sort_key": sortKeyExpr}})
+		}
+
+		if cursorStr := c.Query("cursor"); cursorStr != "" {
+			cur, oid, err := decodeReviewCursor(cursorStr)
+			if err != nil {
+				apierror.Respond(c, apierror.BadRequest("Invalid cursor"))
+				return
+			}
+			createdAt := time.UnixMilli(cur.CreatedAt)
+
+			// Keyset condition: strictly after the cursor in (sort_key, created_at desc, _id desc) order
+			afterCreated := []bson.M{
+				{"created_at": bson.M{"$lt": createdAt}},
+				{"created_at": createdAt, "_id": bson.M{"$lt": oid}},
+			}
+			var cursorMatch bson.M
+			if sortKeyExpr == nil {
+				cursorMatch = bson.M{"$or": afterCreated}
+			} else {
+				keyOp := "$lt"
+				if keyDirection == 1 {
+					keyOp = "$gt"
+				}
+				cursorMatch = bson.M{"$or": []bson.M{
+					{"sort_key": bson.M{keyOp: cur.Key}},
+					{"sort_key": cur.Key, "$or": afterCreated},
+				}}
+			}
+			pipeline = append(pipeline, bson.M{"$match": cursorMatch})
+		}
+
+		sortStage := bson.D{}
+		if sortKeyExpr != nil {
+			sortStage = append(sortStage, bson.E{Key: "sort_key", Value: keyDirection})
+		}
+		sortStage = append(sortStage, bson.E{Key: "created_at", Value: -1}, bson.E{Key: "_id", Value: -1})
+
+		pipeline = append(pipeline,
+			bson.M{"$sort": sortStage},
+			bson.M{"$limit": limit + 1},
+			bson.M{"$lookup": bson.M{
+				"from": "users",
+				"let":  bson.M{"uid": "$user_id"},
+				"pipeline": []bson.M{
+					{"$match": bson.M{"$expr": bson.M{"$eq": []interface{}{"$user_id", "$$uid"}}}},
+					{"$project": bson.M{"_id": 0, "first_name": 1, "last_name": 1}},
+				},
+				"as": "reviewer",
+			}},
+			bson.M{"$lookup": bson.M{
+				"from": "profiles",
+				"let":  bson.M{"pid": "$profile_id"},
+				"pipeline": []bson.M{
+					{"$match": bson.M{"$expr": bson.M{"$eq": []interface{}{"$profile_id", "$$pid"}}}},
+					{"$project": bson.M{"_id": 0, "name": 1}},
+				},
+				"as": "profile",
+			}},
+		)
+
+		cursor, err := database.OpenCollection("ratings", client).Aggregate(ctx, pipeline)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch reviews"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var rows []struct {
+			models.Rating `bson:",inline"`
+			SortKey       int `bson:"sort_key"`
+			Reviewer      []struct {
+				FirstName string `bson:"first_name"`
+				LastName  string `bson:"last_name"`
+			} `bson:"reviewer"`
+			Profile []struct {
+				Name string `bson:"name"`
+			} `bson:"profile"`
+		}
+		if err := cursor.All(ctx, &rows); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode reviews"))
+			return
+		}
+
+		nextCursor := ""
+		if int64(len(rows)) > limit {
+			rows = rows[:limit]
+			last := rows[len(rows)-1]
+			nextCursor = encodeReviewCursor(reviewCursor{
+				Key:       last.SortKey,
+				CreatedAt: last.CreatedAt.UnixMilli(),
+				ID:        last.ID.Hex(),
+			})
+		}
+
+		// Mark the reviews the signed-in viewer already voted helpful
+		votedByMe := map[bson.ObjectID]bool{}
+		if userID, err := utils.GetUserIdFromContext(c); err == nil && len(rows) > 0 {
+			ids := make([]bson.ObjectID, 0, len(rows))
+			for _, row := range rows {
+				ids = append(ids, row.ID)
+			}
+			voteCursor, err := database.OpenCollection("review_votes", client).Find(ctx, bson.D{
+				{Key: "user_id", Value: userID},
+				{Key: "rating_id", Value: bson.D{{Key: "$in", Value: ids}}},
+			})
+			if err == nil {
+				var votes []models.ReviewVote
+				if err := voteCursor.All(ctx, &votes); err == nil {
+					for _, v := range votes {
+						votedByMe[v.RatingID] = true
+					}
+				}
+				voteCursor.Close(ctx)
+			}
+		}
+
+		items := make([]publicReview, 0, len(rows))
+		for _, row := range rows {
+			firstName, lastName, profileName := "", "", ""
+			if len(row.Reviewer) > 0 {
+				firstName, lastName = row.Reviewer[0].FirstName, row.Reviewer[0].LastName
+			}
+			if len(row.Profile) > 0 {
+				profileName = row.Profile[0].Name
+			}
+			items = append(items, publicReview{
+				ID:           row.ID,
+				Rating:       row.Rating.Rating,
+				ReviewText:   row.ReviewText,
+				ReviewerName: reviewerDisplayName(firstName, lastName, profileName),
+				HelpfulCount: row.HelpfulCount,
+				HelpfulByMe:  votedByMe[row.ID],
+				CreatedAt:    row.CreatedAt,
+				UpdatedAt:    row.UpdatedAt,
+			})
+		}
+
+		avg, count, histogram, err := movieRatingAggregates(ctx, client, imdbID)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to aggregate ratings"))
+			return
+		}
+
+		c.JSON(http.StatusOK, movieReviewList{
+			Items:      items,
+			NextCursor: nextCursor,
+			Sort:       sortBy,
+			Avg:        avg,
+			Count:      count,
+			Histogram:  histogram,
+		})
+	}
+}
+
+// findVotableReview loads a published review that the current user may vote on
+func findVotableReview(ctx context.Context, client *mongo.Client, c *gin.Context) (string, bson.ObjectID, bool) {
+	userID, err := utils.GetUserIdFromContext(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrNotAuthenticated)
+		return "", bson.ObjectID{}, false
+	}
+
+	ratingID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid review id"))
+		return "", bson.ObjectID{}, false
+	}
+
+	var rating models.Rating
+	if err := database.OpenCollection("ratings", client).FindOne(ctx, bson.D{{Key: "_id", Value: ratingID}}).Decode(&rating); err != nil {
+		if err == mongo.ErrNoDocuments {
+			apierror.Respond(c, apierror.NotFound("Review not found"))
+			return "", bson.ObjectID{}, false
+		}
+		apierror.Respond(c, apierror.Internal("Failed to fetch review"))
+		return "", bson.ObjectID{}, false
+	}
+
+	if rating.ReviewText == "" || slices.Contains(models.UnpublishedReviewStatuses, rating.ModerationStatus) {
+		apierror.Respond(c, apierror.NotFound("Review not found"))
+		return "", bson.ObjectID{}, false
+	}
+	if rating.UserID == userID {
+		apierror.Respond(c, apierror.BadRequest("You cannot vote on your own review"))
+		return "", bson.ObjectID{}, false
+	}
+
+	return userID, ratingID, true
+}
+
+// VoteReviewHelpful records the current user's helpful vote on a review (one vote per user)
+func VoteReviewHelpful(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, ratingID, ok := findVotableReview(ctx, client, c)
+		if !ok {
+			return
+		}
+
+		vote := models.ReviewVote{
+			RatingID:  ratingID,
+			UserID:    userID,
+			CreatedAt: time.Now(),
+		}
+		if _, err := database.OpenCollection("review_votes", client).InsertOne(ctx, vote); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				apierror.Respond(c, apierror.Conflict("You have already voted on this review"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to save vote"))
+			return
+		}
+
+		_, err := database.OpenCollection("ratings", client).UpdateOne(ctx, bson.D{{Key: "_id", Value: ratingID}},
+			bson.M{"$inc": bson.M{"helpful_count": 1}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to save vote"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, messageResponse{Message: "Vote recorded"})
+	}
+}
+
+// RemoveReviewHelpfulVote withdraws the current user's helpful vote
+func RemoveReviewHelpfulVote(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userID, ratingID, ok := findVotableReview(ctx, client, c)
+		if !ok {
+			return
+		}
+
+		result, err := database.OpenCollection("review_votes", client).DeleteOne(ctx, bson.D{
+			{Key: "rating_id", Value: ratingID},
+			{Key: "user_id", Value: userID},
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to remove vote"))
+			return
+		}
+		if result.DeletedCount == 0 {
+			apierror.Respond(c, apierror.NotFound("Vote not found"))
+			return
+		}
+
+		_, err = database.OpenCollection("ratings", client).UpdateOne(ctx, bson.D{
+			{Key: "_id", Value: ratingID},
+			{Key: "helpful_count", Value: bson.D{{Key: "$gt", Value: 0}}},
+		}, bson.M{"$inc": bson.M{"helpful_count": -1}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to remove vote"))
+			return
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "Vote removed"})
+	}
+}
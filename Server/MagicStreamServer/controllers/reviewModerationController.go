@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type reviewQueueList struct {
+	Items []bson.M `json:"items"`
+	pageInfo
+}
+
+// AdminReviewQueue lists reviews awaiting moderation (or hidden/visible ones via ?status=),
+// most-reported first, with the movie title joined in.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminReviewQueue(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		status := strings.ToLower(strings.TrimSpace(c.DefaultQuery("status", models.ReviewStatusPending)))
+		match := bson.M{"review_text": bson.M{"$nin": []interface{}{nil, ""}}}
+		switch status {
+		case models.ReviewStatusPending:
+			// Reported reviews are back in the queue too
+			match["moderation_status"] = bson.M{"$in": []string{models.ReviewStatusPending, models.ReviewStatusReported}}
+		case models.ReviewStatusHidden:
+			match["moderation_status"] = status
+		case models.ReviewStatusVisible:
+			match["moderation_status"] = bson.M{"$nin": models.UnpublishedReviewStatuses}
+		default:
+			apierror.Respond(c, apierror.BadRequest("Status must be pending, hidden or visible"))
+			return
+		}
+
+		// Pagination defaults
+		var limit int64 = 20
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if parsed, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		var page int64 = 1
+		if pageStr := c.Query("page"); pageStr != "" {
+			if parsed, err := strconv.ParseInt(pageStr, 10, 64); err == nil && parsed > 0 {
+				page = parsed
+			}
+		}
+
+		ratingCollection := database.OpenCollection("ratings", client)
+
+		total, err := ratingCollection.CountDocuments(ctx, match)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to count reviews"))
+			return
+		}
+
+		pipeline := []bson.M{
+			{"$match": match},
+			{"$sort": bson.D{{Key: "report_count", Value: -1}, {Key: "updated_at", Value: -1}}},
+			{"$skip": (page - 1) * limit},
+			{"$limit": limit},
+			{"$lookup": bson.M{
+				"from":         "movies",
+				"localField":   "imdb_id",
+				"foreignField": "imdb_id",
+				"as":           "movie",
+			}},
+			{"$unwind": bson.M{
+				"path":                       "$movie",
+				"preserveNullAndEmptyArrays": true,
+			}},
+			{"$project": bson.M{
+				"_id":               1,
+				"user_id":           1,
+				"imdb_id":           1,
+				"rating":            1,
+				"review_text":       1,
+				"moderation_status": 1,
+				"moderation_reason": 1,
+				"report_count":      1,
+				"moderated_at":      1,
+				"created_at":        1,
+				"updated_at":        1,
+				"movie_title":       "$movie.title",
+			}},
+		}
+
+		cursor, err := ratingCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch reviews"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		items := []bson.M{}
+		if err := cursor.All(ctx, &items); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode reviews"))
+			return
+		}
+
+		totalPages := int64(0)
+		if total > 0 {
+			totalPages = (total + limit - 1) / limit
+		}
+
+		c.JSON(http.StatusOK, reviewQueueList{Items: items, pageInfo: pageInfo{Page: page, Limit: limit, Total: total, TotalPages: totalPages}})
+	}
+}
+
+type moderateReviewRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// moderateReview applies an admin decision to a review and clears its outstanding reports
+func moderateReview(client *mongo.Client, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		adminUserID, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		idStr := strings.TrimSpace(c.Param("id"))
+		ratingID, err := bson.ObjectIDFromHex(idStr)
+		if err != nil {
+			apierror.Respond(c, apierror.BadRequest("Invalid review id"))
+			return
+		}
+
+		var req moderateReviewRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				apierror.Respond(c, apierror.Invalid(err))
+				return
+			}
+		}
+		if err := validate.Struct(req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		ratingCollection := database.OpenCollection("ratings", client)
+		filter := bson.D{{Key: "_id", Value: ratingID}}
+
+		var before models.Rating
+		if err := ratingCollection.FindOne(ctx, filter).Decode(&before); err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Review not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to fetch review"))
+			return
+		}
+
+		now := time.Now()
+		setFields := bson.M{
+			"moderation_status": status,
+			"moderated_by":      adminUserID,
+			"moderated_at":      now,
+		}
+		update := bson.M{"$set": setFields, "$unset": bson.M{"report_count": ""}}
+		if reason := strings.TrimSpace(req.Reason); reason != "" {
+			setFields["moderation_reason"] = reason
+		} else if status == models.ReviewStatusVisible {
+			update["$unset"].(bson.M)["moderation_reason"] = ""
+		}
+
+		var after models.Rating
+		err = ratingCollection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&after)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to update review"))
+			return
+		}
+
+		database.OpenCollection("review_reports", client).DeleteMany(ctx, bson.D{{Key: "rating_id", Value: ratingID}})
+
+		// Approving a pending review adds it to the aggregates; hiding one changes them only when
+		// hidden ratings are excluded
+		wasCounted, isCounted := workers.RatingCounted(before.ModerationStatus), workers.RatingCounted(after.ModerationStatus)
+		if wasCounted != isCounted {
+			removeStar, addStar := 0, 0
+			if wasCounted {
+				removeStar = before.Rating
+			} else {
+				addStar = after.Rating
+			}
+			if err := workers.ApplyRatingDelta(ctx, client, after.ImdbID, removeStar, addStar); err != nil {
+				utils.RequestLogger(c).Warn("Failed to update rating aggregates", "imdb_id", after.ImdbID, "error", err)
+			}
+		}
+
+		action := "review.approve"
+		if status == models.ReviewStatusHidden {
+			action = "review.hide"
+		}
+		if !recordAudit(ctx, client, c, action, "rating", idStr,
+			gin.H{"moderation_status": before.ModerationStatus, "moderation_reason": before.ModerationReason, "report_count": before.ReportCount},
+			gin.H{"moderation_status": after.ModerationStatus, "moderation_reason": after.ModerationReason, "report_count": after.ReportCount}) {
+			return
+		}
+
+		c.JSON(http.StatusOK, after)
+	}
+}
+
+// AdminApproveReview publishes a pending or hidden review.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminApproveReview(client *mongo.Client) gin.HandlerFunc {
+	return moderateReview(client, models.ReviewStatusVisible)
+}
+
+// AdminHideReview removes a review from public responses; its score stays in aggregates
+// unless REVIEW_HIDDEN_IN_AGGREGATES=false.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminHideReview(client *mongo.Client) gin.HandlerFunc {
+	return moderateReview(client, models.ReviewStatusHidden)
+}
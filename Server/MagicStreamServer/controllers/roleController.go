@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Built-in roles that cannot be edited or deleted, so admins can't lock themselves out
+var lockedRoles = map[string]struct{}{"ADMIN": {}, "USER": {}}
+
+// missingPermission returns the first of permissions the current request does not carry, or "".
+// Admins can only hand out what they hold themselves, so users:manage alone can't grant ADMIN.
+func missingPermission(c *gin.Context, permissions []string) string {
+	for _, p := range permissions {
+		if !utils.HasPermission(c, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+func respondMissingPermission(c *gin.Context, permission string) {
+	apierror.Respond(c, apierror.Forbidden("You can only grant permissions you hold").
+		WithCode(apierror.CodeMissingPermission).
+		WithDetail("permission", permission))
+}
+
+type roleList struct {
+	Roles []models.Role `json:"roles"`
+	// Every permission a role can be granted
+	Permissions []string `json:"permissions"`
+}
+
+// AdminListRoles returns every role with its permissions, plus the list of known permissions.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminListRoles(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		cursor, err := database.OpenCollection("roles", client).Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to fetch roles"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		roles := []models.Role{}
+		if err := cursor.All(ctx, &roles); err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to decode roles"))
+			return
+		}
+
+		c.JSON(http.StatusOK, roleList{Roles: roles, Permissions: models.AllPermissions})
+	}
+}
+
+// removedPermissions returns the permissions in before that after no longer has
+func removedPermissions(before, after []string) []string {
+	kept := map[string]struct{}{}
+	for _, p := range after {
+		kept[p] = struct{}{}
+	}
+	var removed []string
+	for _, p := range before {
+		if _, ok := kept[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+	return removed
+}
+
+type adminUpsertRoleRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// AdminUpsertRole creates a role or replaces its permission set.
+// Users pick up added permissions on their next login or token refresh; when permissions are
+// removed, every holder of the role is signed out so their tokens stop carrying them.
+// Admins can only grant, and only edit roles made of, permissions they hold themselves.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminUpsertRole(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		name, ok := normalizeRole(c.Param("name"))
+		if !ok || name == "" {
+			apierror.Respond(c, apierror.BadRequest("Role name must be 2-50 characters of A-Z, 0-9 and _"))
+			return
+		}
+		if _, locked := lockedRoles[name]; locked {
+			apierror.Respond(c, apierror.Forbidden("Built-in role cannot be modified"))
+			return
+		}
+
+		var req adminUpsertRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.Invalid(err))
+			return
+		}
+
+		permissions := []string{}
+		seen := map[string]struct{}{}
+		for _, p := range req.Permissions {
+			p = strings.ToLower(strings.TrimSpace(p))
+			if !models.IsValidPermission(p) {
+				apierror.Respond(c, apierror.BadRequest("Unknown permission: "+p))
+				return
+			}
+			if _, dup := seen[p]; dup {
+				continue
+			}
+			seen[p] = struct{}{}
+			permissions = append(permissions, p)
+		}
+		if missing := missingPermission(c, permissions); missing != "" {
+			respondMissingPermission(c, missing)
+			return
+		}
+
+		roleCollection := database.OpenCollection("roles", client)
+		filter := bson.D{{Key: "name", Value: name}}
+
+		var before *models.Role
+		var existing models.Role
+		if err := roleCollection.FindOne(ctx, filter).Decode(&existing); err == nil {
+			before = &existing
+		} else if err != mongo.ErrNoDocuments {
+			apierror.Respond(c, apierror.Internal("Failed to fetch role"))
+			return
+		}
+		// Nor can they edit a role that holds more than they do
+		if before != nil {
+			if missing := missingPermission(c, before.Permissions); missing != "" {
+				respondMissingPermission(c, missing)
+				return
+			}
+		}
+
+		now := time.Now()
+		update := bson.M{
+			"$set": bson.M{
+				"description": strings.TrimSpace(req.Description),
+				"permissions": permissions,
+				"updated_at":  now,
+			},
+			"$setOnInsert": bson.M{
+				"name":       name,
+				"built_in":   false,
+				"created_at": now,
+			},
+		}
+
+		var role models.Role
+		err := roleCollection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&role)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to save role"))
+			return
+		}
+
+		if before != nil && len(removedPermissions(before.Permissions, permissions)) > 0 {
+			if _, err := utils.RevokeSessions(ctx, client, bson.D{{Key: "role", Value: name}}); err != nil {
+				apierror.Respond(c, apierror.Internal("Role saved, but its users' sessions could not be signed out"))
+				return
+			}
+		}
+
+		action := "role.update"
+		status := http.StatusOK
+		if before == nil {
+			action = "role.create"
+			status = http.StatusCreated
+		}
+		if !recordAudit(ctx, client, c, action, "role", name, before, role) {
+			return
+		}
+
+		c.JSON(status, role)
+	}
+}
+
+// AdminDeleteRole removes a custom role that is not assigned to any user.
+// Admin-only route (protected by RequirePermission middleware).
+func AdminDeleteRole(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		name, ok := normalizeRole(c.Param("name"))
+		if !ok || name == "" {
+			apierror.Respond(c, apierror.BadRequest("Invalid role name"))
+			return
+		}
+		if _, locked := lockedRoles[name]; locked {
+			apierror.Respond(c, apierror.Forbidden("Built-in role cannot be deleted"))
+			return
+		}
+
+		assigned, err := database.OpenCollection("users", client).CountDocuments(ctx, bson.D{{Key: "role", Value: name}})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to check role usage"))
+			return
+		}
+		if assigned > 0 {
+			apierror.Respond(c, apierror.Conflict("Role is still assigned to users").WithDetail("users", assigned))
+			return
+		}
+
+		roleCollection := database.OpenCollection("roles", client)
+		var role models.Role
+		err = roleCollection.FindOneAndDelete(ctx, bson.D{{Key: "name", Value: name}}).Decode(&role)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.NotFound("Role not found"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to delete role"))
+			return
+		}
+
+		if !recordAudit(ctx, client, c, "role.delete", "role", name, role, nil) {
+			return
+		}
+
+		c.JSON(http.StatusOK, messageResponse{Message: "Role deleted"})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
@@ -24,18 +25,27 @@ func generateToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+type forgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// tokenIssuedResponse returns a simulated email token; token is empty for unknown addresses
+type tokenIssuedResponse struct {
+	Message   string     `json:"message"`
+	Token     string     `json:"token"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
 // ForgotPassword handles password reset request (SIMULATION - returns token in response)
 func ForgotPassword(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		var req struct {
-			Email string `json:"email" validate:"required,email"`
-		}
+		var req forgotPasswordRequest
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
@@ -46,20 +56,17 @@ func ForgotPassword(client *mongo.Client) gin.HandlerFunc {
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
 				// Don't reveal if email exists (security best practice)
-				c.JSON(http.StatusOK, gin.H{
-					"message": "If the email exists, a reset token has been generated",
-					"token":   "", // Empty for non-existent emails
-				})
+				c.JSON(http.StatusOK, tokenIssuedResponse{Message: "If the email exists, a reset token has been generated"})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+			apierror.Respond(c, apierror.Internal("Failed to process request"))
 			return
 		}
 
 		// Generate token
 		token, err := generateToken()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			apierror.Respond(c, apierror.Internal("Failed to generate token"))
 			return
 		}
 
@@ -75,32 +82,34 @@ func ForgotPassword(client *mongo.Client) gin.HandlerFunc {
 
 		_, err = passwordResetCollection.InsertOne(ctx, reset)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+			apierror.Respond(c, apierror.Internal("Failed to create reset token"))
 			return
 		}
 
 		// SIMULATION: Return token in response (in production, send via email)
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Password reset token generated (SIMULATION)",
-			"token":   token,
-			"expires_at": reset.ExpiresAt,
+		c.JSON(http.StatusOK, tokenIssuedResponse{
+			Message:   "Password reset token generated (SIMULATION)",
+			Token:     token,
+			ExpiresAt: &reset.ExpiresAt,
 		})
 	}
 }
 
+type resetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6"`
+}
+
 // ResetPassword validates token and updates password
 func ResetPassword(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		var req struct {
-			Token       string `json:"token" validate:"required"`
-			NewPassword string `json:"new_password" validate:"required,min=6"`
-		}
+		var req resetPasswordRequest
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
@@ -116,21 +125,21 @@ func ResetPassword(client *mongo.Client) gin.HandlerFunc {
 		err := passwordResetCollection.FindOne(ctx, filter).Decode(&reset)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+				apierror.Respond(c, apierror.BadRequest("Invalid or expired token"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+			apierror.Respond(c, apierror.Internal("Failed to validate token"))
 			return
 		}
 
 		// Hash new password
 		hashedPassword, err := utils.HashPassword(req.NewPassword)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			apierror.Respond(c, apierror.Internal("Failed to hash password"))
 			return
 		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			apierror.Respond(c, apierror.Internal("Failed to hash password"))
 			return
 		}
 
@@ -145,7 +154,7 @@ func ResetPassword(client *mongo.Client) gin.HandlerFunc {
 
 		_, err = userCollection.UpdateOne(ctx, bson.D{{Key: "user_id", Value: reset.UserID}}, update)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+			apierror.Respond(c, apierror.Internal("Failed to update password"))
 			return
 		}
 
@@ -155,7 +164,7 @@ func ResetPassword(client *mongo.Client) gin.HandlerFunc {
 			"$set": bson.M{"used_at": now},
 		})
 
-		c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+		c.JSON(http.StatusOK, messageResponse{Message: "Password reset successfully"})
 	}
 }
 
@@ -167,7 +176,7 @@ func RequestEmailVerification(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
@@ -176,19 +185,19 @@ func RequestEmailVerification(client *mongo.Client) gin.HandlerFunc {
 		var user models.User
 		err = userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: userID}}).Decode(&user)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			apierror.Respond(c, apierror.NotFound("User not found"))
 			return
 		}
 
 		if user.EmailVerified {
-			c.JSON(http.StatusOK, gin.H{"message": "Email already verified"})
+			c.JSON(http.StatusOK, messageResponse{Message: "Email already verified"})
 			return
 		}
 
 		// Generate token
 		token, err := generateToken()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			apierror.Respond(c, apierror.Internal("Failed to generate token"))
 			return
 		}
 
@@ -203,31 +212,33 @@ func RequestEmailVerification(client *mongo.Client) gin.HandlerFunc {
 
 		_, err = emailVerificationCollection.InsertOne(ctx, verification)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create verification token"})
+			apierror.Respond(c, apierror.Internal("Failed to create verification token"))
 			return
 		}
 
 		// SIMULATION: Return token in response
-		c.JSON(http.StatusOK, gin.H{
-			"message":    "Email verification token generated (SIMULATION)",
-			"token":      token,
-			"expires_at": verification.ExpiresAt,
+		c.JSON(http.StatusOK, tokenIssuedResponse{
+			Message:   "Email verification token generated (SIMULATION)",
+			Token:     token,
+			ExpiresAt: &verification.ExpiresAt,
 		})
 	}
 }
 
+type confirmEmailVerificationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
 // ConfirmEmailVerification validates token and marks email as verified
 func ConfirmEmailVerification(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		var req struct {
-			Token string `json:"token" validate:"required"`
-		}
+		var req confirmEmailVerificationRequest
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
@@ -243,10 +254,10 @@ func ConfirmEmailVerification(client *mongo.Client) gin.HandlerFunc {
 		err := emailVerificationCollection.FindOne(ctx, filter).Decode(&verification)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+				apierror.Respond(c, apierror.BadRequest("Invalid or expired token"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+			apierror.Respond(c, apierror.Internal("Failed to validate token"))
 			return
 		}
 
@@ -261,7 +272,7 @@ func ConfirmEmailVerification(client *mongo.Client) gin.HandlerFunc {
 
 		_, err = userCollection.UpdateOne(ctx, bson.D{{Key: "user_id", Value: verification.UserID}}, update)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+			apierror.Respond(c, apierror.Internal("Failed to verify email"))
 			return
 		}
 
@@ -271,7 +282,7 @@ func ConfirmEmailVerification(client *mongo.Client) gin.HandlerFunc {
 			"$set": bson.M{"used_at": now},
 		})
 
-		c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+		c.JSON(http.StatusOK, messageResponse{Message: "Email verified successfully"})
 	}
 }
 
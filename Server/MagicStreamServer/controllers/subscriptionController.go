@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/metrics"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
 	"github.com/gin-gonic/gin"
@@ -34,14 +36,14 @@ func GetPlans(client *mongo.Client) gin.HandlerFunc {
 
 		cursor, err := planCollection.Find(ctx, bson.D{}, findOptions)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch plans"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch plans"))
 			return
 		}
 		defer cursor.Close(ctx)
 
 		var plans []models.Plan
 		if err = cursor.All(ctx, &plans); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode plans"})
+			apierror.Respond(c, apierror.Internal("Failed to decode plans"))
 			return
 		}
 
@@ -54,6 +56,14 @@ func GetPlans(client *mongo.Client) gin.HandlerFunc {
 	}
 }
 
+type subscriptionResponse struct {
+	// Latest active or cancelled subscription, null when there is none
+	Subscription *models.SubscriptionWithPlan `json:"subscription"`
+	CanStream    bool                         `json:"can_stream"`
+	// The 10 most recent payments
+	Payments []models.Payment `json:"payments"`
+}
+
 // GetSubscription returns the current user's subscription details with plan info
 func GetSubscription(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -62,7 +72,7 @@ func GetSubscription(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
@@ -81,14 +91,10 @@ func GetSubscription(client *mongo.Client) gin.HandlerFunc {
 
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusOK, gin.H{
-					"subscription": nil,
-					"can_stream":   false,
-					"payments":     []models.Payment{},
-				})
+				c.JSON(http.StatusOK, subscriptionResponse{Payments: []models.Payment{}})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscription"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch subscription"))
 			return
 		}
 
@@ -109,20 +115,49 @@ func GetSubscription(client *mongo.Client) gin.HandlerFunc {
 			cursor.Close(ctx)
 		}
 
-		response := models.SubscriptionWithPlan{
-			Subscription: subscription,
-			Plan:         &plan,
-			CanStream:    canStream,
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"subscription": response,
-			"can_stream":   canStream,
-			"payments":     payments,
+		c.JSON(http.StatusOK, subscriptionResponse{
+			Subscription: &models.SubscriptionWithPlan{
+				Subscription: subscription,
+				Plan:         &plan,
+				CanStream:    canStream,
+			},
+			CanStream: canStream,
+			Payments:  payments,
 		})
 	}
 }
 
+type subscribeRequest struct {
+	PlanID        string `json:"plan_id" binding:"required"`
+	PaymentMethod string `json:"payment_method"` // "CARD" or "PAYPAL"
+	CardNumber    string `json:"card_number"`    // For simulation - last 4 digits stored
+}
+
+type subscribedPlan struct {
+	PlanID        string    `json:"plan_id"`
+	PlanName      string    `json:"plan_name"`
+	Status        string    `json:"status"`
+	StartedAt     time.Time `json:"started_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	NextBillingAt time.Time `json:"next_billing_at"`
+	AutoRenew     bool      `json:"auto_renew"`
+}
+
+type subscribePayment struct {
+	TransactionID string  `json:"transaction_id"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	Status        string  `json:"status"`
+	PaymentMethod string  `json:"payment_method"`
+}
+
+type subscribeResponse struct {
+	Message      string           `json:"message"`
+	Subscription subscribedPlan   `json:"subscription"`
+	Payment      subscribePayment `json:"payment"`
+	CanStream    bool             `json:"can_stream"`
+}
+
 // Subscribe creates or updates active subscription for current user with payment simulation
 func Subscribe(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -131,18 +166,14 @@ func Subscribe(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
-		var req struct {
-			PlanID        string `json:"plan_id" binding:"required"`
-			PaymentMethod string `json:"payment_method"` // "CARD" or "PAYPAL"
-			CardNumber    string `json:"card_number"`    // For simulation - last 4 digits stored
-		}
+		var req subscribeRequest
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
@@ -157,10 +188,10 @@ func Subscribe(client *mongo.Client) gin.HandlerFunc {
 		err = planCollection.FindOne(ctx, bson.D{{Key: "plan_id", Value: req.PlanID}}).Decode(&plan)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+				apierror.Respond(c, apierror.NotFound("Plan not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify plan"})
+			apierror.Respond(c, apierror.Internal("Failed to verify plan"))
 			return
 		}
 
@@ -189,9 +220,10 @@ func Subscribe(client *mongo.Client) gin.HandlerFunc {
 
 		paymentResult, err := paymentCollection.InsertOne(ctx, payment)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
+			apierror.Respond(c, apierror.Internal("Failed to create payment"))
 			return
 		}
+		metrics.Payments.WithLabelValues("PENDING").Inc()
 
 		// Simulate payment processing (instant success for simulation)
 		// In real world, this would be async with webhook
@@ -203,6 +235,7 @@ func Subscribe(client *mongo.Client) gin.HandlerFunc {
 				"updated_at": time.Now(),
 			},
 		})
+		metrics.Payments.WithLabelValues("SUCCESS").Inc()
 
 		// Cancel any existing active subscriptions
 		filter := bson.D{
@@ -211,8 +244,10 @@ func Subscribe(client *mongo.Client) gin.HandlerFunc {
 		}
 		update := bson.M{
 			"$set": bson.M{
-				"status":     "CANCELED",
-				"updated_at": time.Now(),
+				"status":        "CANCELED",
+				"canceled_at":   time.Now(),
+				"cancel_reason": models.CancelReasonReplaced,
+				"updated_at":    time.Now(),
 			},
 		}
 		subscriptionCollection.UpdateMany(ctx, filter, update)
@@ -237,9 +272,10 @@ func Subscribe(client *mongo.Client) gin.HandlerFunc {
 
 		subResult, err := subscriptionCollection.InsertOne(ctx, subscription)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subscription"})
+			apierror.Respond(c, apierror.Internal("Failed to create subscription"))
 			return
 		}
+		metrics.SubscriptionsCreated.WithLabelValues(req.PlanID).Inc()
 
 		// Update payment with subscription ID
 		paymentCollection.UpdateOne(ctx, bson.D{{Key: "_id", Value: paymentResult.InsertedID}}, bson.M{
@@ -248,29 +284,35 @@ func Subscribe(client *mongo.Client) gin.HandlerFunc {
 			},
 		})
 
-		c.JSON(http.StatusCreated, gin.H{
-			"message":        "Subscription activated successfully",
-			"subscription": gin.H{
-				"plan_id":         req.PlanID,
-				"plan_name":       plan.Name,
-				"status":          "ACTIVE",
-				"started_at":      now,
-				"expires_at":      expiresAt,
-				"next_billing_at": nextBillingAt,
-				"auto_renew":      true,
+		c.JSON(http.StatusCreated, subscribeResponse{
+			Message: "Subscription activated successfully",
+			Subscription: subscribedPlan{
+				PlanID:        req.PlanID,
+				PlanName:      plan.Name,
+				Status:        "ACTIVE",
+				StartedAt:     now,
+				ExpiresAt:     expiresAt,
+				NextBillingAt: nextBillingAt,
+				AutoRenew:     true,
 			},
-			"payment": gin.H{
-				"transaction_id": transactionID,
-				"amount":         plan.PriceMonthly,
-				"currency":       "USD",
-				"status":         "SUCCESS",
-				"payment_method": req.PaymentMethod,
+			Payment: subscribePayment{
+				TransactionID: transactionID,
+				Amount:        plan.PriceMonthly,
+				Currency:      "USD",
+				Status:        "SUCCESS",
+				PaymentMethod: req.PaymentMethod,
 			},
-			"can_stream": true,
+			CanStream: true,
 		})
 	}
 }
 
+type cancelSubscriptionResponse struct {
+	Message   string    `json:"message"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CanStream bool      `json:"can_stream"`
+}
+
 // CancelSubscription cancels auto-renewal for active subscription
 func CancelSubscription(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -279,7 +321,7 @@ func CancelSubscription(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
@@ -295,35 +337,37 @@ func CancelSubscription(client *mongo.Client) gin.HandlerFunc {
 		err = subscriptionCollection.FindOne(ctx, filter).Decode(&subscription)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"error": "No active subscription found"})
+				apierror.Respond(c, apierror.NotFound("No active subscription found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscription"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch subscription"))
 			return
 		}
 
 		// Update subscription - set auto_renew to false, keep ACTIVE until expiry
 		update := bson.M{
 			"$set": bson.M{
-				"auto_renew": false,
-				"status":     "CANCELED",
-				"updated_at": time.Now(),
+				"auto_renew":    false,
+				"status":        "CANCELED",
+				"canceled_at":   time.Now(),
+				"cancel_reason": models.CancelReasonUser,
+				"updated_at":    time.Now(),
 			},
 		}
 
 		_, err = subscriptionCollection.UpdateOne(ctx, bson.D{{Key: "_id", Value: subscription.ID}}, update)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel subscription"})
+			apierror.Respond(c, apierror.Internal("Failed to cancel subscription"))
 			return
 		}
 
 		// Can still stream until expiry
 		canStream := subscription.ExpiresAt.After(time.Now())
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":    "Subscription cancelled. You can continue streaming until " + subscription.ExpiresAt.Format("January 2, 2006"),
-			"expires_at": subscription.ExpiresAt,
-			"can_stream": canStream,
+		c.JSON(http.StatusOK, cancelSubscriptionResponse{
+			Message:   "Subscription cancelled. You can continue streaming until " + subscription.ExpiresAt.Format("January 2, 2006"),
+			ExpiresAt: subscription.ExpiresAt,
+			CanStream: canStream,
 		})
 	}
 }
@@ -336,7 +380,7 @@ func GetPaymentHistory(client *mongo.Client) gin.HandlerFunc {
 
 		userID, err := utils.GetUserIdFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
 			return
 		}
 
@@ -347,14 +391,14 @@ func GetPaymentHistory(client *mongo.Client) gin.HandlerFunc {
 
 		cursor, err := paymentCollection.Find(ctx, filter, findOptions)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+			apierror.Respond(c, apierror.Internal("Failed to fetch payments"))
 			return
 		}
 		defer cursor.Close(ctx)
 
 		var payments []models.Payment
 		if err = cursor.All(ctx, &payments); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode payments"})
+			apierror.Respond(c, apierror.Internal("Failed to decode payments"))
 			return
 		}
 
@@ -2,15 +2,16 @@ package controllers
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"io"
 	"net/http"
 	"time"
 
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"golang.org/x/crypto/bcrypt"
@@ -31,20 +32,19 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 		var user models.User
 
 		if err := c.ShouldBindJSON(&user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
-		validate := validator.New()
 
 		if err := validate.Struct(user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
 		hashedPassword, err := HashPassword(user.Password)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to hash password"})
+			apierror.Respond(c, apierror.Internal("Unable to hash password"))
 			return
 		}
 
@@ -56,13 +56,15 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 		count, err := userCollection.CountDocuments(ctx, bson.D{{Key: "email", Value: user.Email}})
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing user"})
+			apierror.Respond(c, apierror.Internal("Failed to check existing user"))
 			return
 		}
 		if count > 0 {
-			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			apierror.Respond(c, apierror.Conflict("User already exists").WithCode(apierror.CodeEmailInUse))
 			return
 		}
+		// Roles are granted by admins only; self-registration always creates a regular user
+		user.Role = "USER"
 		user.UserID = bson.NewObjectID().Hex()
 		user.CreatedAt = time.Now()
 		user.UpdatedAt = time.Now()
@@ -71,7 +73,7 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 		result, err := userCollection.InsertOne(ctx, user)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+			apierror.Respond(c, apierror.Internal("Failed to create user"))
 			return
 		}
 
@@ -81,12 +83,53 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 
 }
 
+// setAuthCookies stores the session tokens in the HttpOnly cookies browsers authenticate with
+func setAuthCookies(c *gin.Context, token, refreshToken string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:  "access_token",
+		Value: token,
+		Path:  "/",
+		// Domain:   "localhost",
+		MaxAge:   86400,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:  "refresh_token",
+		Value: refreshToken,
+		Path:  "/",
+		// Domain:   "localhost",
+		MaxAge:   604800,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+// reissueSession replaces the current session's tokens. Cookie sessions get new cookies; Bearer
+// clients get the tokens back to return in the response body.
+func reissueSession(c *gin.Context, client *mongo.Client, user models.User, profileId string, permissions []string, issuedAt time.Time) (string, string, error) {
+	token, refreshToken, err := utils.GenerateAllTokensAt(issuedAt, user.Email, user.FirstName, user.LastName, user.Role, user.UserID, profileId, permissions)
+	if err != nil {
+		return "", "", err
+	}
+	if err := utils.UpdateAllTokens(user.UserID, token, refreshToken, client); err != nil {
+		return "", "", err
+	}
+	if utils.UsesCookieAuth(c) {
+		setAuthCookies(c, token, refreshToken)
+		return "", "", nil
+	}
+	return token, refreshToken, nil
+}
+
 func LoginUser(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var userLogin models.UserLogin
 
 		if err := c.ShouldBindJSON(&userLogin); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalide input data"})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
@@ -98,85 +141,83 @@ func LoginUser(client *mongo.Client) gin.HandlerFunc {
 		var foundUser models.User
 		err := userCollection.FindOne(ctx, bson.D{{Key: "email", Value: userLogin.Email}}).Decode(&foundUser)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+			apierror.Respond(c, apierror.Unauthorized("Invalid email or password").WithCode(apierror.CodeInvalidCredentials))
 			return
 		}
 
 		err = bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(userLogin.Password))
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+			apierror.Respond(c, apierror.Unauthorized("Invalid email or password").WithCode(apierror.CodeInvalidCredentials))
 			return
 		}
 
-		token, refreshToken, err := utils.GenerateAllTokens(foundUser.Email, foundUser.FirstName, foundUser.LastName, foundUser.Role, foundUser.UserID)
+		if foundUser.Status == models.UserStatusSuspended {
+			apierror.Respond(c, apierror.Forbidden("Account suspended").WithCode(apierror.CodeAccountSuspended))
+			return
+		}
 
+		permissions, err := utils.ResolvePermissions(ctx, client, foundUser.Role)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+			apierror.Respond(c, apierror.Internal("Failed to load permissions"))
 			return
 		}
 
-		err = utils.UpdateAllTokens(foundUser.UserID, token, refreshToken, client)
+		token, refreshToken, err := utils.GenerateAllTokens(foundUser.Email, foundUser.FirstName, foundUser.LastName, foundUser.Role, foundUser.UserID, "", permissions)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tokens"})
+			apierror.Respond(c, apierror.Internal("Failed to generate tokens"))
 			return
 		}
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name:  "access_token",
-			Value: token,
-			Path:  "/",
-			// Domain:   "localhost",
-			MaxAge:   86400,
-			Secure:   true,
-			HttpOnly: true,
-			SameSite: http.SameSiteNoneMode,
-		})
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name:  "refresh_token",
-			Value: refreshToken,
-			Path:  "/",
-			// Domain:   "localhost",
-			MaxAge:   604800,
-			Secure:   true,
-			HttpOnly: true,
-			SameSite: http.SameSiteNoneMode,
-		})
 
-		c.JSON(http.StatusOK, models.UserResponse{
-			UserId:    foundUser.UserID,
-			FirstName: foundUser.FirstName,
-			LastName:  foundUser.LastName,
-			Email:     foundUser.Email,
-			Role:      foundUser.Role,
-			//Token:           token,
-			//RefreshToken:    refreshToken,
+		err = utils.UpdateAllTokens(foundUser.UserID, token, refreshToken, client)
+
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to update tokens"))
+			return
+		}
+		setAuthCookies(c, token, refreshToken)
+
+		response := models.UserResponse{
+			UserId:          foundUser.UserID,
+			FirstName:       foundUser.FirstName,
+			LastName:        foundUser.LastName,
+			Email:           foundUser.Email,
+			Role:            foundUser.Role,
 			FavouriteGenres: foundUser.FavouriteGenres,
-		})
+		}
+		// Browsers keep using the HttpOnly cookies; apps and CLIs ask for the tokens explicitly
+		if userLogin.ReturnTokens {
+			response.Token = token
+			response.RefreshToken = refreshToken
+		}
+		c.JSON(http.StatusOK, response)
 
 	}
 }
 
+type logoutRequest struct {
+	UserId string `json:"user_id"`
+}
+
 func LogoutHandler(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Clear the access_token cookie
 
-		var UserLogout struct {
-			UserId string `json:"user_id"`
-		}
+		var UserLogout logoutRequest
 
 		err := c.ShouldBindJSON(&UserLogout)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
-		fmt.Println("User ID from Logout request:", UserLogout.UserId)
+		utils.RequestLogger(c).Debug("Logout requested", "user_id", UserLogout.UserId)
 
 		err = utils.UpdateAllTokens(UserLogout.UserId, "", "", client) // Clear tokens in the database
 		// Optionally, you can also remove the user session from the database if needed
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error logging out"})
+			apierror.Respond(c, apierror.Internal("Error logging out"))
 			return
 		}
 		// c.SetCookie(
@@ -219,27 +260,49 @@ func LogoutHandler(client *mongo.Client) gin.HandlerFunc {
 			SameSite: http.SameSiteNoneMode,
 		})
 
-		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+		c.JSON(http.StatusOK, messageResponse{Message: "Logged out successfully"})
 	}
 }
 
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type refreshTokenResponse struct {
+	Message      string `json:"message"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// RefreshTokenHandler issues new tokens from the refresh_token cookie, or from a refresh token
+// in the body for Bearer clients, which then get the new tokens in the response
 func RefreshTokenHandler(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 
-		refreshToken, err := c.Cookie("refresh_token")
-
-		if err != nil {
-			fmt.Println("error", err.Error())
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unable to retrieve refresh token from cookie"})
+		// The body is optional; cookie clients send none
+		var req refreshTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			apierror.Respond(c, apierror.Invalid(err))
 			return
 		}
 
+		refreshToken := req.RefreshToken
+		if refreshToken == "" {
+			cookie, err := c.Cookie("refresh_token")
+			if err != nil {
+				utils.RequestLogger(c).Debug("Refresh token cookie missing", "error", err)
+				apierror.Respond(c, apierror.Unauthorized("Unable to retrieve refresh token from cookie").WithCode(apierror.CodeNotAuthenticated))
+				return
+			}
+			refreshToken = cookie
+		}
+
 		claim, err := utils.ValidateRefreshToken(refreshToken)
 		if err != nil || claim == nil {
-			fmt.Println("error", err.Error())
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			utils.RequestLogger(c).Debug("Refresh token rejected", "error", err)
+			apierror.Respond(c, apierror.Unauthorized("Invalid or expired refresh token").WithCode(apierror.CodeInvalidToken))
 			return
 		}
 
@@ -249,20 +312,42 @@ func RefreshTokenHandler(client *mongo.Client) gin.HandlerFunc {
 		err = userCollection.FindOne(ctx, bson.D{{Key: "user_id", Value: claim.UserId}}).Decode(&user)
 
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			apierror.Respond(c, apierror.Unauthorized("User not found"))
 			return
 		}
 
-		newToken, newRefreshToken, _ := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.Role, user.UserID)
+		if err := utils.CheckSession(ctx, client, claim); err != nil {
+			if err == utils.ErrAccountSuspended {
+				apierror.Respond(c, apierror.Forbidden("Account suspended").WithCode(apierror.CodeAccountSuspended))
+				return
+			}
+			apierror.Respond(c, apierror.Unauthorized("Session is no longer valid").WithCode(apierror.CodeInvalidToken))
+			return
+		}
+
+		// Permissions are re-resolved on refresh so role edits take effect without a new login
+		permissions, err := utils.ResolvePermissions(ctx, client, user.Role)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal("Failed to load permissions"))
+			return
+		}
+
+		// A session locked to a kids profile stays locked
+		newToken, newRefreshToken, _ := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.Role, user.UserID, claim.ProfileId, permissions)
 		err = utils.UpdateAllTokens(user.UserID, newToken, newRefreshToken, client)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating tokens"})
+			apierror.Respond(c, apierror.Internal("Error updating tokens"))
+			return
+		}
+
+		if req.RefreshToken != "" {
+			c.JSON(http.StatusOK, refreshTokenResponse{Message: "Tokens refreshed", Token: newToken, RefreshToken: newRefreshToken})
 			return
 		}
 
 		c.SetCookie("access_token", newToken, 86400, "/", "localhost", true, true)          // expires in 24 hours
 		c.SetCookie("refresh_token", newRefreshToken, 604800, "/", "localhost", true, true) //expires in 1 week
 
-		c.JSON(http.StatusOK, gin.H{"message": "Tokens refreshed"})
+		c.JSON(http.StatusOK, refreshTokenResponse{Message: "Tokens refreshed"})
 	}
 }
@@ -3,11 +3,13 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/metrics"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/tracing"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -18,18 +20,17 @@ func Connect() *mongo.Client {
 	err := godotenv.Load(".env")
 
 	if err != nil {
-		log.Println("Warning: unable to fund .env file")
+		slog.Debug(".env file not found")
 	}
 
 	MongoDb := os.Getenv("MONGODB_URI")
 
 	if MongoDb == "" {
-		log.Fatal("MONGODB_URI not set!")
+		slog.Error("MONGODB_URI not set")
+		os.Exit(1)
 	}
 
-	fmt.Println("MongoDB URI: ", MongoDb)
-
-	clientOptions := options.Client().ApplyURI(MongoDb)
+	clientOptions := options.Client().ApplyURI(MongoDb).SetMonitor(combineMonitors(metrics.MongoMonitor(), tracing.MongoMonitor()))
 
 	client, err := mongo.Connect(clientOptions)
 
@@ -46,13 +47,11 @@ func OpenCollection(collectionName string, client *mongo.Client) *mongo.Collecti
 
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Debug(".env file not found")
 	}
 
 	databaseName := os.Getenv("DATABASE_NAME")
 
-	fmt.Println("DATABASE_NAME: ", databaseName)
-
 	collection := client.Database(databaseName).Collection(collectionName)
 
 	if collection == nil {
@@ -66,7 +65,7 @@ func OpenCollection(collectionName string, client *mongo.Client) *mongo.Collecti
 func CreateMovieIndexes(client *mongo.Client) error {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Debug(".env file not found")
 	}
 
 	databaseName := os.Getenv("DATABASE_NAME")
@@ -100,16 +99,26 @@ func CreateMovieIndexes(client *mongo.Client) error {
 		Options: options.Index().SetName("ranking_idx"),
 	}
 
-	indexes := []mongo.IndexModel{titleIndexModel, genreRankingIndexModel, rankingIndexModel}
+	// Index for user rating sort and min_rating filter
+	userRatingIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "avg_rating", Value: -1},
+			{Key: "rating_count", Value: -1},
+		},
+		Options: options.Index().SetName("avg_rating_count_idx"),
+	}
+
+	indexes := []mongo.IndexModel{titleIndexModel, genreRankingIndexModel, rankingIndexModel, userRatingIndexModel}
 
 	_, err = movieCollection.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
-		log.Printf("Warning: Failed to create some indexes (may already exist): %v", err)
+		slog.Warn("Failed to create some indexes (may already exist)", "error", err)
+		recordIndexFailure("movie", err)
 		// Don't fail completely - indexes may already exist
 		return nil
 	}
 
-	log.Println("Movie indexes created successfully")
+	slog.Info("Movie indexes created successfully")
 	return nil
 }
 
@@ -117,7 +126,7 @@ func CreateMovieIndexes(client *mongo.Client) error {
 func CreateWatchlistIndexes(client *mongo.Client) error {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Debug(".env file not found")
 	}
 
 	databaseName := os.Getenv("DATABASE_NAME")
@@ -130,14 +139,18 @@ func CreateWatchlistIndexes(client *mongo.Client) error {
 
 	watchlistCollection := client.Database(databaseName).Collection("watchlists")
 
-	// Unique compound index: user_id + imdb_id (prevents duplicates)
+	// The pre-profiles unique index would block two profiles from adding the same movie
+	_ = watchlistCollection.Indexes().DropOne(ctx, "user_imdb_unique_idx")
+
+	// Unique compound index: user_id + profile_id + imdb_id (prevents duplicates per profile)
 	uniqueIndexModel := mongo.IndexModel{
 		Keys: bson.D{
 			{Key: "user_id", Value: 1},
+			{Key: "profile_id", Value: 1},
 			{Key: "imdb_id", Value: 1},
 		},
 		Options: options.Index().
-			SetName("user_imdb_unique_idx").
+			SetName("user_profile_imdb_unique_idx").
 			SetUnique(true),
 	}
 
@@ -154,12 +167,13 @@ func CreateWatchlistIndexes(client *mongo.Client) error {
 
 	_, err = watchlistCollection.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
-		log.Printf("Warning: Failed to create some watchlist indexes (may already exist): %v", err)
+		slog.Warn("Failed to create some watchlist indexes (may already exist)", "error", err)
+		recordIndexFailure("watchlist", err)
 		// Don't fail completely - indexes may already exist
 		return nil
 	}
 
-	log.Println("Watchlist indexes created successfully")
+	slog.Info("Watchlist indexes created successfully")
 	return nil
 }
 
@@ -167,7 +181,7 @@ func CreateWatchlistIndexes(client *mongo.Client) error {
 func CreatePlanIndexes(client *mongo.Client) error {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Debug(".env file not found")
 	}
 
 	databaseName := os.Getenv("DATABASE_NAME")
@@ -190,11 +204,12 @@ func CreatePlanIndexes(client *mongo.Client) error {
 
 	_, err = planCollection.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
-		log.Printf("Warning: Failed to create some plan indexes (may already exist): %v", err)
+		slog.Warn("Failed to create some plan indexes (may already exist)", "error", err)
+		recordIndexFailure("plan", err)
 		return nil
 	}
 
-	log.Println("Plan indexes created successfully")
+	slog.Info("Plan indexes created successfully")
 	return nil
 }
 
@@ -202,7 +217,7 @@ func CreatePlanIndexes(client *mongo.Client) error {
 func CreateSubscriptionIndexes(client *mongo.Client) error {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Debug(".env file not found")
 	}
 
 	databaseName := os.Getenv("DATABASE_NAME")
@@ -230,15 +245,28 @@ func CreateSubscriptionIndexes(client *mongo.Client) error {
 		Options: options.Index().SetName("expires_at_idx"),
 	}
 
-	indexes := []mongo.IndexModel{userStatusIndexModel, expiresIndexModel}
+	// Index for churn analytics by cancellation time
+	canceledAtIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "canceled_at", Value: 1}},
+		Options: options.Index().SetName("canceled_at_idx"),
+	}
+
+	// Index for cohort/retention scans ordered by start
+	startedAtIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "started_at", Value: 1}},
+		Options: options.Index().SetName("started_at_idx"),
+	}
+
+	indexes := []mongo.IndexModel{userStatusIndexModel, expiresIndexModel, canceledAtIndexModel, startedAtIndexModel}
 
 	_, err = subscriptionCollection.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
-		log.Printf("Warning: Failed to create some subscription indexes (may already exist): %v", err)
+		slog.Warn("Failed to create some subscription indexes (may already exist)", "error", err)
+		recordIndexFailure("subscription", err)
 		return nil
 	}
 
-	log.Println("Subscription indexes created successfully")
+	slog.Info("Subscription indexes created successfully")
 	return nil
 }
 
@@ -246,7 +274,7 @@ func CreateSubscriptionIndexes(client *mongo.Client) error {
 func CreateRatingIndexes(client *mongo.Client) error {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Debug(".env file not found")
 	}
 
 	databaseName := os.Getenv("DATABASE_NAME")
@@ -259,14 +287,18 @@ func CreateRatingIndexes(client *mongo.Client) error {
 
 	ratingCollection := client.Database(databaseName).Collection("ratings")
 
-	// Unique compound index: user_id + imdb_id (prevents duplicates)
+	// The pre-profiles unique index would block two profiles from adding the same movie
+	_ = ratingCollection.Indexes().DropOne(ctx, "user_imdb_unique_idx")
+
+	// Unique compound index: user_id + profile_id + imdb_id (prevents duplicates per profile)
 	uniqueIndexModel := mongo.IndexModel{
 		Keys: bson.D{
 			{Key: "user_id", Value: 1},
+			{Key: "profile_id", Value: 1},
 			{Key: "imdb_id", Value: 1},
 		},
 		Options: options.Index().
-			SetName("user_imdb_unique_idx").
+			SetName("user_profile_imdb_unique_idx").
 			SetUnique(true),
 	}
 
@@ -279,15 +311,62 @@ func CreateRatingIndexes(client *mongo.Client) error {
 		Options: options.Index().SetName("imdb_created_at_idx"),
 	}
 
-	indexes := []mongo.IndexModel{uniqueIndexModel, movieIndexModel}
+	// Index for the moderation queue (most reported first)
+	moderationIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "moderation_status", Value: 1},
+			{Key: "report_count", Value: -1},
+			{Key: "updated_at", Value: -1},
+		},
+		Options: options.Index().SetName("moderation_status_reports_idx"),
+	}
+
+	indexes := []mongo.IndexModel{uniqueIndexModel, movieIndexModel, moderationIndexModel}
 
 	_, err = ratingCollection.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
-		log.Printf("Warning: Failed to create some rating indexes (may already exist): %v", err)
+		slog.Warn("Failed to create some rating indexes (may already exist)", "error", err)
+		recordIndexFailure("rating", err)
+		return nil
+	}
+
+	// One report per user per review
+	reportIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "rating_id", Value: 1},
+			{Key: "user_id", Value: 1},
+		},
+		Options: options.Index().
+			SetName("rating_user_unique_idx").
+			SetUnique(true),
+	}
+
+	_, err = client.Database(databaseName).Collection("review_reports").Indexes().CreateOne(ctx, reportIndexModel)
+	if err != nil {
+		slog.Warn("Failed to create review report index (may already exist)", "error", err)
+		recordIndexFailure("rating", err)
+		return nil
+	}
+
+	// One helpful vote per user per review
+	voteIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "rating_id", Value: 1},
+			{Key: "user_id", Value: 1},
+		},
+		Options: options.Index().
+			SetName("rating_user_unique_idx").
+			SetUnique(true),
+	}
+
+	_, err = client.Database(databaseName).Collection("review_votes").Indexes().CreateOne(ctx, voteIndexModel)
+	if err != nil {
+		slog.Warn("Failed to create review vote index (may already exist)", "error", err)
+		recordIndexFailure("rating", err)
 		return nil
 	}
 
-	log.Println("Rating indexes created successfully")
+	slog.Info("Rating indexes created successfully")
 	return nil
 }
 
@@ -295,7 +374,7 @@ func CreateRatingIndexes(client *mongo.Client) error {
 func CreatePasswordResetIndexes(client *mongo.Client) error {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Debug(".env file not found")
 	}
 
 	databaseName := os.Getenv("DATABASE_NAME")
@@ -330,11 +409,12 @@ func CreatePasswordResetIndexes(client *mongo.Client) error {
 
 	_, err = passwordResetCollection.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
-		log.Printf("Warning: Failed to create some password reset indexes (may already exist): %v", err)
+		slog.Warn("Failed to create some password reset indexes (may already exist)", "error", err)
+		recordIndexFailure("password_reset", err)
 		return nil
 	}
 
-	log.Println("Password reset indexes created successfully")
+	slog.Info("Password reset indexes created successfully")
 	return nil
 }
 
@@ -342,7 +422,7 @@ func CreatePasswordResetIndexes(client *mongo.Client) error {
 func CreatePaymentIndexes(client *mongo.Client) error {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Debug(".env file not found")
 	}
 
 	databaseName := os.Getenv("DATABASE_NAME")
@@ -380,11 +460,12 @@ func CreatePaymentIndexes(client *mongo.Client) error {
 
 	_, err = paymentCollection.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
-		log.Printf("Warning: Failed to create some payment indexes (may already exist): %v", err)
+		slog.Warn("Failed to create some payment indexes (may already exist)", "error", err)
+		recordIndexFailure("payment", err)
 		return nil
 	}
 
-	log.Println("Payment indexes created successfully")
+	slog.Info("Payment indexes created successfully")
 	return nil
 }
 
@@ -392,7 +473,7 @@ func CreatePaymentIndexes(client *mongo.Client) error {
 func CreateEmailVerificationIndexes(client *mongo.Client) error {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Debug(".env file not found")
 	}
 
 	databaseName := os.Getenv("DATABASE_NAME")
@@ -427,10 +508,485 @@ func CreateEmailVerificationIndexes(client *mongo.Client) error {
 
 	_, err = emailVerificationCollection.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
-		log.Printf("Warning: Failed to create some email verification indexes (may already exist): %v", err)
+		slog.Warn("Failed to create some email verification indexes (may already exist)", "error", err)
+		recordIndexFailure("email_verification", err)
+		return nil
+	}
+
+	slog.Info("Email verification indexes created successfully")
+	return nil
+}
+
+// CreateEmailChangeIndexes creates indexes for the email_changes collection
+func CreateEmailChangeIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	emailChangeCollection := client.Database(databaseName).Collection("email_changes")
+
+	// Unique index on token
+	tokenIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "token", Value: 1}},
+		Options: options.Index().SetName("token_unique_idx").SetUnique(true),
+	}
+
+	// Index for expiry cleanup
+	expiresIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("expires_at_idx"),
+	}
+
+	// Index for user lookups
+	userIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("user_id_idx"),
+	}
+
+	indexes := []mongo.IndexModel{tokenIndexModel, expiresIndexModel, userIndexModel}
+
+	_, err = emailChangeCollection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		slog.Warn("Failed to create some email change indexes (may already exist)", "error", err)
+		recordIndexFailure("email_change", err)
+		return nil
+	}
+
+	slog.Info("Email change indexes created successfully")
+	return nil
+}
+
+// CreateUserIndexes creates indexes for the users collection
+func CreateUserIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	userCollection := client.Database(databaseName).Collection("users")
+
+	// Unique index on user_id
+	userIDIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("user_id_unique_idx").SetUnique(true),
+	}
+
+	// Index for login and email uniqueness checks
+	emailIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName("email_idx"),
+	}
+
+	// Sparse index for the account deletion worker
+	deletionIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletion_scheduled_at", Value: 1}},
+		Options: options.Index().SetName("deletion_scheduled_at_idx").SetSparse(true),
+	}
+
+	indexes := []mongo.IndexModel{userIDIndexModel, emailIndexModel, deletionIndexModel}
+
+	_, err = userCollection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		slog.Warn("Failed to create some user indexes (may already exist)", "error", err)
+		recordIndexFailure("user", err)
+		return nil
+	}
+
+	slog.Info("User indexes created successfully")
+	return nil
+}
+
+// CreateProfileIndexes creates indexes for the profiles collection
+func CreateProfileIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	profileCollection := client.Database(databaseName).Collection("profiles")
+
+	// Unique index on profile_id
+	profileIDIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "profile_id", Value: 1}},
+		Options: options.Index().SetName("profile_id_unique_idx").SetUnique(true),
+	}
+
+	// Index for listing an account's profiles
+	userIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "user_id", Value: 1},
+			{Key: "created_at", Value: 1},
+		},
+		Options: options.Index().SetName("user_created_at_idx"),
+	}
+
+	indexes := []mongo.IndexModel{profileIDIndexModel, userIndexModel}
+
+	_, err = profileCollection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		slog.Warn("Failed to create some profile indexes (may already exist)", "error", err)
+		recordIndexFailure("profile", err)
+		return nil
+	}
+
+	slog.Info("Profile indexes created successfully")
+	return nil
+}
+
+// CreateWatchProgressIndexes creates indexes for the watch_progress collection
+func CreateWatchProgressIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	progressCollection := client.Database(databaseName).Collection("watch_progress")
+
+	// Unique compound index: one progress entry per profile and movie
+	uniqueIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "user_id", Value: 1},
+			{Key: "profile_id", Value: 1},
+			{Key: "imdb_id", Value: 1},
+		},
+		Options: options.Index().
+			SetName("user_profile_imdb_unique_idx").
+			SetUnique(true),
+	}
+
+	// Index for continue-watching and history (sorted by last_watched_at desc)
+	recentIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "user_id", Value: 1},
+			{Key: "profile_id", Value: 1},
+			{Key: "finished", Value: 1},
+			{Key: "last_watched_at", Value: -1},
+		},
+		Options: options.Index().SetName("user_profile_finished_last_watched_idx"),
+	}
+
+	indexes := []mongo.IndexModel{uniqueIndexModel, recentIndexModel}
+
+	_, err = progressCollection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		slog.Warn("Failed to create some watch progress indexes (may already exist)", "error", err)
+		recordIndexFailure("watch_progress", err)
+		return nil
+	}
+
+	slog.Info("Watch progress indexes created successfully")
+	return nil
+}
+
+// CreateAuditEventIndexes creates indexes for the audit_events collection
+func CreateAuditEventIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	auditCollection := client.Database(databaseName).Collection("audit_events")
+
+	// Unique sequence keeps the hash chain linear under concurrent writers
+	sequenceIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "sequence", Value: 1}},
+		Options: options.Index().
+			SetName("sequence_unique_idx").
+			SetUnique(true),
+	}
+
+	actorIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "actor_id", Value: 1},
+			{Key: "created_at", Value: -1},
+		},
+		Options: options.Index().SetName("actor_created_at_idx"),
+	}
+
+	targetIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "target_type", Value: 1},
+			{Key: "target_id", Value: 1},
+			{Key: "created_at", Value: -1},
+		},
+		Options: options.Index().SetName("target_created_at_idx"),
+	}
+
+	actionIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "action", Value: 1},
+			{Key: "created_at", Value: -1},
+		},
+		Options: options.Index().SetName("action_created_at_idx"),
+	}
+
+	indexes := []mongo.IndexModel{sequenceIndexModel, actorIndexModel, targetIndexModel, actionIndexModel}
+
+	_, err = auditCollection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		slog.Warn("Failed to create some audit event indexes (may already exist)", "error", err)
+		recordIndexFailure("audit_event", err)
+		return nil
+	}
+
+	slog.Info("Audit event indexes created successfully")
+	return nil
+}
+
+// CreateRoleIndexes creates indexes for the roles collection
+func CreateRoleIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	roleCollection := client.Database(databaseName).Collection("roles")
+
+	// Unique index on name
+	nameIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().
+			SetName("name_unique_idx").
+			SetUnique(true),
+	}
+
+	_, err = roleCollection.Indexes().CreateOne(ctx, nameIndexModel)
+	if err != nil {
+		slog.Warn("Failed to create role index (may already exist)", "error", err)
+		recordIndexFailure("role", err)
+		return nil
+	}
+
+	slog.Info("Role indexes created successfully")
+	return nil
+}
+
+// CreateReportIndexes creates indexes for the scheduled_reports and generated_reports collections
+func CreateReportIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	scheduledCollection := client.Database(databaseName).Collection("scheduled_reports")
+	generatedCollection := client.Database(databaseName).Collection("generated_reports")
+
+	// Lets the scheduler find due reports without a collection scan
+	dueIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "enabled", Value: 1},
+			{Key: "next_run_at", Value: 1},
+		},
+		Options: options.Index().SetName("enabled_next_run_at_idx"),
+	}
+
+	_, err = scheduledCollection.Indexes().CreateOne(ctx, dueIndexModel)
+	if err != nil {
+		slog.Warn("Failed to create scheduled report indexes (may already exist)", "error", err)
+		recordIndexFailure("report", err)
+		return nil
+	}
+
+	reportFilesIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "report_id", Value: 1},
+			{Key: "created_at", Value: -1},
+		},
+		Options: options.Index().SetName("report_created_at_idx"),
+	}
+
+	_, err = generatedCollection.Indexes().CreateOne(ctx, reportFilesIndexModel)
+	if err != nil {
+		slog.Warn("Failed to create generated report indexes (may already exist)", "error", err)
+		recordIndexFailure("report", err)
+		return nil
+	}
+
+	slog.Info("Report indexes created successfully")
+	return nil
+}
+
+// CreateSearchAnalyticsIndexes creates indexes for the zero_result_searches collection
+func CreateSearchAnalyticsIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	searchCollection := client.Database(databaseName).Collection("zero_result_searches")
+
+	// One counter per query per day; the upsert relies on this to avoid duplicates
+	queryDayIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "query", Value: 1},
+			{Key: "day", Value: 1},
+		},
+		Options: options.Index().
+			SetName("query_day_unique_idx").
+			SetUnique(true),
+	}
+
+	// Index for date-range scans in content analytics
+	dayIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "day", Value: -1}},
+		Options: options.Index().SetName("day_idx"),
+	}
+
+	indexes := []mongo.IndexModel{queryDayIndexModel, dayIndexModel}
+
+	_, err = searchCollection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		slog.Warn("Failed to create some search analytics indexes (may already exist)", "error", err)
+		recordIndexFailure("search_analytics", err)
+		return nil
+	}
+
+	slog.Info("Search analytics indexes created successfully")
+	return nil
+}
+
+// CreateAnalyticsRollupIndexes creates indexes for the analytics_daily collection
+func CreateAnalyticsRollupIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rollupCollection := client.Database(databaseName).Collection("analytics_daily")
+
+	// One fact document per UTC day; upserts from the worker and rebuilds rely on it
+	dayIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "day", Value: 1}},
+		Options: options.Index().
+			SetName("day_unique_idx").
+			SetUnique(true),
+	}
+
+	_, err = rollupCollection.Indexes().CreateOne(ctx, dayIndexModel)
+	if err != nil {
+		slog.Warn("Failed to create analytics rollup indexes (may already exist)", "error", err)
+		recordIndexFailure("analytics_rollup", err)
+		return nil
+	}
+
+	slog.Info("Analytics rollup indexes created successfully")
+	return nil
+}
+
+// CreateAPIKeyIndexes creates indexes for the api_keys collection
+func CreateAPIKeyIndexes(client *mongo.Client) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		slog.Debug(".env file not found")
+	}
+
+	databaseName := os.Getenv("DATABASE_NAME")
+	if databaseName == "" {
+		return fmt.Errorf("DATABASE_NAME not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	apiKeyCollection := client.Database(databaseName).Collection("api_keys")
+
+	// Every authenticated request with an API key looks it up by hash
+	hashIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "key_hash", Value: 1}},
+		Options: options.Index().SetName("key_hash_unique_idx").SetUnique(true),
+	}
+
+	// Index for listing a user's keys
+	userIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "user_id", Value: 1},
+			{Key: "created_at", Value: -1},
+		},
+		Options: options.Index().SetName("user_created_at_idx"),
+	}
+
+	indexes := []mongo.IndexModel{hashIndexModel, userIndexModel}
+
+	_, err = apiKeyCollection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		slog.Warn("Failed to create some API key indexes (may already exist)", "error", err)
+		recordIndexFailure("api_key", err)
 		return nil
 	}
 
-	log.Println("Email verification indexes created successfully")
+	slog.Info("API key indexes created successfully")
 	return nil
 }
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	mongoRetryInitialDelay = time.Second
+	mongoRetryMaxDelay     = 30 * time.Second
+)
+
+// WaitForMongo pings MongoDB until it answers, backing off exponentially between attempts.
+// It only gives up when ctx is done, so a database that comes up after the server does is picked up.
+func WaitForMongo(ctx context.Context, client *mongo.Client) error {
+	delay := mongoRetryInitialDelay
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := client.Ping(pingCtx, nil)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				slog.Info("Connected to MongoDB", "attempts", attempt)
+			}
+			return nil
+		}
+
+		slog.Warn("MongoDB not reachable, retrying", "attempt", attempt, "retry_in", delay.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > mongoRetryMaxDelay {
+			delay = mongoRetryMaxDelay
+		}
+	}
+}
+
+// IndexSyncStatus reports whether startup index creation has finished and which index groups failed
+type IndexSyncStatus struct {
+	Completed   bool              `json:"completed"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	Failures    map[string]string `json:"failures,omitempty"`
+}
+
+var indexSync = struct {
+	sync.Mutex
+	status IndexSyncStatus
+}{}
+
+// recordIndexFailure notes an index group that could not be created; the server keeps running without it
+func recordIndexFailure(group string, err error) {
+	indexSync.Lock()
+	defer indexSync.Unlock()
+	if indexSync.status.Failures == nil {
+		indexSync.status.Failures = map[string]string{}
+	}
+	indexSync.status.Failures[group] = err.Error()
+}
+
+// MarkIndexSyncComplete is called once every Create*Indexes function has run
+func MarkIndexSyncComplete() {
+	indexSync.Lock()
+	defer indexSync.Unlock()
+	now := time.Now()
+	indexSync.status.Completed = true
+	indexSync.status.CompletedAt = &now
+}
+
+// GetIndexSyncStatus returns a copy of the current index sync status
+func GetIndexSyncStatus() IndexSyncStatus {
+	indexSync.Lock()
+	defer indexSync.Unlock()
+	status := indexSync.status
+	if status.Failures != nil {
+		status.Failures = make(map[string]string, len(indexSync.status.Failures))
+		for k, v := range indexSync.status.Failures {
+			status.Failures[k] = v
+		}
+	}
+	return status
+}
@@ -0,0 +1,352 @@
+// Package mongotest provides an in-process MongoDB deployment for tests, in the spirit of
+// net/http/httptest: handlers and workers run against a real *mongo.Client without a server.
+package mongotest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/address"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/x/bsonx/bsoncore"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/description"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/mnet"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/wiremessage"
+)
+
+// Deployment answers every command from fixed documents per collection. Filters, projections
+// and sorts are ignored: reads return every fixture of the collection, CountDocuments counts the
+// fixtures, other aggregations return nothing and writes report one matched document. That is
+// enough to drive handlers down their success paths; Reply overrides the answer for tests that
+// need more, and every command is recorded for tests that check what was written.
+type Deployment struct {
+	fixtures map[string][]bson.D
+
+	// Reply, when set, answers commands it returns a non-nil document for
+	Reply func(command bsoncore.Document) bson.D
+
+	mu       sync.Mutex
+	commands []bsoncore.Document
+	blocking bool
+	held     chan string
+}
+
+var sessionTimeoutMinutes int64 = 30
+
+var fakeServerDescription = description.Server{
+	Addr:                  address.Address("fake:27017"),
+	CanonicalAddr:         address.Address("fake:27017"),
+	Kind:                  description.ServerKindStandalone,
+	MaxDocumentSize:       16777216,
+	MaxMessageSize:        48000000,
+	MaxBatchCount:         100000,
+	SessionTimeoutMinutes: &sessionTimeoutMinutes,
+	WireVersion:           &description.VersionRange{Min: 0, Max: 25},
+}
+
+var (
+	_ driver.Deployment   = &Deployment{}
+	_ driver.Server       = &Deployment{}
+	_ driver.Connector    = &Deployment{}
+	_ driver.Disconnector = &Deployment{}
+	_ driver.Subscriber   = &Deployment{}
+)
+
+// NewClient returns a client whose commands are answered by a Deployment serving fixtures
+func NewClient(fixtures map[string][]bson.D) (*mongo.Client, *Deployment, error) {
+	deployment := &Deployment{fixtures: fixtures, held: make(chan string, 16)}
+	opts := options.Client()
+	opts.Deployment = deployment
+	client, err := mongo.Connect(opts)
+	return client, deployment, err
+}
+
+// Commands returns the commands sent so far with the given name ("update", "find", ...) against
+// collection, or against any collection when collection is ""
+func (d *Deployment) Commands(name, collection string) []bsoncore.Document {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var matched []bsoncore.Document
+	for _, command := range d.commands {
+		elements, err := command.Elements()
+		if err != nil || len(elements) == 0 || elements[0].Key() != name {
+			continue
+		}
+		if target, _ := elements[0].Value().StringValueOK(); collection == "" || target == collection {
+			matched = append(matched, command)
+		}
+	}
+	return matched
+}
+
+// Block makes every later command wait until its context is done instead of being answered
+func (d *Deployment) Block() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.blocking = true
+}
+
+// Held receives the name of each command Block is holding, once the caller is waiting on it
+func (d *Deployment) Held() <-chan string {
+	return d.held
+}
+
+func (d *Deployment) SelectServer(context.Context, description.ServerSelector) (driver.Server, error) {
+	return d, nil
+}
+
+func (d *Deployment) Kind() description.TopologyKind { return description.TopologyKindSingle }
+
+func (d *Deployment) GetServerSelectionTimeout() time.Duration { return 0 }
+
+func (d *Deployment) Connection(context.Context) (*mnet.Connection, error) {
+	return mnet.NewConnection(&fakeConnection{server: d}), nil
+}
+
+func (d *Deployment) RTTMonitor() driver.RTTMonitor { return zeroRTTMonitor{} }
+
+func (d *Deployment) Connect() error { return nil }
+
+func (d *Deployment) Disconnect(context.Context) error { return nil }
+
+func (d *Deployment) Subscribe() (*driver.Subscription, error) {
+	updates := make(chan description.Topology, 1)
+	updates <- description.Topology{
+		Kind:                  description.TopologyKindSingle,
+		Servers:               []description.Server{fakeServerDescription},
+		SessionTimeoutMinutes: &sessionTimeoutMinutes,
+	}
+	return &driver.Subscription{Updates: updates}, nil
+}
+
+func (d *Deployment) Unsubscribe(*driver.Subscription) error { return nil }
+
+// reply builds the response document for one command
+func (d *Deployment) reply(command bsoncore.Document) bson.D {
+	elements, err := command.Elements()
+	if err != nil || len(elements) == 0 {
+		return bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: "unreadable command"}}
+	}
+	if d.Reply != nil {
+		if reply := d.Reply(command); reply != nil {
+			return reply
+		}
+	}
+	name := elements[0].Key()
+	collection, _ := elements[0].Value().StringValueOK()
+	ns := "test." + collection
+
+	switch name {
+	case "find":
+		return cursorReply(ns, "firstBatch", d.documents(collection))
+	case "aggregate":
+		if isCountPipeline(command) {
+			count := bson.D{{Key: "_id", Value: 1}, {Key: "n", Value: int32(len(d.fixtures[collection]))}}
+			return cursorReply(ns, "firstBatch", bson.A{count})
+		}
+		return cursorReply(ns, "firstBatch", nil)
+	case "getMore":
+		return cursorReply(ns, "nextBatch", nil)
+	case "distinct":
+		return bson.D{{Key: "values", Value: bson.A{}}, {Key: "ok", Value: 1}}
+	case "count":
+		return bson.D{{Key: "n", Value: int64(len(d.fixtures[collection]))}, {Key: "ok", Value: 1}}
+	case "insert":
+		return bson.D{{Key: "n", Value: 1}, {Key: "ok", Value: 1}}
+	case "update":
+		return bson.D{{Key: "n", Value: 1}, {Key: "nModified", Value: 1}, {Key: "ok", Value: 1}}
+	case "delete":
+		return bson.D{{Key: "n", Value: 1}, {Key: "ok", Value: 1}}
+	case "findAndModify":
+		var value interface{}
+		if docs := d.documents(collection); len(docs) > 0 {
+			value = docs[0]
+		}
+		return bson.D{
+			{Key: "lastErrorObject", Value: bson.D{{Key: "n", Value: 1}, {Key: "updatedExisting", Value: true}}},
+			{Key: "value", Value: value},
+			{Key: "ok", Value: 1},
+		}
+	}
+	// ping, createIndexes, endSessions and anything else just succeed
+	return bson.D{{Key: "ok", Value: 1}}
+}
+
+// isCountPipeline reports whether an aggregation is the one CountDocuments sends, which ends
+// with {$group: {_id: 1, n: {$sum: 1}}}
+func isCountPipeline(command bsoncore.Document) bool {
+	pipeline, ok := command.Lookup("pipeline").ArrayOK()
+	if !ok {
+		return false
+	}
+	stages, err := pipeline.Values()
+	if err != nil || len(stages) == 0 {
+		return false
+	}
+	last, ok := stages[len(stages)-1].DocumentOK()
+	if !ok {
+		return false
+	}
+	_, err = last.LookupErr("$group", "n")
+	return err == nil
+}
+
+func (d *Deployment) documents(collection string) bson.A {
+	docs := bson.A{}
+	for _, doc := range d.fixtures[collection] {
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func cursorReply(ns, batch string, docs bson.A) bson.D {
+	if docs == nil {
+		docs = bson.A{}
+	}
+	return bson.D{
+		{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: ns}, {Key: batch, Value: docs}}},
+		{Key: "ok", Value: 1},
+	}
+}
+
+// fakeConnection answers each written command on the next read
+type fakeConnection struct {
+	server  *Deployment
+	mu      sync.Mutex
+	pending []pendingReply
+}
+
+type pendingReply struct {
+	command string
+	held    bool
+	message []byte
+}
+
+var (
+	_ mnet.ReadWriteCloser = &fakeConnection{}
+	_ mnet.Describer       = &fakeConnection{}
+)
+
+func (c *fakeConnection) Write(_ context.Context, wm []byte) error {
+	command, err := commandFromMessage(wm)
+	if err != nil {
+		return err
+	}
+	_, requestID, _, _, _, _ := wiremessage.ReadHeader(wm)
+
+	c.server.mu.Lock()
+	c.server.commands = append(c.server.commands, command)
+	held := c.server.blocking
+	c.server.mu.Unlock()
+
+	response, err := bson.Marshal(c.server.reply(command))
+	if err != nil {
+		return err
+	}
+	var dst []byte
+	index, dst := wiremessage.AppendHeaderStart(dst, wiremessage.NextRequestID(), requestID, wiremessage.OpMsg)
+	dst = wiremessage.AppendMsgFlags(dst, 0)
+	dst = wiremessage.AppendMsgSectionType(dst, wiremessage.SingleDocument)
+	dst = append(dst, response...)
+	dst = bsoncore.UpdateLength(dst, index, int32(len(dst[index:])))
+
+	name := ""
+	if elements, err := command.Elements(); err == nil && len(elements) > 0 {
+		name = elements[0].Key()
+	}
+	c.mu.Lock()
+	c.pending = append(c.pending, pendingReply{command: name, held: held, message: dst})
+	c.mu.Unlock()
+	return nil
+}
+
+// commandFromMessage returns the command an OP_MSG carries, with its document sequences
+// ("updates", "documents", ...) folded back in as arrays the way a server sees them
+func commandFromMessage(wm []byte) (bsoncore.Document, error) {
+	_, _, _, _, rem, ok := wiremessage.ReadHeader(wm)
+	if !ok {
+		return nil, errors.New("could not read header")
+	}
+	if _, rem, ok = wiremessage.ReadMsgFlags(rem); !ok {
+		return nil, errors.New("could not read flags")
+	}
+
+	var body bsoncore.Document
+	var sequences []byte
+	for len(rem) > 0 {
+		var sectionType wiremessage.SectionType
+		if sectionType, rem, ok = wiremessage.ReadMsgSectionType(rem); !ok {
+			return nil, errors.New("could not read section type")
+		}
+		switch sectionType {
+		case wiremessage.SingleDocument:
+			if body, rem, ok = wiremessage.ReadMsgSectionSingleDocument(rem); !ok {
+				return nil, errors.New("could not read command document")
+			}
+		case wiremessage.DocumentSequence:
+			var identifier string
+			var docs []bsoncore.Document
+			if identifier, docs, rem, ok = wiremessage.ReadMsgSectionDocumentSequence(rem); !ok {
+				return nil, errors.New("could not read document sequence")
+			}
+			array := bsoncore.NewArrayBuilder()
+			for _, doc := range docs {
+				array.AppendDocument(doc)
+			}
+			sequences = bsoncore.AppendArrayElement(sequences, identifier, array.Build())
+		default:
+			return nil, errors.New("unexpected section type")
+		}
+	}
+	if body == nil {
+		return nil, errors.New("no command document")
+	}
+
+	idx, command := bsoncore.AppendDocumentStart(nil)
+	command = append(command, body[4:len(body)-1]...)
+	command = append(command, sequences...)
+	command, err := bsoncore.AppendDocumentEnd(command, idx)
+	return command, err
+}
+
+func (c *fakeConnection) Read(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return nil, errors.New("no command to answer")
+	}
+	next := c.pending[0]
+	c.pending = c.pending[1:]
+	c.mu.Unlock()
+
+	if next.held {
+		select {
+		case c.server.held <- next.command:
+		default:
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return next.message, nil
+}
+
+func (c *fakeConnection) Close() error                    { return nil }
+func (c *fakeConnection) Description() description.Server { return fakeServerDescription }
+func (c *fakeConnection) ID() string                      { return "fake" }
+func (c *fakeConnection) ServerConnectionID() *int64      { id := int64(1); return &id }
+func (c *fakeConnection) DriverConnectionID() int64       { return 1 }
+func (c *fakeConnection) Address() address.Address        { return fakeServerDescription.Addr }
+func (c *fakeConnection) Stale() bool                     { return false }
+func (c *fakeConnection) OIDCTokenGenID() uint64          { return 0 }
+func (c *fakeConnection) SetOIDCTokenGenID(uint64)        {}
+
+type zeroRTTMonitor struct{}
+
+func (zeroRTTMonitor) EWMA() time.Duration { return 0 }
+func (zeroRTTMonitor) Min() time.Duration  { return 0 }
+func (zeroRTTMonitor) Stats() string       { return "" }
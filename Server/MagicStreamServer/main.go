@@ -2,150 +2,295 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
+	"net/http"
 	"os"
-	"regexp"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	controller "github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/controllers"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/middleware"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/openapi"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/routes"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/tracing"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/workers"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
 	// This is the main function
 
-	router := gin.Default()
-
-	router.GET("/hello", func(c *gin.Context) {
-		c.String(200, "Hello, MagicStreamMovies!")
-	})
-
 	err := godotenv.Load(".env")
+
+	// Structured logging, configured by LOG_LEVEL and LOG_FORMAT
+	utils.InitLogger()
 	if err != nil {
-		log.Println("Warning: unable to find .env file")
+		slog.Warn(".env file not found")
 	}
 
-	// CORS origins: configurable via env, with safe defaults for local + deployed frontends
-	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
-
-	// Default allow-list covers localhost + known deployments (vercel + render)
-	defaultOrigins := []string{
-		"http://localhost:5173",
-		"http://localhost:4173",
-		"http://localhost:3000",
-		"https://magic-stream-main.vercel.app",
-		"https://magicstream-main.vercel.app",
-		"https://magic-stream-main.onrender.com",
-		"https://magicstream-main.onrender.com",
+	// Tracing, configured by OTEL_TRACES_EXPORTER and the standard OTEL_* variables
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		slog.Error("Failed to initialise tracing", "error", err)
+		os.Exit(1)
 	}
+	defer shutdownTracing(context.Background())
 
-	var origins []string
-	if allowedOrigins != "" {
-		origins = strings.Split(allowedOrigins, ",")
-		for i := range origins {
-			origins[i] = strings.TrimSpace(origins[i])
-		}
-	} else {
-		origins = defaultOrigins
-	}
-	originSet := make(map[string]struct{}, len(origins))
-	for _, o := range origins {
-		log.Println("Allowed Origin:", o)
-		originSet[o] = struct{}{}
-	}
+	// gin.Default() would add gin's own text logger; requests are logged by RequestLoggerMiddleware instead
+	router := gin.New()
+	// Handler contexts derived from *gin.Context then carry the request's span, so Mongo spans nest under it
+	router.ContextWithFallback = true
+
+	router.GET("/hello", func(c *gin.Context) {
+		c.String(200, "Hello, MagicStreamMovies!")
+	})
 
-	vercelPreview := regexp.MustCompile(`^https://[-a-z0-9]+\.vercel\.app$`)
-	onRender := regexp.MustCompile(`^https://[-a-z0-9]+\.onrender\.com$`)
+	// Browser origins allowed to send credentials, per environment (APP_ENV, ALLOWED_ORIGINS,
+	// ALLOWED_ORIGIN_PATTERNS); also enforced on cookie-authenticated writes by CSRFMiddleware
+	originPolicy := middleware.LoadOriginPolicy()
 
 	config := cors.Config{}
-	// Keep list for transparency, but use AllowOriginFunc to permit previews and exact matches
-	config.AllowOriginFunc = func(origin string) bool {
-		if _, ok := originSet[origin]; ok {
-			return true
-		}
-		// Allow Vercel previews and Render-hosted frontends
-		if vercelPreview.MatchString(origin) || onRender.MatchString(origin) {
-			return true
-		}
-		// Allow localhost variants if not explicitly listed
-		if strings.HasPrefix(origin, "http://localhost:") {
-			return true
-		}
-		return false
-	}
+	config.AllowOriginFunc = originPolicy.Allowed
 	config.AllowMethods = []string{"GET", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"}
-	config.ExposeHeaders = []string{"Content-Length"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-Profile-ID", "X-Impersonation-Token", "X-Request-ID", "X-CSRF-Token", "traceparent", "tracestate", "baggage"}
+	config.ExposeHeaders = []string{"Content-Length", "X-Request-ID", "Deprecation", "Sunset", "Link"}
 	config.AllowCredentials = true
 	config.MaxAge = 12 * time.Hour
 
 	router.Use(cors.New(config))
-	router.Use(gin.Logger())
+	router.Use(otelgin.Middleware(tracing.ServiceName()))
+	router.Use(middleware.RequestIdMiddleware())
+	router.Use(middleware.RequestLoggerMiddleware())
+	// After the logger so a recovered panic is still logged as a 500 request
+	router.Use(middleware.RecoveryMiddleware())
+	router.Use(middleware.MetricsMiddleware())
+	router.Use(middleware.CSRFMiddleware(originPolicy))
+	// Logs responses that diverge from the OpenAPI document; development and CI only
+	if os.Getenv("OPENAPI_VALIDATE_RESPONSES") == "true" {
+		router.Use(middleware.ResponseContractMiddleware(func() *openapi.Document {
+			return controller.OpenAPIDocument(router)
+		}))
+	}
 
 	var client *mongo.Client = database.Connect()
-
-	if err := client.Ping(context.Background(), nil); err != nil {
-		log.Fatalf("Failed to reach server: %v", err)
+	if client == nil {
+		slog.Error("Failed to create MongoDB client")
+		os.Exit(1)
 	}
-	defer func() {
-		err := client.Disconnect(context.Background())
-		if err != nil {
-			log.Fatalf("Failed to disconnect from MongoDB: %v", err)
+
+	// Cancelled on SIGINT/SIGTERM; stops the MongoDB retry loop and the background workers
+	appCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The server starts serving (and /healthz answers) while MongoDB may still be unreachable;
+	// /readyz fails until the connection is up, indexes are synced and the workers are running
+	bootstrapDone := make(chan struct{})
+	go func() {
+		defer close(bootstrapDone)
+		if err := database.WaitForMongo(appCtx, client); err != nil {
+			slog.Warn("Stopped waiting for MongoDB", "error", err)
+			return
 		}
+		initDatabaseAndWorkers(appCtx, client)
+	}()
+
+	routes.SetupUnProtectedRoutes(router, client)
+	routes.SetupProtectedRoutes(router, client)
+
+	server := &http.Server{
+		Addr:              serverAddr(),
+		Handler:           router,
+		ReadHeaderTimeout: envDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:       envDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		// Long enough for the 30s OpenAI call in the review ranking update and report downloads
+		WriteTimeout: envDuration("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:  envDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+	}
 
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("HTTP server listening", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
 	}()
 
+	select {
+	case err := <-serverErr:
+		slog.Error("Failed to start server", "error", err)
+		stop()
+	case <-appCtx.Done():
+		slog.Info("Shutdown signal received, draining")
+	}
+
+	// Everything below shares one deadline: in-flight requests, then workers, then MongoDB
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HTTP server did not drain before the deadline", "error", err)
+	}
+
+	select {
+	case <-bootstrapDone:
+	case <-shutdownCtx.Done():
+	}
+	if err := workers.Wait(shutdownCtx); err != nil {
+		slog.Warn("Background workers did not stop before the deadline", "error", err)
+	}
+
+	if err := client.Disconnect(shutdownCtx); err != nil {
+		slog.Error("Failed to disconnect from MongoDB", "error", err)
+	}
+	slog.Info("Shutdown complete")
+}
+
+// serverAddr is HTTP_ADDR, else :$PORT (as set by most PaaS hosts), else :8080
+func serverAddr() string {
+	if addr := os.Getenv("HTTP_ADDR"); addr != "" {
+		return addr
+	}
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+	return ":8080"
+}
+
+// envDuration parses a Go duration (e.g. "30s") from the environment, falling back to def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("Ignoring invalid duration", "key", key, "value", value)
+		return def
+	}
+	return d
+}
+
+// initDatabaseAndWorkers creates indexes, seeds the built-in roles and starts the background workers once MongoDB is reachable
+func initDatabaseAndWorkers(ctx context.Context, client *mongo.Client) {
+	// Create indexes for users collection
+	if err := database.CreateUserIndexes(client); err != nil {
+		slog.Warn("Failed to create user indexes", "error", err)
+	}
+
 	// Create indexes for movies collection
 	if err := database.CreateMovieIndexes(client); err != nil {
-		log.Printf("Warning: Failed to create movie indexes: %v", err)
+		slog.Warn("Failed to create movie indexes", "error", err)
 	}
 
 	// Create indexes for watchlists collection
 	if err := database.CreateWatchlistIndexes(client); err != nil {
-		log.Printf("Warning: Failed to create watchlist indexes: %v", err)
+		slog.Warn("Failed to create watchlist indexes", "error", err)
+	}
+
+	// Create indexes for profiles collection
+	if err := database.CreateProfileIndexes(client); err != nil {
+		slog.Warn("Failed to create profile indexes", "error", err)
+	}
+
+	// Create indexes for watch_progress collection
+	if err := database.CreateWatchProgressIndexes(client); err != nil {
+		slog.Warn("Failed to create watch progress indexes", "error", err)
 	}
 
 	// Create indexes for plans collection
 	if err := database.CreatePlanIndexes(client); err != nil {
-		log.Printf("Warning: Failed to create plan indexes: %v", err)
+		slog.Warn("Failed to create plan indexes", "error", err)
 	}
 
 	// Create indexes for subscriptions collection
 	if err := database.CreateSubscriptionIndexes(client); err != nil {
-		log.Printf("Warning: Failed to create subscription indexes: %v", err)
+		slog.Warn("Failed to create subscription indexes", "error", err)
 	}
 
 	// Create indexes for ratings collection
 	if err := database.CreateRatingIndexes(client); err != nil {
-		log.Printf("Warning: Failed to create rating indexes: %v", err)
+		slog.Warn("Failed to create rating indexes", "error", err)
 	}
 
 	// Create indexes for password_resets collection
 	if err := database.CreatePasswordResetIndexes(client); err != nil {
-		log.Printf("Warning: Failed to create password reset indexes: %v", err)
+		slog.Warn("Failed to create password reset indexes", "error", err)
 	}
 
 	// Create indexes for email_verifications collection
 	if err := database.CreateEmailVerificationIndexes(client); err != nil {
-		log.Printf("Warning: Failed to create email verification indexes: %v", err)
+		slog.Warn("Failed to create email verification indexes", "error", err)
+	}
+
+	// Create indexes for email_changes collection
+	if err := database.CreateEmailChangeIndexes(client); err != nil {
+		slog.Warn("Failed to create email change indexes", "error", err)
 	}
 
 	// Create indexes for payments collection
 	if err := database.CreatePaymentIndexes(client); err != nil {
-		log.Printf("Warning: Failed to create payment indexes: %v", err)
+		slog.Warn("Failed to create payment indexes", "error", err)
 	}
 
-	routes.SetupUnProtectedRoutes(router, client)
-	routes.SetupProtectedRoutes(router, client)
+	// Create indexes for audit_events collection
+	if err := database.CreateAuditEventIndexes(client); err != nil {
+		slog.Warn("Failed to create audit event indexes", "error", err)
+	}
+
+	// Create indexes for roles collection and seed the built-in roles
+	if err := database.CreateRoleIndexes(client); err != nil {
+		slog.Warn("Failed to create role indexes", "error", err)
+	}
+	seedCtx, seedCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := utils.SeedDefaultRoles(seedCtx, client); err != nil {
+		slog.Warn("Failed to seed default roles", "error", err)
+	}
+	seedCancel()
+
+	// Create indexes for zero_result_searches collection
+	if err := database.CreateSearchAnalyticsIndexes(client); err != nil {
+		slog.Warn("Failed to create search analytics indexes", "error", err)
+	}
+
+	// Create indexes for analytics_daily collection
+	if err := database.CreateAnalyticsRollupIndexes(client); err != nil {
+		slog.Warn("Failed to create analytics rollup indexes", "error", err)
+	}
+
+	// Create indexes for scheduled_reports and generated_reports collections
+	if err := database.CreateReportIndexes(client); err != nil {
+		slog.Warn("Failed to create report indexes", "error", err)
+	}
 
-	if err := router.Run(":8080"); err != nil {
-		fmt.Println("Failed to start server", err)
+	// Create indexes for api_keys collection
+	if err := database.CreateAPIKeyIndexes(client); err != nil {
+		slog.Warn("Failed to create API key indexes", "error", err)
 	}
 
+	database.MarkIndexSyncComplete()
+
+	// Shutdown may have started while indexes were being created
+	if ctx.Err() != nil {
+		return
+	}
+
+	// Purge accounts whose deletion grace period has elapsed
+	workers.StartAccountDeletionWorker(ctx, client, time.Hour)
+
+	// Repair drift in the rating aggregates stored on movie documents
+	workers.StartRatingReconciliationWorker(ctx, client, 6*time.Hour)
+
+	// Keep the daily analytics rollups current (backfills missing days on start)
+	workers.StartAnalyticsRollupWorker(ctx, client, 15*time.Minute)
+
+	// Generate scheduled admin reports as their periods close
+	workers.StartScheduledReportWorker(ctx, client, 5*time.Minute, controller.RunScheduledReport)
 }
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+// MongoMonitor returns a command monitor that records the duration of every MongoDB command.
+// The collection is only present on the started event, so it is remembered by request ID until the command finishes.
+func MongoMonitor() *event.CommandMonitor {
+	var collections sync.Map
+
+	finish := func(requestID int64, command string, seconds float64, outcome string) {
+		collection := "unknown"
+		if v, ok := collections.LoadAndDelete(requestID); ok {
+			collection = v.(string)
+		}
+		MongoCommandDuration.WithLabelValues(command, collection, outcome).Observe(seconds)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			collection := "unknown"
+			// The first element of a command document is {<command>: <collection>} for collection-level commands
+			if elem, err := e.Command.IndexErr(0); err == nil {
+				if name, ok := elem.Value().StringValueOK(); ok {
+					collection = name
+				}
+			}
+			collections.Store(e.RequestID, collection)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finish(e.RequestID, e.CommandName, e.Duration.Seconds(), "success")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finish(e.RequestID, e.CommandName, e.Duration.Seconds(), "error")
+		},
+	}
+}
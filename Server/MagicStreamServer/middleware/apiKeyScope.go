@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+)
+
+// APIKeyWriteScope refuses writes from API keys without the write scope, unless the route is
+// guarded by a permission the key was granted. It runs right before the handler, after any
+// RequirePermission on the route.
+func APIKeyWriteScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if utils.IsReadOnlyAPIKeyFromContext(c) && !c.GetBool("permissionGranted") {
+			apierror.Respond(c, apierror.Forbidden("This API key is read-only").WithCode(apierror.CodeAPIKeyReadOnly))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -1,37 +1,126 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"time"
 
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
-func AuthMiddleWare() gin.HandlerFunc {
+// claimPermissions returns the token's permissions; tokens issued before permission
+// claims existed fall back to the built-in permissions of their role
+func claimPermissions(claims *utils.SignedDetails) []string {
+	if claims.Permissions != nil {
+		return claims.Permissions
+	}
+	return utils.DefaultPermissionsOrEmpty(claims.Role)
+}
+
+// setClaims copies the token claims the handlers rely on into the request context
+func setClaims(c *gin.Context, claims *utils.SignedDetails) {
+	c.Set("userId", claims.UserId)
+	c.Set("role", claims.Role)
+	c.Set("permissions", claimPermissions(claims))
+	if claims.ImpersonatorId != "" {
+		c.Set("impersonatorId", claims.ImpersonatorId)
+	}
+	if claims.APIKeyId != "" {
+		c.Set("apiKeyId", claims.APIKeyId)
+		c.Set("apiKeyWrite", claims.APIKeyWrite)
+	}
+	if claims.ProfileId != "" {
+		c.Set("lockedProfileId", claims.ProfileId)
+	}
+}
+
+// resolveClaims validates a JWT, or looks up the owner of an API key
+func resolveClaims(c *gin.Context, client *mongo.Client, token string) (*utils.SignedDetails, error) {
+	if !utils.IsAPIKey(token) {
+		return utils.ValidateToken(token)
+	}
+
+	ctx, cancel := context.WithTimeout(c, 10*time.Second)
+	defer cancel()
+	return utils.AuthenticateAPIKey(ctx, client, token, c.ClientIP())
+}
+
+// AuthMiddleWare requires an access token (cookie or bearer JWT) or an API key
+func AuthMiddleWare(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token, err := utils.GetAccessToken(c)
 
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
+			apierror.Respond(c, apierror.Unauthorized("No token provided").WithCode(apierror.CodeNotAuthenticated))
 			return
 		}
 		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "No token provided"})
-			c.Abort()
+			apierror.Respond(c, apierror.Unauthorized("No token provided").WithCode(apierror.CodeNotAuthenticated))
 			return
 		}
-		claims, err := utils.ValidateToken(token)
+		claims, err := resolveClaims(c, client, token)
 
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			if utils.IsAPIKey(token) && err != utils.ErrInvalidAPIKey && err != utils.ErrUserNotFound {
+				apierror.Respond(c, apierror.Internal("Failed to verify API key"))
+				return
+			}
+			apierror.Respond(c, apierror.Unauthorized("Invalid token").WithCode(apierror.CodeInvalidToken))
+			return
+		}
+
+		// Suspended accounts and force-logged-out tokens are rejected even if the JWT is still valid
+		ctx, cancel := context.WithTimeout(c, 10*time.Second)
+		err = utils.CheckSession(ctx, client, claims)
+		cancel()
+		switch err {
+		case nil:
+		case utils.ErrAccountSuspended:
+			apierror.Respond(c, apierror.Forbidden("Account suspended").WithCode(apierror.CodeAccountSuspended))
+			return
+		case utils.ErrTokenRevoked, utils.ErrUserNotFound:
+			apierror.Respond(c, apierror.Unauthorized("Session is no longer valid").WithCode(apierror.CodeInvalidToken))
+			return
+		default:
+			apierror.Respond(c, apierror.Internal("Failed to verify session"))
 			return
 		}
-		c.Set("userId", claims.UserId)
-		c.Set("role", claims.Role)
+
+		if claims.ReadOnly {
+			switch c.Request.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				apierror.Respond(c, apierror.Forbidden("Impersonation sessions are read-only").WithCode(apierror.CodeImpersonationReadOnly))
+				return
+			}
+		}
+
+		setClaims(c, claims)
 
 		c.Next()
 
 	}
 }
+
+// OptionalAuthMiddleWare sets userId/role when a valid access token is present,
+// but lets anonymous requests through (used by public routes that personalize results).
+func OptionalAuthMiddleWare(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := utils.GetAccessToken(c)
+		if err == nil && token != "" {
+			if claims, err := resolveClaims(c, client, token); err == nil {
+				ctx, cancel := context.WithTimeout(c, 10*time.Second)
+				sessionErr := utils.CheckSession(ctx, client, claims)
+				cancel()
+				if sessionErr == nil {
+					setClaims(c, claims)
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
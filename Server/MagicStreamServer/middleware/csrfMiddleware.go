@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+)
+
+// CSRFMiddleware protects cookie-authenticated writes. The auth cookies are SameSite=None so the
+// frontend can be hosted on another site, which means browsers attach them to requests from any
+// site; such requests must come from an allowed origin and repeat the csrf_token cookie in the
+// X-CSRF-Token header (signed double-submit). Requests with header credentials are not checked.
+func CSRFMiddleware(origins *OriginPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if !utils.UsesCookieAuth(c) {
+			c.Next()
+			return
+		}
+
+		if origin := c.GetHeader("Origin"); origin != "" && !origins.Allowed(origin) && !sameHost(origin, c.Request.Host) {
+			apierror.Respond(c, apierror.Forbidden("Origin not allowed").
+				WithCode(apierror.CodeOriginNotAllowed).
+				WithDetail("origin", origin))
+			return
+		}
+
+		cookie, err := c.Cookie(utils.CSRFCookieName)
+		header := c.GetHeader(utils.CSRFHeaderName)
+		if err != nil || header == "" ||
+			subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 || !utils.ValidCSRFToken(header) {
+			apierror.Respond(c, apierror.Forbidden("Missing or invalid CSRF token, fetch one from GET /api/v1/auth/csrf").
+				WithCode(apierror.CodeCSRFTokenInvalid))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/metrics"
+)
+
+// The unversioned routes were deprecated when /api/v1 was introduced
+var legacyRoutesDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// legacyRoutesSunset is when the unversioned routes stop working: LEGACY_ROUTES_SUNSET (YYYY-MM-DD),
+// six months after deprecation by default
+var legacyRoutesSunset = sync.OnceValue(func() time.Time {
+	sunset := legacyRoutesDeprecatedAt.AddDate(0, 6, 0)
+	if value := os.Getenv("LEGACY_ROUTES_SUNSET"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			slog.Warn("Ignoring invalid LEGACY_ROUTES_SUNSET, expected YYYY-MM-DD", "value", value)
+			return sunset
+		}
+		sunset = parsed
+	}
+	return sunset
+})
+
+// DeprecatedRoute marks responses of a legacy route with the Deprecation (RFC 9745) and Sunset
+// (RFC 8594) headers and a Link to its successor, a /api/v1 path template such as /api/v1/movies/:imdb_id
+func DeprecatedRoute(successor string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(legacyRoutesDeprecatedAt.Unix(), 10)
+	sunset := legacyRoutesSunset().Format(http.TimeFormat)
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("Deprecation", deprecation)
+		header.Set("Sunset", sunset)
+		header.Add("Link", "<"+successorURL(successor, c.Params)+`>; rel="successor-version"`)
+
+		metrics.DeprecatedRouteRequests.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
+		c.Next()
+	}
+}
+
+// successorURL fills the successor's path parameters from the legacy request
+func successorURL(template string, params gin.Params) string {
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = url.PathEscape(params.ByName(segment[1:]))
+		}
+	}
+	return strings.Join(segments, "/")
+}
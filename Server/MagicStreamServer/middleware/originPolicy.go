@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Origins allowed when ALLOWED_ORIGINS is not set: the deployed frontends, plus the Vite
+// dev and preview servers in development
+var (
+	defaultProductionOrigins = []string{
+		"https://magic-stream-main.vercel.app",
+		"https://magicstream-main.vercel.app",
+		"https://magic-stream-main.onrender.com",
+		"https://magicstream-main.onrender.com",
+	}
+	defaultDevelopmentOrigins = []string{
+		"http://localhost:5173",
+		"http://localhost:4173",
+		"http://localhost:3000",
+	}
+)
+
+var localhostOrigin = regexp.MustCompile(`^http://(localhost|127\.0\.0\.1)(:[0-9]+)?$`)
+
+// OriginPolicy decides which browser origins may call the API with credentials. It backs both
+// the CORS allow-list and the Origin check on cookie-authenticated writes.
+type OriginPolicy struct {
+	exact    map[string]struct{}
+	patterns []*regexp.Regexp
+	// Development also trusts any localhost port
+	anyLocalhost bool
+}
+
+// LoadOriginPolicy reads the policy for the environment:
+//   - APP_ENV: "production" (default) allows only the listed origins; "development" adds any localhost port
+//   - ALLOWED_ORIGINS: comma-separated exact origins, replacing the defaults for the environment
+//   - ALLOWED_ORIGIN_PATTERNS: comma-separated origins where * stands for one DNS label,
+//     e.g. https://magic-stream-main-*.vercel.app for preview deployments
+func LoadOriginPolicy() *OriginPolicy {
+	env := strings.ToLower(strings.TrimSpace(os.Getenv("APP_ENV")))
+	switch env {
+	case "development", "production":
+	case "":
+		env = "production"
+	default:
+		slog.Warn("Unknown APP_ENV, using the production origin policy", "app_env", env)
+		env = "production"
+	}
+
+	policy := &OriginPolicy{exact: map[string]struct{}{}, anyLocalhost: env == "development"}
+
+	origins := splitList(os.Getenv("ALLOWED_ORIGINS"))
+	if len(origins) == 0 {
+		origins = defaultProductionOrigins
+		if env == "development" {
+			origins = append(append([]string{}, defaultDevelopmentOrigins...), defaultProductionOrigins...)
+		}
+	}
+	for _, origin := range origins {
+		policy.exact[strings.TrimSuffix(origin, "/")] = struct{}{}
+		slog.Info("Allowed origin", "origin", origin)
+	}
+
+	for _, pattern := range splitList(os.Getenv("ALLOWED_ORIGIN_PATTERNS")) {
+		parts := strings.Split(strings.TrimSuffix(pattern, "/"), "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		policy.patterns = append(policy.patterns, regexp.MustCompile("^"+strings.Join(parts, "[-a-z0-9]+")+"$"))
+		slog.Info("Allowed origin pattern", "pattern", pattern)
+	}
+
+	slog.Info("Origin policy loaded", "app_env", env, "any_localhost", policy.anyLocalhost)
+	return policy
+}
+
+// Allowed reports whether a browser origin may make credentialed requests
+func (p *OriginPolicy) Allowed(origin string) bool {
+	if _, ok := p.exact[origin]; ok {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return p.anyLocalhost && localhostOrigin.MatchString(origin)
+}
+
+// sameHost reports whether origin is the API's own host, e.g. the /docs page
+func sameHost(origin, host string) bool {
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host != "" && strings.EqualFold(parsed.Host, host)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ProfileMiddleware resolves the X-Profile-ID header to a profile owned by the authenticated user.
+// A missing header (or the user's own user_id) selects the account's primary profile.
+// Sessions locked to a kids profile (see SelectProfile) always get that profile, whatever the header says.
+// Anonymous requests (see OptionalAuthMiddleWare) pass through without a profile.
+func ProfileMiddleware(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		profileId := strings.TrimSpace(c.GetHeader("X-Profile-ID"))
+		if lockedId := utils.GetLockedProfileIdFromContext(c); lockedId != "" {
+			if profileId != "" && profileId != lockedId {
+				apierror.Respond(c, apierror.Forbidden("This session is locked to a kids profile; switching requires the parental PIN").
+					WithCode(apierror.CodeProfileLocked))
+				return
+			}
+			profileId = lockedId
+		}
+		if profileId == "" || profileId == userId {
+			c.Set("profileId", "")
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 10*time.Second)
+		defer cancel()
+
+		var profile models.Profile
+		err = database.OpenCollection("profiles", client).FindOne(ctx, bson.D{
+			{Key: "profile_id", Value: profileId},
+			{Key: "user_id", Value: userId},
+		}).Decode(&profile)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				apierror.Respond(c, apierror.Forbidden("Profile not found for this account"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal("Failed to load profile"))
+			return
+		}
+
+		c.Set("profileId", profile.ProfileID)
+		c.Set("isKidsProfile", profile.IsKids)
+
+		c.Next()
+	}
+}
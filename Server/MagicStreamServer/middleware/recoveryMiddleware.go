@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"io"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+)
+
+// RecoveryMiddleware turns a panicking handler into a 500 error envelope and logs the panic with its stack.
+// gin's own dump is discarded since it would print request headers, including cookies, unredacted.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		utils.RequestLogger(c).Error("Panic while handling request",
+			"panic", recovered,
+			"stack", string(debug.Stack()),
+		)
+		apierror.Respond(c, apierror.Internal("Internal server error"))
+	})
+}
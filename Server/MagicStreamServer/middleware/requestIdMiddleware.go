@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Client-supplied request IDs are echoed into logs, so only plain token characters are accepted
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIdMiddleware tags every request with an ID, reusing a client-supplied X-Request-ID when present
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader("X-Request-ID")
+		if !requestIdPattern.MatchString(requestId) {
+			buf := make([]byte, 16)
+			if _, err := rand.Read(buf); err == nil {
+				requestId = hex.EncodeToString(buf)
+			}
+		}
+
+		c.Set("requestId", requestId)
+		c.Header("X-Request-ID", requestId)
+		c.Next()
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+)
+
+// RequirePermission is a middleware that ensures the user's role grants every listed permission.
+// Passing it also lets API keys without the write scope use the route, see APIKeyWriteScope.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := utils.GetUserIdFromContext(c); err != nil {
+			apierror.Respond(c, apierror.ErrNotAuthenticated)
+			return
+		}
+
+		for _, permission := range permissions {
+			if !utils.HasPermission(c, permission) {
+				apierror.Respond(c, apierror.Forbidden("Missing required permission").
+					WithCode(apierror.CodeMissingPermission).
+					WithDetail("permission", permission))
+				return
+			}
+		}
+
+		c.Set("permissionGranted", true)
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/openapi"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+)
+
+// Bodies larger than this are passed through without being checked
+const maxContractBodyBytes = 1 << 20
+
+// contractRecorder keeps a copy of the response body while writing it through
+type contractRecorder struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *contractRecorder) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *contractRecorder) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *contractRecorder) capture(data []byte) {
+	if w.truncated || w.body.Len()+len(data) > maxContractBodyBytes {
+		w.truncated = true
+		return
+	}
+	w.body.Write(data)
+}
+
+// ResponseContractMiddleware checks every JSON response against the OpenAPI document and logs a
+// warning listing each divergence. It is meant for development and CI (OPENAPI_VALIDATE_RESPONSES=true)
+// since it copies every response body.
+func ResponseContractMiddleware(spec func() *openapi.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recorder := &contractRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		route := c.FullPath()
+		contentType := recorder.Header().Get("Content-Type")
+		if route == "" || recorder.truncated || !strings.HasPrefix(contentType, "application/json") {
+			return
+		}
+
+		status := recorder.Status()
+		problems := spec().ValidateResponse(c.Request.Method, openapi.PathFromGin(route), status, recorder.body.Bytes())
+		if len(problems) > 0 {
+			utils.RequestLogger(c).Warn("Response does not match the OpenAPI spec",
+				"method", c.Request.Method, "status", status, "problems", problems)
+		}
+	}
+}
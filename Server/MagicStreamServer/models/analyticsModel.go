@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AnalyticsTotals is a point-in-time snapshot of the dashboard headline numbers
+type AnalyticsTotals struct {
+	Movies              int64     `bson:"movies" json:"movies"`
+	Users               int64     `bson:"users" json:"users"`
+	ActiveSubscriptions int64     `bson:"active_subscriptions" json:"active_subscriptions"`
+	Ratings             int64     `bson:"ratings" json:"ratings"`
+	WatchlistItems      int64     `bson:"watchlist_items" json:"watchlist_items"`
+	Revenue             float64   `bson:"revenue" json:"revenue"` // all-time SUCCESS payments
+	ComputedAt          time.Time `bson:"computed_at" json:"computed_at"`
+}
+
+// AnalyticsDaily holds the facts for one UTC day in the analytics_daily collection.
+// Facts are recomputed for recent days by the rollup worker and can be rebuilt for any range.
+type AnalyticsDaily struct {
+	ID                  bson.ObjectID      `bson:"_id,omitempty" json:"_id,omitempty"`
+	Day                 time.Time          `bson:"day" json:"day"` // UTC midnight
+	Revenue             float64            `bson:"revenue" json:"revenue"`
+	Payments            int64              `bson:"payments" json:"payments"`
+	RevenueByPlan       map[string]float64 `bson:"revenue_by_plan" json:"revenue_by_plan"`
+	Signups             int64              `bson:"signups" json:"signups"`
+	NewSubscriptions    int64              `bson:"new_subscriptions" json:"new_subscriptions"`
+	SubscriptionsByPlan map[string]int64   `bson:"subscriptions_by_plan" json:"subscriptions_by_plan"`
+	Cancellations       int64              `bson:"cancellations" json:"cancellations"`
+	Ratings             int64              `bson:"ratings" json:"ratings"`
+	WatchlistAdds       int64              `bson:"watchlist_adds" json:"watchlist_adds"`
+	// Only set on days the worker ran; historical days rebuilt later have no snapshot
+	Totals    *AnalyticsTotals `bson:"totals,omitempty" json:"totals,omitempty"`
+	UpdatedAt time.Time        `bson:"updated_at" json:"updated_at"`
+}
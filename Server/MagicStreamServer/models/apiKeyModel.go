@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// APIKey is a long-lived credential for scripts and integrations, sent as "Authorization: Bearer <key>".
+// Only a hash of the key is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+	ID     bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID string        `bson:"user_id" json:"user_id" validate:"required"`
+	Name   string        `bson:"name" json:"name" validate:"required,min=1,max=100"`
+	// Prefix is the start of the key, enough for the owner to recognise it
+	Prefix  string `bson:"prefix" json:"prefix"`
+	KeyHash string `bson:"key_hash" json:"-"`
+	// Permissions the key may use; the owner's role must still grant them when the key is used
+	Permissions []string `bson:"permissions" json:"permissions"`
+	// Write lets the key change the owner's account; without it the key is read-only outside
+	// the routes its Permissions guard
+	Write      bool       `bson:"write" json:"write"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	LastUsedIP string     `bson:"last_used_ip,omitempty" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
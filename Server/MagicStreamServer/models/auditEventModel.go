@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AuditChange holds the before/after values of a single changed field
+type AuditChange struct {
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
+
+// AuditEvent is an append-only record of an admin mutation.
+// Each event stores the hash of the previous one so any edit or deletion breaks the chain.
+type AuditEvent struct {
+	ID         bson.ObjectID          `bson:"_id,omitempty" json:"_id,omitempty"`
+	Sequence   int64                  `bson:"sequence" json:"sequence"`
+	ActorID    string                 `bson:"actor_id" json:"actor_id"`
+	ActorRole  string                 `bson:"actor_role" json:"actor_role"`
+	Action     string                 `bson:"action" json:"action"`
+	TargetType string                 `bson:"target_type" json:"target_type"`
+	TargetID   string                 `bson:"target_id" json:"target_id"`
+	Changes    map[string]AuditChange `bson:"changes" json:"changes"`
+	IP         string                 `bson:"ip" json:"ip"`
+	RequestID  string                 `bson:"request_id" json:"request_id"`
+	CreatedAt  time.Time              `bson:"created_at" json:"created_at"`
+	PrevHash   string                 `bson:"prev_hash" json:"prev_hash"`
+	Hash       string                 `bson:"hash" json:"hash"`
+}
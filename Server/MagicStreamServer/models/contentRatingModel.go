@@ -0,0 +1,17 @@
+package models
+
+// ContentRatings lists supported maturity ratings from least to most restrictive audience
+var ContentRatings = []string{"G", "PG", "PG-13", "R", "NC-17"}
+
+// KidsMaxContentRating is the highest rating a kids profile may see
+const KidsMaxContentRating = "PG"
+
+// ContentRatingRank returns the position of rating in ContentRatings, or -1 if unknown
+func ContentRatingRank(rating string) int {
+	for i, r := range ContentRatings {
+		if r == rating {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type EmailChange struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID    string        `bson:"user_id" json:"user_id" validate:"required"`
+	OldEmail  string        `bson:"old_email" json:"old_email" validate:"required,email"`
+	NewEmail  string        `bson:"new_email" json:"new_email" validate:"required,email"`
+	Token     string        `bson:"token" json:"token" validate:"required"`
+	ExpiresAt time.Time     `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time    `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+}
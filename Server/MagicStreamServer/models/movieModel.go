@@ -23,4 +23,11 @@ type Movie struct {
 	Genre       []Genre       `bson:"genre" json:"genre" validate:"required,dive"`
 	AdminReview string        `bson:"admin_review" json:"admin_review"`
 	Ranking     Ranking       `bson:"ranking" json:"ranking" validate:"required"`
+	// Maturity rating (G, PG, PG-13, R, NC-17); unrated titles are hidden from restricted profiles
+	ContentRating string `bson:"content_rating,omitempty" json:"content_rating,omitempty" validate:"omitempty,oneof=G PG PG-13 R NC-17"`
+	// User rating aggregates, maintained on every rating change and repaired by the reconciliation job
+	AvgRating       float64          `bson:"avg_rating" json:"avg_rating"`
+	RatingCount     int64            `bson:"rating_count" json:"rating_count"`
+	RatingSum       int64            `bson:"rating_sum" json:"-"`
+	RatingHistogram map[string]int64 `bson:"rating_histogram,omitempty" json:"rating_histogram,omitempty"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Profile is an additional viewer under an account. The account owner's own
+// profile is implicit: data stored without a profile_id belongs to it.
+type Profile struct {
+	ID               bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	ProfileID        string        `bson:"profile_id" json:"profile_id"`
+	UserID           string        `bson:"user_id" json:"user_id" validate:"required"`
+	Name             string        `bson:"name" json:"name" validate:"required,min=1,max=50"`
+	AvatarURL        string        `bson:"avatar_url,omitempty" json:"avatar_url,omitempty" validate:"omitempty,url"`
+	IsKids           bool          `bson:"is_kids" json:"is_kids"`
+	MaxContentRating string        `bson:"max_content_rating,omitempty" json:"max_content_rating,omitempty"` // "" = unrestricted
+	FavouriteGenres  []Genre       `bson:"favourite_genres" json:"favourite_genres" validate:"dive"`
+	CreatedAt        time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+// ProfileResponse is the API shape for both the primary and additional profiles
+type ProfileResponse struct {
+	ProfileID        string  `json:"profile_id"`
+	Name             string  `json:"name"`
+	AvatarURL        string  `json:"avatar_url,omitempty"`
+	IsKids           bool    `json:"is_kids"`
+	IsPrimary        bool    `json:"is_primary"`
+	MaxContentRating string  `json:"max_content_rating,omitempty"`
+	FavouriteGenres  []Genre `json:"favourite_genres"`
+}
@@ -9,11 +9,48 @@ import (
 type Rating struct {
 	ID         bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	UserID     string        `bson:"user_id" json:"user_id" validate:"required"`
+	ProfileID  string        `bson:"profile_id,omitempty" json:"profile_id,omitempty"` // empty = primary profile
 	ImdbID     string        `bson:"imdb_id" json:"imdb_id" validate:"required"`
 	Rating     int           `bson:"rating" json:"rating" validate:"required,min=1,max=5"`
 	ReviewText string        `bson:"review_text,omitempty" json:"review_text,omitempty"`
 	CreatedAt  time.Time     `bson:"created_at" json:"created_at"`
 	UpdatedAt  time.Time     `bson:"updated_at" json:"updated_at"`
+	// Moderation state of the review; empty is treated as visible (ratings created before moderation)
+	ModerationStatus string     `bson:"moderation_status,omitempty" json:"moderation_status,omitempty"`
+	ModerationReason string     `bson:"moderation_reason,omitempty" json:"moderation_reason,omitempty"`
+	ReportCount      int        `bson:"report_count,omitempty" json:"report_count,omitempty"`
+	HelpfulCount     int        `bson:"helpful_count,omitempty" json:"helpful_count,omitempty"`
+	ModeratedBy      string     `bson:"moderated_by,omitempty" json:"-"`
+	ModeratedAt      *time.Time `bson:"moderated_at,omitempty" json:"moderated_at,omitempty"`
+}
+
+// ReviewVote records one user marking a review as helpful
+type ReviewVote struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	RatingID  bson.ObjectID `bson:"rating_id" json:"rating_id"`
+	UserID    string        `bson:"user_id" json:"user_id"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+}
+
+const (
+	ReviewStatusVisible = "visible"
+	ReviewStatusPending = "pending"
+	// Reported reviews wait for a moderator like pending ones, but their stars keep counting:
+	// reports alone never change a movie's rating
+	ReviewStatusReported = "reported"
+	ReviewStatusHidden   = "hidden"
+)
+
+// UnpublishedReviewStatuses are the statuses whose review text is not shown publicly
+var UnpublishedReviewStatuses = []string{ReviewStatusPending, ReviewStatusReported, ReviewStatusHidden}
+
+// ReviewReport records one user flagging a review; a user can report a review once
+type ReviewReport struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	RatingID  bson.ObjectID `bson:"rating_id" json:"rating_id"`
+	UserID    string        `bson:"user_id" json:"user_id"`
+	Reason    string        `bson:"reason,omitempty" json:"reason,omitempty" validate:"max=500"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
 }
 
 type RatingAggregate struct {
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	ReportFrequencyDaily   = "daily"
+	ReportFrequencyWeekly  = "weekly"
+	ReportFrequencyMonthly = "monthly"
+
+	ReportFormatCSV = "csv"
+	ReportFormatPDF = "pdf"
+
+	ReportSectionStats         = "stats"
+	ReportSectionRevenue       = "revenue"
+	ReportSectionSubscriptions = "subscriptions"
+	ReportSectionPlans         = "plans"
+)
+
+// AllReportSections lists the analytics a scheduled report can include, in render order
+var AllReportSections = []string{
+	ReportSectionStats,
+	ReportSectionRevenue,
+	ReportSectionSubscriptions,
+	ReportSectionPlans,
+}
+
+// ScheduledReport is an admin-defined analytics report generated on a fixed cadence
+type ScheduledReport struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string        `bson:"name" json:"name" validate:"required,min=2,max=100"`
+	Frequency  string        `bson:"frequency" json:"frequency" validate:"required,oneof=daily weekly monthly"`
+	Sections   []string      `bson:"sections" json:"sections" validate:"required,min=1,dive,oneof=stats revenue subscriptions plans"`
+	Formats    []string      `bson:"formats" json:"formats" validate:"required,min=1,dive,oneof=csv pdf"`
+	Recipients []string      `bson:"recipients" json:"recipients" validate:"max=20,dive,email"`
+	Enabled    bool          `bson:"enabled" json:"enabled"`
+	CreatedBy  string        `bson:"created_by" json:"created_by"`
+	NextRunAt  time.Time     `bson:"next_run_at" json:"next_run_at"`
+	LastRunAt  *time.Time    `bson:"last_run_at,omitempty" json:"last_run_at,omitempty"`
+	LastError  string        `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt  time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+// GeneratedReport is one rendered file of a scheduled report, stored on local disk
+type GeneratedReport struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	ReportID    bson.ObjectID `bson:"report_id" json:"report_id"`
+	ReportName  string        `bson:"report_name" json:"report_name"`
+	Format      string        `bson:"format" json:"format"`
+	FileName    string        `bson:"file_name" json:"file_name"`
+	SizeBytes   int64         `bson:"size_bytes" json:"size_bytes"`
+	PeriodStart time.Time     `bson:"period_start" json:"period_start"`
+	PeriodEnd   time.Time     `bson:"period_end" json:"period_end"` // exclusive
+	Trigger     string        `bson:"trigger" json:"trigger"`       // "schedule" or "manual"
+	EmailedTo   []string      `bson:"emailed_to,omitempty" json:"emailed_to,omitempty"`
+	CreatedAt   time.Time     `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Named permissions checked by admin routes
+const (
+	PermissionMoviesWrite     = "movies:write"
+	PermissionReviewsRank     = "reviews:rank"
+	PermissionReviewsModerate = "reviews:moderate"
+	PermissionUsersManage     = "users:manage"
+	PermissionBillingManage   = "billing:manage"
+	PermissionAnalyticsRead   = "analytics:read"
+	PermissionReportsManage   = "reports:manage"
+	PermissionAuditRead       = "audit:read"
+)
+
+var AllPermissions = []string{
+	PermissionMoviesWrite,
+	PermissionReviewsRank,
+	PermissionReviewsModerate,
+	PermissionUsersManage,
+	PermissionBillingManage,
+	PermissionAnalyticsRead,
+	PermissionReportsManage,
+	PermissionAuditRead,
+}
+
+// Role is a named, configurable set of permissions assigned to users via User.Role
+type Role struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Name        string        `bson:"name" json:"name" validate:"required,min=2,max=50"`
+	Description string        `bson:"description,omitempty" json:"description,omitempty"`
+	Permissions []string      `bson:"permissions" json:"permissions"`
+	// Built-in roles are seeded on startup; ADMIN and USER cannot be edited or deleted
+	BuiltIn   bool      `bson:"built_in" json:"built_in"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// DefaultRoles are seeded into the roles collection when missing
+var DefaultRoles = []Role{
+	{Name: "ADMIN", Description: "Full administrative access", Permissions: AllPermissions, BuiltIn: true},
+	{Name: "USER", Description: "Regular subscriber", Permissions: []string{}, BuiltIn: true},
+	{Name: "CONTENT_EDITOR", Description: "Manages the catalog and review rankings", Permissions: []string{PermissionMoviesWrite, PermissionReviewsRank, PermissionReviewsModerate}, BuiltIn: true},
+	{Name: "BILLING_ADMIN", Description: "Manages subscriptions and payments", Permissions: []string{PermissionBillingManage, PermissionAnalyticsRead}, BuiltIn: true},
+	{Name: "ANALYST", Description: "Read-only access to analytics", Permissions: []string{PermissionAnalyticsRead}, BuiltIn: true},
+}
+
+// DefaultRolePermissions returns the seeded permissions for a built-in role (nil if unknown)
+func DefaultRolePermissions(name string) []string {
+	for _, role := range DefaultRoles {
+		if role.Name == name {
+			return role.Permissions
+		}
+	}
+	return nil
+}
+
+// IsValidPermission reports whether p is a known permission
+func IsValidPermission(p string) bool {
+	for _, known := range AllPermissions {
+		if known == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ZeroResultSearch counts catalog searches that matched no titles, one document per query per day
+type ZeroResultSearch struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Query      string        `bson:"query" json:"query"` // lowercased, whitespace-collapsed
+	Day        time.Time     `bson:"day" json:"day"`     // UTC midnight
+	Count      int64         `bson:"count" json:"count"`
+	LastSeenAt time.Time     `bson:"last_seen_at" json:"last_seen_at"`
+}
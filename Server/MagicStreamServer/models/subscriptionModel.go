@@ -6,6 +6,14 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+// Why a subscription was canceled. "replaced" marks a plan switch, which is not churn.
+const (
+	CancelReasonUser           = "user"
+	CancelReasonAdmin          = "admin"
+	CancelReasonReplaced       = "replaced"
+	CancelReasonAccountDeleted = "account_deleted"
+)
+
 type Subscription struct {
 	ID            bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	UserID        string        `bson:"user_id" json:"user_id" validate:"required"`
@@ -16,6 +24,8 @@ type Subscription struct {
 	NextBillingAt time.Time     `bson:"next_billing_at" json:"next_billing_at"`
 	PaymentMethod string        `bson:"payment_method" json:"payment_method"` // "CARD", "PAYPAL" (simulated)
 	AutoRenew     bool          `bson:"auto_renew" json:"auto_renew"`
+	CanceledAt    *time.Time    `bson:"canceled_at,omitempty" json:"canceled_at,omitempty"`
+	CancelReason  string        `bson:"cancel_reason,omitempty" json:"cancel_reason,omitempty"`
 	CreatedAt     time.Time     `bson:"created_at" json:"created_at"`
 	UpdatedAt     time.Time     `bson:"updated_at" json:"updated_at"`
 }
@@ -13,17 +13,37 @@ type User struct {
 	LastName        string        `json:"last_name" bson:"last_name" validate:"required,min=2,max=100"`
 	Email           string        `json:"email" bson:"email" validate:"required,email"`
 	Password        string        `json:"password" bson:"password" validate:"required,min=6"`
-	Role            string        `json:"role" bson:"role" validate:"oneof=ADMIN USER"`
+	Role            string        `json:"role" bson:"role" validate:"omitempty,max=50"`
 	CreatedAt       time.Time     `json:"created_at" bson:"created_at"`
 	UpdatedAt       time.Time     `json:"update_at" bson:"update_at"`
 	Token           string        `json:"token" bson:"token"`
 	RefreshToken    string        `json:"refresh_token" bson:"refresh_token"`
 	FavouriteGenres []Genre       `json:"favourite_genres" bson:"favourite_genres" validate:"required,dive"`
 	EmailVerified   bool          `json:"email_verified" bson:"email_verified"`
+	// Set when the user requested account deletion; data is purged after DeletionScheduledAt
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty" bson:"deletion_requested_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" bson:"deletion_scheduled_at,omitempty"`
+	// Parental controls for the primary profile; the PIN is a bcrypt hash and never serialized
+	MaxContentRating string `json:"max_content_rating,omitempty" bson:"max_content_rating,omitempty"`
+	ParentalPin      string `json:"-" bson:"parental_pin,omitempty"`
+	// Account status set by admins; an empty status means ACTIVE
+	Status           string     `json:"status,omitempty" bson:"status,omitempty"`
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty" bson:"suspended_at,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty" bson:"suspension_reason,omitempty"`
+	// Tokens issued before this instant are rejected (forced logout)
+	TokensValidAfter *time.Time `json:"-" bson:"tokens_valid_after,omitempty"`
 }
+
+const (
+	UserStatusActive    = "ACTIVE"
+	UserStatusSuspended = "SUSPENDED"
+)
+
 type UserLogin struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
+	// ReturnTokens also returns the tokens in the body, for clients that send them as Bearer tokens
+	ReturnTokens bool `json:"return_tokens"`
 }
 type UserResponse struct {
 	UserId          string  `json:"user_id"`
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type WatchProgress struct {
+	ID              bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID          string        `bson:"user_id" json:"user_id" validate:"required"`
+	ProfileID       string        `bson:"profile_id,omitempty" json:"profile_id,omitempty"` // empty = primary profile
+	ImdbID          string        `bson:"imdb_id" json:"imdb_id" validate:"required"`
+	PositionSeconds float64       `bson:"position_seconds" json:"position_seconds" validate:"min=0"`
+	DurationSeconds float64       `bson:"duration_seconds" json:"duration_seconds" validate:"gt=0"`
+	Finished        bool          `bson:"finished" json:"finished"`
+	FinishedAt      *time.Time    `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	LastWatchedAt   time.Time     `bson:"last_watched_at" json:"last_watched_at"`
+	CreatedAt       time.Time     `bson:"created_at" json:"created_at"`
+}
+
+// WatchProgressWithMovie is a combined structure for continue-watching and history responses
+type WatchProgressWithMovie struct {
+	WatchProgress
+	ProgressPercent float64 `json:"progress_percent"`
+	Movie           *Movie  `json:"movie,omitempty"`
+}
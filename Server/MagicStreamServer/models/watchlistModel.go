@@ -9,6 +9,7 @@ import (
 type Watchlist struct {
 	ID        bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	UserID    string        `bson:"user_id" json:"user_id" validate:"required"`
+	ProfileID string        `bson:"profile_id,omitempty" json:"profile_id,omitempty"` // empty = primary profile
 	ImdbID    string        `bson:"imdb_id" json:"imdb_id" validate:"required"`
 	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
 }
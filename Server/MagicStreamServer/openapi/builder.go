@@ -0,0 +1,252 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+)
+
+// Auth says which credentials an operation accepts
+type Auth int
+
+const (
+	AuthNone Auth = iota
+	// AuthOptional routes work anonymously and personalise the response when logged in
+	AuthOptional
+	AuthRequired
+)
+
+// Operation documents one route; the method and path come from the router
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	Auth        Auth
+	// Permission required by RequirePermission, listed in the description
+	Permission string
+	Query      []Param
+	// Request and Response are zero values of the body types; a nil Response is a free-form object
+	// and a OneOf lists the bodies a response can take
+	Request  interface{}
+	Response interface{}
+	// RequestOptional marks a request body that may be omitted
+	RequestOptional bool
+	// Status of a successful response, 200 when zero
+	Status int
+	// AlsoStatus lists other statuses answered with the same body, e.g. 200 for an upsert that creates with 201
+	AlsoStatus []int
+	// ContentType of a non-JSON success response, e.g. a file download
+	ContentType string
+	Deprecated  bool
+}
+
+// OneOf documents a body that is one of several types
+type OneOf []interface{}
+
+// Param is a query string parameter
+type Param struct {
+	Name        string
+	Type        string // string (default), integer, number or boolean
+	Description string
+	Required    bool
+}
+
+// errorResponse mirrors the envelope written by apierror.Respond
+type errorResponse struct {
+	Error     string                 `json:"error" validate:"required"`
+	Code      apierror.Code          `json:"code" validate:"required"`
+	Fields    []apierror.FieldError  `json:"fields,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+const (
+	cookieAuthScheme = "cookieAuth"
+	bearerAuthScheme = "bearerAuth"
+	profileHeader    = "X-Profile-ID"
+	csrfHeader       = "X-CSRF-Token"
+)
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// PathFromGin converts a gin route pattern to an OpenAPI path template: /movie/:imdb_id -> /movie/{imdb_id}
+func PathFromGin(path string) string {
+	return ginParam.ReplaceAllString(path, "{$1}")
+}
+
+// Build documents every route in the router's table. Routes missing from ops are still listed,
+// with a generic response, so the document always covers the full surface of the API.
+// aliases maps deprecated "METHOD /path" keys to the key of the route they stand in for; an
+// alias without its own entry in ops is documented like its successor.
+func Build(info Info, routes gin.RoutesInfo, ops map[string]Operation, aliases map[string]string) *Document {
+	registry := newSchemaRegistry()
+	errorSchema := registry.schemaFor(errorResponse{})
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]*SecurityScheme{
+				cookieAuthScheme: {
+					Type:        "apiKey",
+					In:          "cookie",
+					Name:        "access_token",
+					Description: "JWT access token set by POST /api/v1/auth/login and renewed by POST /api/v1/auth/refresh",
+				},
+			},
+		},
+	}
+
+	tags := map[string]bool{}
+	usedIDs := map[string]bool{}
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		op, documented := ops[key]
+		successor, aliased := aliases[key]
+		if aliased {
+			if !documented {
+				op = ops[successor]
+			}
+			op.Deprecated = true
+			op.Description = strings.TrimSpace("Deprecated alias of `" + successor + "`.\n\n" + op.Description)
+		}
+		path := PathFromGin(route.Path)
+
+		operation := &OperationObject{
+			OperationID: operationID(route, aliased, usedIDs),
+			Summary:     op.Summary,
+			Description: describe(op),
+			Deprecated:  op.Deprecated,
+			Responses:   map[string]*Response{},
+		}
+		if op.Tag != "" {
+			operation.Tags = []string{op.Tag}
+			tags[op.Tag] = true
+		}
+
+		for _, name := range ginParam.FindAllStringSubmatch(route.Path, -1) {
+			operation.Parameters = append(operation.Parameters, ParameterObject{
+				Name: name[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+			})
+		}
+		for _, q := range op.Query {
+			paramType := q.Type
+			if paramType == "" {
+				paramType = "string"
+			}
+			operation.Parameters = append(operation.Parameters, ParameterObject{
+				Name: q.Name, In: "query", Description: q.Description, Required: q.Required,
+				Schema: &Schema{Type: paramType},
+			})
+		}
+
+		switch op.Auth {
+		case AuthRequired:
+			operation.Security = []map[string][]string{{cookieAuthScheme: {}}, {bearerAuthScheme: {}}}
+		case AuthOptional:
+			// An empty requirement makes the credentials optional
+			operation.Security = []map[string][]string{{cookieAuthScheme: {}}, {bearerAuthScheme: {}}, {}}
+		}
+		if op.Auth != AuthNone {
+			operation.Parameters = append(operation.Parameters, ParameterObject{
+				Name: profileHeader, In: "header",
+				Description: "Viewer profile to act as; the primary profile when omitted",
+				Schema:      &Schema{Type: "string"},
+			})
+		}
+
+		switch route.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			operation.Parameters = append(operation.Parameters, ParameterObject{
+				Name: csrfHeader, In: "header",
+				Description: "Required when authenticating with the auth cookies; see GET /api/v1/auth/csrf",
+				Schema:      &Schema{Type: "string"},
+			})
+		}
+
+		if op.Request != nil {
+			operation.RequestBody = &RequestBody{
+				Required: !op.RequestOptional,
+				Content:  map[string]MediaType{"application/json": {Schema: registry.schemaFor(op.Request)}},
+			}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		var body map[string]MediaType
+		switch {
+		case op.ContentType != "":
+			body = map[string]MediaType{op.ContentType: {Schema: &Schema{Type: "string", Format: "binary"}}}
+		case status != http.StatusNoContent:
+			body = map[string]MediaType{"application/json": {Schema: registry.schemaFor(op.Response)}}
+		}
+		for _, code := range append([]int{status}, op.AlsoStatus...) {
+			operation.Responses[strconv.Itoa(code)] = &Response{Description: http.StatusText(code), Content: body}
+		}
+		operation.Responses["default"] = &Response{
+			Description: "Error",
+			Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][lowerMethod(route.Method)] = operation
+	}
+
+	for name := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: name})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+
+	doc.Components.Schemas = registry.schemas
+	return doc
+}
+
+func describe(op Operation) string {
+	if op.Permission == "" {
+		return op.Description
+	}
+	requires := "Requires the `" + op.Permission + "` permission."
+	if op.Description == "" {
+		return requires
+	}
+	return op.Description + "\n\n" + requires
+}
+
+// operationID names an operation after its controller (GetMovie), falling back to method and path
+// for aliases and for handlers that are shared, built by an unexported helper or not controllers at all
+func operationID(route gin.RouteInfo, alias bool, used map[string]bool) string {
+	name := ""
+	if _, handler, ok := strings.Cut(route.Handler, "/controllers."); ok && !alias {
+		name = strings.TrimSuffix(handler, ".func1")
+	}
+
+	if name == "" || strings.Contains(name, ".") || !startsUpper(name) || used[name] {
+		name = strings.ToLower(route.Method)
+		for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool {
+			return r == '/' || r == ':' || r == '*' || r == '-' || r == '_'
+		}) {
+			name += exportedName(part)
+		}
+	}
+	used[name] = true
+	return name
+}
+
+func startsUpper(s string) bool {
+	return s != "" && s[0] >= 'A' && s[0] <= 'Z'
+}
+
+func lowerMethod(method string) string {
+	return strings.ToLower(method)
+}
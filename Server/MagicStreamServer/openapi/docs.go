@@ -0,0 +1,9 @@
+package openapi
+
+import _ "embed"
+
+// DocsPage is a self-contained API browser that renders the openapi.json served next to it.
+// It loads no external scripts or stylesheets, so it also works offline.
+//
+//go:embed docs.html
+var DocsPage []byte
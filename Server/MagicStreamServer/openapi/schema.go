@@ -0,0 +1,287 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const componentPrefix = "#/components/schemas/"
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	objectIDType  = reflect.TypeOf(bson.ObjectID{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaRegistry turns Go types into schemas, registering named structs as components
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// schemaFor returns the schema for the type of v; nil v means a free-form JSON object
+func (r *schemaRegistry) schemaFor(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{Type: "object"}
+	}
+	if alternatives, ok := v.(OneOf); ok {
+		s := &Schema{}
+		for _, alternative := range alternatives {
+			s.OneOf = append(s.OneOf, r.schemaFor(alternative))
+		}
+		return s
+	}
+	return r.schemaOf(reflect.TypeOf(v))
+}
+
+func (r *schemaRegistry) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == objectIDType:
+		return &Schema{Type: "string", Description: "MongoDB ObjectID (24 hex characters)"}
+	case t.Kind() == reflect.Struct && reflect.PointerTo(t).Implements(marshalerType):
+		// A struct with its own MarshalJSON doesn't encode as its fields, so accept any value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(r.schemaOf(t.Elem()))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		// nil slices encode as null
+		return &Schema{Type: "array", Items: r.schemaOf(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: r.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schemaOf(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return &Schema{Ref: componentPrefix + r.component(t)}
+	}
+	// interface{} and anything else accepts any value
+	return &Schema{}
+}
+
+// component registers a named struct once and returns its component name
+func (r *schemaRegistry) component(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+
+	name := exportedName(t.Name())
+	if _, taken := r.schemas[name]; taken {
+		// Same type name in two packages, e.g. models.Role and controllers.role
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = exportedName(pkg) + name
+	}
+
+	// Reserve the name before descending so recursive types terminate
+	r.names[t] = name
+	r.schemas[name] = &Schema{}
+	*r.schemas[name] = *r.structSchema(t)
+	return name
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, Closed: true}
+	r.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+func (r *schemaRegistry) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts := parseJSONTag(field.Tag.Get("json"))
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		// Embedded structs without a json name are flattened, like encoding/json does
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				r.addFields(s, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		var prop *Schema
+		if strings.Contains(opts, "string") {
+			prop = &Schema{Type: "string"}
+		} else {
+			prop = r.schemaOf(field.Type)
+		}
+
+		rules := fieldRules(field)
+		if applyRules(prop, rules) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// fieldRules returns the validator rules that apply to the field itself, not to its elements
+func fieldRules(field reflect.StructField) []string {
+	var rules []string
+	for _, tag := range []string{field.Tag.Get("validate"), field.Tag.Get("binding")} {
+		if tag == "" {
+			continue
+		}
+		if i := strings.Index(tag, "dive"); i >= 0 {
+			tag = tag[:i]
+		}
+		for _, rule := range strings.Split(tag, ",") {
+			if rule = strings.TrimSpace(rule); rule != "" {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+// applyRules maps validator rules onto schema keywords and reports whether the field is required
+func applyRules(s *Schema, rules []string) bool {
+	required := false
+	target := s
+	if s.Ref != "" || len(s.AllOf) > 0 {
+		// Keywords next to a $ref are ignored by OpenAPI 3.0 tooling
+		target = nil
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "required" {
+			required = true
+		}
+		if target == nil {
+			continue
+		}
+
+		switch name {
+		case "email":
+			target.Format = "email"
+		case "url", "http_url":
+			target.Format = "uri"
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				target.Enum = append(target.Enum, enumValue(target.Type, value))
+			}
+		case "min", "gte":
+			setBound(target, param, true)
+		case "max", "lte":
+			setBound(target, param, false)
+		case "len":
+			setBound(target, param, true)
+			setBound(target, param, false)
+		}
+	}
+	return required
+}
+
+func setBound(s *Schema, param string, lower bool) {
+	switch s.Type {
+	case "string", "array":
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		switch {
+		case s.Type == "string" && lower:
+			s.MinLength = &n
+		case s.Type == "string":
+			s.MaxLength = &n
+		case lower:
+			s.MinItems = &n
+		default:
+			s.MaxItems = &n
+		}
+	case "integer", "number":
+		f, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		if lower {
+			s.Minimum = &f
+		} else {
+			s.Maximum = &f
+		}
+	}
+}
+
+func enumValue(schemaType, value string) interface{} {
+	switch schemaType {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// nullable marks s as accepting null; a $ref can't carry siblings, so it is wrapped in allOf
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AllOf: []*Schema{s}, Nullable: true}
+	}
+	s.Nullable = true
+	return s
+}
+
+func parseJSONTag(tag string) (name, opts string) {
+	name, opts, _ = strings.Cut(tag, ",")
+	return name, opts
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, componentPrefix)
+}
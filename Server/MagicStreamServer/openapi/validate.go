@@ -0,0 +1,179 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ValidateResponse checks a JSON response body against the documented response for the
+// operation and status, returning one message per divergence. Statuses the operation doesn't
+// document are checked against its default (error) response.
+func (d *Document) ValidateResponse(method, path string, status int, body []byte) []string {
+	op := d.Operation(method, path)
+	if op == nil {
+		return []string{fmt.Sprintf("%s %s is not documented", method, path)}
+	}
+
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if status < 400 {
+			return []string{fmt.Sprintf("status %d is not documented", status)}
+		}
+		response = op.Responses["default"]
+	}
+	if response == nil || len(response.Content) == 0 {
+		if len(bytes.TrimSpace(body)) > 0 {
+			return []string{fmt.Sprintf("status %d is documented without a body", status)}
+		}
+		return nil
+	}
+
+	media, ok := response.Content["application/json"]
+	if !ok {
+		// Binary downloads aren't checked
+		return nil
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return []string{"response body is not valid JSON"}
+	}
+
+	var problems []string
+	d.validate(media.Schema, value, "$", &problems)
+	return problems
+}
+
+func (d *Document) validate(s *Schema, value interface{}, at string, problems *[]string) {
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		d.validate(d.resolve(s), value, at, problems)
+		return
+	}
+	if value == nil {
+		if !s.Nullable && (s.Type != "" || len(s.AllOf) > 0) {
+			*problems = append(*problems, at+": null is not allowed")
+		}
+		return
+	}
+	for _, part := range s.AllOf {
+		d.validate(part, value, at, problems)
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, alternative := range s.OneOf {
+			var mismatches []string
+			d.validate(alternative, value, at, &mismatches)
+			if len(mismatches) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			*problems = append(*problems, fmt.Sprintf("%s: matches %d of the %d oneOf schemas", at, matches, len(s.OneOf)))
+		}
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected object, got %s", jsonType(value))
+			return
+		}
+		for _, name := range s.Required {
+			if _, present := object[name]; !present {
+				fail("missing required property %q", name)
+			}
+		}
+
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := at + "." + name
+			if prop, declared := s.Properties[name]; declared {
+				d.validate(prop, object[name], child, problems)
+				continue
+			}
+			switch {
+			case s.AdditionalProperties != nil:
+				d.validate(s.AdditionalProperties, object[name], child, problems)
+			case s.Closed:
+				*problems = append(*problems, child+": property is not documented")
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("expected array, got %s", jsonType(value))
+			return
+		}
+		for i, item := range items {
+			d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i), problems)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			fail("expected string, got %s", jsonType(value))
+			return
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean, got %s", jsonType(value))
+			return
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			fail("expected %s, got %s", s.Type, jsonType(value))
+			return
+		}
+		f, err := number.Float64()
+		if err != nil || (s.Type == "integer" && f != math.Trunc(f)) {
+			fail("expected %s, got %s", s.Type, number)
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		fail("value %v is not one of %v", value, s.Enum)
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	}
+	return "null"
+}
@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/x/bsonx/bsoncore"
+)
+
+type sentMail struct {
+	to, subject, body string
+}
+
+// fakeMailer records what it sends instead of logging it
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+}
+
+func (m *fakeMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentMail{to: to, subject: subject, body: body})
+	return nil
+}
+
+// useFakeMailer swaps utils.DefaultMailer for a fakeMailer for the rest of the test
+func useFakeMailer(t *testing.T) *fakeMailer {
+	t.Helper()
+	mailer := &fakeMailer{}
+	previous := utils.DefaultMailer
+	utils.DefaultMailer = mailer
+	t.Cleanup(func() { utils.DefaultMailer = previous })
+	return mailer
+}
+
+func TestEmailChangeTokenIsOnlyMailed(t *testing.T) {
+	mailer := useFakeMailer(t)
+	router, deployment := newTestRouter(t, contractFixtures(t))
+	// Nobody else uses the new address
+	deployment.Reply = func(command bsoncore.Document) bson.D {
+		if collection, _ := command.Lookup("aggregate").StringValueOK(); collection != "users" {
+			return nil
+		}
+		return bson.D{{Key: "ok", Value: 1}, {Key: "cursor", Value: bson.D{
+			{Key: "id", Value: int64(0)},
+			{Key: "ns", Value: "contract.users"},
+			{Key: "firstBatch", Value: bson.A{}},
+		}}}
+	}
+
+	rec := serve(router, http.MethodPost, "/api/v1/me/email", bearer(t, "ADMIN", models.AllPermissions),
+		`{"new_email":"new@example.com","current_password":"`+contractPassword+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(mailer.sent) != 1 || mailer.sent[0].to != "new@example.com" {
+		t.Fatalf("mailed %+v, want one mail to new@example.com", mailer.sent)
+	}
+	token := mailer.sent[0].body[strings.LastIndex(mailer.sent[0].body, " ")+1:]
+
+	if strings.Contains(rec.Body.String(), token) {
+		t.Errorf("response carries the token: %s", rec.Body.String())
+	}
+	inserts := deployment.Commands("insert", "email_changes")
+	if len(inserts) != 1 || inserts[0].Lookup("documents", "0", "token").StringValue() != token {
+		t.Errorf("the mailed token %q is not the one stored", token)
+	}
+}
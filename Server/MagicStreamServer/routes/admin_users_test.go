@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database/mongotest"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+)
+
+// bearer issues an access token for contractUserID carrying permissions
+func bearer(t *testing.T, role string, permissions []string) string {
+	t.Helper()
+	access, _, err := utils.GenerateAllTokens(contractEmail, "Ada", "Admin", role, contractUserID, "", permissions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + access
+}
+
+func serve(router *gin.Engine, method, path, authorization, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// sessionRevocations counts the users updates that set tokens_valid_after
+func sessionRevocations(deployment *mongotest.Deployment) int {
+	revocations := 0
+	for _, command := range deployment.Commands("update", "users") {
+		updates, ok := command.Lookup("updates").ArrayOK()
+		if !ok {
+			continue
+		}
+		values, _ := updates.Values()
+		for _, update := range values {
+			if _, err := update.Document().LookupErr("u", "$set", "tokens_valid_after"); err == nil {
+				revocations++
+			}
+		}
+	}
+	return revocations
+}
+
+func TestChangingAUsersRoleSignsThemOut(t *testing.T) {
+	router, deployment := newTestRouter(t, contractFixtures(t))
+
+	rec := serve(router, http.MethodPatch, "/api/v1/admin/users/u2/role",
+		bearer(t, "ADMIN", models.AllPermissions), `{"role":"ADMIN"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if n := sessionRevocations(deployment); n != 1 {
+		t.Errorf("%d session revocations, want 1", n)
+	}
+}
+
+func TestRemovingRolePermissionsSignsOutItsUsers(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions string
+		revocations int
+	}{
+		{"permission removed", `["movies:write"]`, 1},
+		{"nothing removed", `["` + strings.Join(models.AllPermissions, `","`) + `"]`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The roles fixture is what the SUPPORT role held before the edit
+			router, deployment := newTestRouter(t, contractFixtures(t))
+
+			rec := serve(router, http.MethodPut, "/api/v1/admin/roles/SUPPORT",
+				bearer(t, "ADMIN", models.AllPermissions), `{"permissions":`+tt.permissions+`}`)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			if n := sessionRevocations(deployment); n != tt.revocations {
+				t.Errorf("%d session revocations, want %d", n, tt.revocations)
+			}
+		})
+	}
+}
+
+// TestUsersManageAloneCannotActOnAnAdmin checks that an actor holding only users:manage is
+// refused on the users fixture, whose ADMIN role holds every permission
+func TestUsersManageAloneCannotActOnAnAdmin(t *testing.T) {
+	tests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPatch, "/api/v1/admin/users/u2/status", `{"status":"SUSPENDED"}`},
+		{http.MethodPost, "/api/v1/admin/users/u2/logout", ``},
+		{http.MethodPost, "/api/v1/admin/users/u2/impersonate", ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			router, deployment := newTestRouter(t, contractFixtures(t))
+
+			rec := serve(router, tt.method, tt.path, bearer(t, "SUPPORT", []string{models.PermissionUsersManage}), tt.body)
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+			}
+			if n := len(deployment.Commands("update", "")); n != 0 {
+				t.Errorf("%d updates sent, want none", n)
+			}
+		})
+	}
+}
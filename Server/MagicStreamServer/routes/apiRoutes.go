@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	controller "github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/controllers"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/middleware"
+)
+
+// APIPrefix is where the current version of the API is mounted
+const APIPrefix = "/api/v1"
+
+// apiGroup registers routes under /api/v1 and, until clients have migrated, at their old
+// unversioned paths, which answer with deprecation headers pointing at the v1 route
+type apiGroup struct {
+	router     *gin.Engine
+	middleware []gin.HandlerFunc
+}
+
+// newAPIGroup returns a group whose routes all run the given middleware first
+func newAPIGroup(router *gin.Engine, middleware ...gin.HandlerFunc) apiGroup {
+	return apiGroup{router: router, middleware: middleware}
+}
+
+// handle mounts the route at path under /api/v1, and at legacyPath when it is not empty
+func (g apiGroup) handle(method, path, legacyPath string, handlers ...gin.HandlerFunc) {
+	g.router.Handle(method, APIPrefix+path, g.chain(nil, handlers)...)
+	if legacyPath != "" {
+		g.alias(method, legacyPath, path, handlers...)
+	}
+}
+
+// alias mounts handlers at a legacy path as the deprecated twin of the v1 route at path.
+// The deprecation headers are set first so they are also present on auth failures.
+func (g apiGroup) alias(method, legacyPath, path string, handlers ...gin.HandlerFunc) {
+	successor := APIPrefix + path
+	g.router.Handle(method, legacyPath, g.chain(middleware.DeprecatedRoute(successor), handlers)...)
+	controller.DocumentLegacyAlias(method, legacyPath, successor)
+}
+
+// chain runs first, the group middleware, the route's own middleware, the API key write scope
+// check and finally the route's handler
+func (g apiGroup) chain(first gin.HandlerFunc, handlers []gin.HandlerFunc) []gin.HandlerFunc {
+	chain := make([]gin.HandlerFunc, 0, len(g.middleware)+len(handlers)+2)
+	if first != nil {
+		chain = append(chain, first)
+	}
+	chain = append(chain, g.middleware...)
+	chain = append(chain, handlers[:len(handlers)-1]...)
+	return append(chain, middleware.APIKeyWriteScope(), handlers[len(handlers)-1])
+}
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/apierror"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// apiKeyFixtures adds a key for contractUserID to the contract fixtures and returns its bearer header
+func apiKeyFixtures(t *testing.T, write bool, permissions ...string) (map[string][]bson.D, string) {
+	t.Helper()
+	key, prefix, hash, err := utils.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	fixtures := contractFixtures(t)
+	fixtures["api_keys"] = []bson.D{{
+		{Key: "_id", Value: bson.NewObjectID()},
+		{Key: "user_id", Value: contractUserID},
+		{Key: "name", Value: "script"},
+		{Key: "prefix", Value: prefix},
+		{Key: "key_hash", Value: hash},
+		{Key: "permissions", Value: permissions},
+		{Key: "write", Value: write},
+		{Key: "created_at", Value: time.Now()},
+	}}
+	return fixtures, "Bearer " + key
+}
+
+func TestAPIKeysAreReadOnlyWithoutTheWriteScope(t *testing.T) {
+	tests := []struct {
+		name               string
+		write              bool
+		permissions        []string
+		method, path, body string
+		readOnly           bool
+	}{
+		{"unscoped key adds to my list", false, nil, http.MethodPost, "/api/v1/me/list/" + contractMovieID, ``, true},
+		{"unscoped key edits a profile", false, nil, http.MethodPatch, "/api/v1/me/profiles/" + contractProfileID, `{"name":"Bob"}`, true},
+		{"unscoped key on a legacy path", false, nil, http.MethodPost, "/mylist/" + contractMovieID, ``, true},
+		{"permission-scoped key edits my account", false, []string{models.PermissionMoviesWrite}, http.MethodPatch, "/api/v1/me/profiles/" + contractProfileID, `{"name":"Bob"}`, true},
+		{"permission-scoped key on its route", false, []string{models.PermissionMoviesWrite}, http.MethodPatch, "/api/v1/admin/movies/" + contractMovieID, `{"title":"The Shawshank Redemption"}`, false},
+		{"unscoped key reads", false, nil, http.MethodGet, "/api/v1/me", ``, false},
+		{"write-scoped key adds to my list", true, nil, http.MethodPost, "/api/v1/me/list/" + contractMovieID, ``, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixtures, authorization := apiKeyFixtures(t, tt.write, tt.permissions...)
+			router, _ := newTestRouter(t, fixtures)
+
+			rec := serve(router, tt.method, tt.path, authorization, tt.body)
+			var body apierror.Error
+			_ = json.Unmarshal(rec.Body.Bytes(), &body)
+			if readOnly := body.Code == apierror.CodeAPIKeyReadOnly; readOnly != tt.readOnly {
+				t.Errorf("status %d, read-only refusal %v, want %v: %s", rec.Code, readOnly, tt.readOnly, rec.Body.String())
+			}
+			if !tt.readOnly && rec.Code >= http.StatusMultipleChoices {
+				t.Errorf("status %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
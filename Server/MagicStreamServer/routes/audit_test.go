@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/x/bsonx/bsoncore"
+)
+
+func TestAdminChangesFailWhenTheyCannotBeAudited(t *testing.T) {
+	tests := []struct{ method, path, body string }{
+		{http.MethodPatch, "/api/v1/admin/movies/" + contractMovieID, `{"title":"Renamed"}`},
+		{http.MethodPatch, "/api/v1/admin/users/u2/role", `{"role":"ADMIN"}`},
+		{http.MethodPost, "/api/v1/admin/users/u2/impersonate", ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			router, deployment := newTestRouter(t, contractFixtures(t))
+			deployment.Reply = func(command bsoncore.Document) bson.D {
+				if collection, _ := command.Lookup("insert").StringValueOK(); collection == "audit_events" {
+					return bson.D{{Key: "ok", Value: 0}, {Key: "code", Value: 1}, {Key: "errmsg", Value: "insert failed"}}
+				}
+				return nil
+			}
+
+			rec := serve(router, tt.method, tt.path, bearer(t, "ADMIN", models.AllPermissions), tt.body)
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status %d, want %d: %s", rec.Code, http.StatusInternalServerError, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMovieUpdateAuditsTheUpdatedMovie(t *testing.T) {
+	router, deployment := newTestRouter(t, contractFixtures(t))
+
+	rec := serve(router, http.MethodPatch, "/api/v1/admin/movies/"+contractMovieID, bearer(t, "ADMIN", models.AllPermissions), `{"title":"Renamed"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The movie is read back with its write, so there is no separate read that could fail
+	if n := len(deployment.Commands("update", "movies")); n != 0 {
+		t.Errorf("%d separate movie updates, want none", n)
+	}
+	if n := len(deployment.Commands("findAndModify", "movies")); n != 1 {
+		t.Errorf("%d movie find-and-modify commands, want 1", n)
+	}
+	if n := len(deployment.Commands("insert", "audit_events")); n != 1 {
+		t.Errorf("%d audit events, want 1", n)
+	}
+}
@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/x/bsonx/bsoncore"
+)
+
+func TestLegacyMoviesReturnsABareArrayOnlyWithoutListParameters(t *testing.T) {
+	tests := []struct {
+		path     string
+		envelope bool
+	}{
+		{"/movies", false},
+		{"/movies?min_rating=3", true},
+		{"/movies?genre_id=1", true},
+		{"/api/v1/movies", true},
+		{"/api/v1/movies?min_rating=3", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			router, _ := newTestRouter(t, contractFixtures(t))
+
+			rec := serve(router, http.MethodGet, tt.path, "", ``)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			if envelope := strings.HasPrefix(rec.Body.String(), "{"); envelope != tt.envelope {
+				t.Errorf("envelope = %v, want %v: %s", envelope, tt.envelope, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestSearchMissCountsTitleMatchesOnTheTitleAlone checks that a search emptied by its other
+// filters looks the title up on its own, and isn't recorded when the title exists
+func TestSearchMissCountsTitleMatchesOnTheTitleAlone(t *testing.T) {
+	router, deployment := newTestRouter(t, contractFixtures(t))
+	// Only the listing count comes back empty; the title lookup then finds the fixture
+	counts := 0
+	deployment.Reply = func(command bsoncore.Document) bson.D {
+		if collection, _ := command.Lookup("aggregate").StringValueOK(); collection != "movies" {
+			return nil
+		}
+		if counts++; counts > 1 {
+			return nil
+		}
+		return bson.D{{Key: "ok", Value: 1}, {Key: "cursor", Value: bson.D{
+			{Key: "id", Value: int64(0)},
+			{Key: "ns", Value: "contract.movies"},
+			{Key: "firstBatch", Value: bson.A{}},
+		}}}
+	}
+
+	rec := serve(router, http.MethodGet, "/api/v1/movies?genre_id=1&q=Shawshank&min_rating=4.5", "", ``)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	aggregates := deployment.Commands("aggregate", "movies")
+	if len(aggregates) != 2 {
+		t.Fatalf("%d counts on movies, want the listing and the title lookup", len(aggregates))
+	}
+	match := aggregates[1].Lookup("pipeline", "0", "$match").Document()
+	elements, _ := match.Elements()
+	if len(elements) != 1 || elements[0].Key() != "title" {
+		t.Errorf("title lookup matched %s, want the title alone", match)
+	}
+	if n := len(deployment.Commands("update", "zero_result_searches")); n != 0 {
+		t.Errorf("%d zero-result searches recorded for a title the catalog carries", n)
+	}
+}
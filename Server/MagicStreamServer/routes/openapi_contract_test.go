@@ -0,0 +1,378 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	controller "github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/controllers"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database/mongotest"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/openapi"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	contractUserID   = "u1"
+	contractEmail    = "admin@example.com"
+	contractPassword = "password123"
+	contractPin      = "1234"
+)
+
+var (
+	contractMovieID   = "tt0111161"
+	contractRatingID  = bson.NewObjectID()
+	contractReportID  = bson.NewObjectID()
+	contractFileID    = bson.NewObjectID()
+	contractProfileID = "p1"
+)
+
+// contractBodies are minimal valid request bodies, keyed by handler name
+var contractBodies = map[string]string{
+	"RegisterUser":             `{"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","password":"password123","favourite_genres":[{"genre_id":1,"genre_name":"Drama"}]}`,
+	"LoginUser":                `{"email":"admin@example.com","password":"password123"}`,
+	"LogoutHandler":            `{"user_id":"u1"}`,
+	"ForgotPassword":           `{"email":"admin@example.com"}`,
+	"ResetPassword":            `{"token":"reset-token","new_password":"password456"}`,
+	"UpdatePreferences":        `{"favourite_genres":[{"genre_id":1,"genre_name":"Drama"}]}`,
+	"ChangePassword":           `{"current_password":"password123","new_password":"password456"}`,
+	"RequestEmailChange":       `{"new_email":"new@example.com","current_password":"password123"}`,
+	"ConfirmEmailChange":       `{"token":"change-token"}`,
+	"DeleteMe":                 `{"password":"password123"}`,
+	"CreateAPIKey":             `{"name":"ci","permissions":[]}`,
+	"CreateProfile":            `{"name":"Guest"}`,
+	"UpdateProfile":            `{"name":"Renamed"}`,
+	"SelectProfile":            `{}`,
+	"UpdateParentalControls":   `{"pin":"1234","profile_id":"p1","max_content_rating":"PG"}`,
+	"SetParentalPin":           `{"password":"password123","new_pin":"4321"}`,
+	"UpdateProgress":           `{"position_seconds":60,"duration_seconds":600}`,
+	"Subscribe":                `{"plan_id":"basic","payment_method":"CARD","card_number":"4242424242424242"}`,
+	"UpsertRating":             `{"rating":4,"review_text":"Great"}`,
+	"ReportReview":             `{"reason":"spam"}`,
+	"ConfirmEmailVerification": `{"token":"verify-token"}`,
+	"AdminUpdateUserRole":      `{"role":"USER"}`,
+	"AdminUpdateUserStatus":    `{"status":"SUSPENDED","reason":"abuse"}`,
+	"AdminUpsertRole":          `{"description":"Support staff","permissions":["users:manage"]}`,
+	"AdminCreateReport":        `{"name":"Weekly","frequency":"weekly","sections":["stats"],"formats":["csv"],"recipients":[]}`,
+	"AdminUpdateReport":        `{"name":"Renamed"}`,
+	"AdminHideReview":          `{"reason":"spam"}`,
+	"AddMovie":                 `{"imdb_id":"tt0000001","title":"New Movie","poster_path":"https://example.com/p.jpg","youtube_id":"abc","genre":[{"genre_id":1,"genre_name":"Drama"}],"ranking":{"ranking_value":1,"ranking_name":"Excellent"}}`,
+	"AdminReviewUpdate":        `{"admin_review":""}`,
+	"UpdateMovie":              `{"title":"Renamed Movie"}`,
+}
+
+// contractParams fills gin path parameters with ids the fixtures use
+var contractParams = map[string]string{
+	":imdb_id":    contractMovieID,
+	":id":         contractRatingID.Hex(),
+	":user_id":    "u2",
+	":name":       "SUPPORT",
+	":profile_id": contractProfileID,
+	":file_id":    contractFileID.Hex(),
+}
+
+// contractExpectedStatus lists the handlers that can't reach their success path against the mongotest
+// deployment, which ignores filters, with the status they answer instead. Their error bodies are
+// still checked against the document.
+var contractExpectedStatus = map[string]int{
+	// Uniqueness checks find the fixture user's own email
+	"RegisterUser":       http.StatusConflict,
+	"RequestEmailChange": http.StatusConflict,
+	"ConfirmEmailChange": http.StatusConflict,
+	// The fixture user holds every role
+	"AdminDeleteRole": http.StatusConflict,
+	// Ranking a review calls the OpenAI API
+	"AdminReviewUpdate": http.StatusBadGateway,
+	// Index sync only runs at startup
+	"Readyz": http.StatusServiceUnavailable,
+}
+
+func contractFixtures(t *testing.T) map[string][]bson.D {
+	t.Helper()
+	password, err := bcrypt.GenerateFromPassword([]byte(contractPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin, err := bcrypt.GenerateFromPassword([]byte(contractPin), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	later := now.Add(24 * time.Hour)
+	genres := bson.A{bson.D{{Key: "genre_id", Value: 1}, {Key: "genre_name", Value: "Drama"}}}
+	return map[string][]bson.D{
+		"users": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "user_id", Value: contractUserID},
+			{Key: "first_name", Value: "Ada"},
+			{Key: "last_name", Value: "Admin"},
+			{Key: "email", Value: contractEmail},
+			{Key: "password", Value: string(password)},
+			{Key: "role", Value: "ADMIN"},
+			{Key: "created_at", Value: now},
+			{Key: "update_at", Value: now},
+			{Key: "favourite_genres", Value: genres},
+			{Key: "email_verified", Value: true},
+			{Key: "parental_pin", Value: string(pin)},
+			{Key: "status", Value: "ACTIVE"},
+		}},
+		"roles": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "name", Value: "ADMIN"},
+			{Key: "permissions", Value: models.AllPermissions},
+			{Key: "built_in", Value: true},
+			{Key: "created_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}},
+		"movies": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "imdb_id", Value: contractMovieID},
+			{Key: "title", Value: "The Shawshank Redemption"},
+			{Key: "poster_path", Value: "https://example.com/poster.jpg"},
+			{Key: "youtube_id", Value: "6hB3S9bIaco"},
+			{Key: "genre", Value: genres},
+			{Key: "admin_review", Value: "A classic"},
+			{Key: "ranking", Value: bson.D{{Key: "ranking_value", Value: 1}, {Key: "ranking_name", Value: "Excellent"}}},
+			{Key: "content_rating", Value: "R"},
+			{Key: "avg_rating", Value: 4.5},
+			{Key: "rating_count", Value: int64(2)},
+			{Key: "rating_sum", Value: int64(9)},
+		}},
+		"genres": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "genre_id", Value: 1},
+			{Key: "genre_name", Value: "Drama"},
+		}},
+		"profiles": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "profile_id", Value: contractProfileID},
+			{Key: "user_id", Value: contractUserID},
+			{Key: "name", Value: "Ada"},
+			{Key: "is_kids", Value: false},
+			{Key: "favourite_genres", Value: genres},
+			{Key: "created_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}},
+		"plans": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "plan_id", Value: "basic"},
+			{Key: "name", Value: "Basic"},
+			{Key: "price_monthly", Value: 9.99},
+			{Key: "max_streams", Value: 4},
+			{Key: "max_quality", Value: "1080p"},
+			{Key: "features", Value: bson.A{"HD"}},
+			{Key: "created_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}},
+		"subscriptions": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "user_id", Value: contractUserID},
+			{Key: "plan_id", Value: "basic"},
+			{Key: "status", Value: "ACTIVE"},
+			{Key: "started_at", Value: now},
+			{Key: "expires_at", Value: later},
+			{Key: "next_billing_at", Value: later},
+			{Key: "payment_method", Value: "CARD"},
+			{Key: "auto_renew", Value: true},
+			{Key: "created_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}},
+		"payments": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "user_id", Value: contractUserID},
+			{Key: "plan_id", Value: "basic"},
+			{Key: "amount", Value: 9.99},
+			{Key: "currency", Value: "USD"},
+			{Key: "status", Value: "SUCCESS"},
+			{Key: "payment_method", Value: "CARD"},
+			{Key: "transaction_id", Value: "txn_1"},
+			{Key: "created_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}},
+		"ratings": {{
+			{Key: "_id", Value: contractRatingID},
+			{Key: "user_id", Value: "u3"},
+			{Key: "imdb_id", Value: contractMovieID},
+			{Key: "rating", Value: 5},
+			{Key: "review_text", Value: "Hope is a good thing"},
+			{Key: "moderation_status", Value: "APPROVED"},
+			{Key: "created_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}},
+		"watch_progress": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "user_id", Value: contractUserID},
+			{Key: "imdb_id", Value: contractMovieID},
+			{Key: "position_seconds", Value: 60.0},
+			{Key: "duration_seconds", Value: 600.0},
+			{Key: "finished", Value: false},
+			{Key: "last_watched_at", Value: now},
+			{Key: "created_at", Value: now},
+		}},
+		"password_resets": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "email", Value: contractEmail},
+			{Key: "user_id", Value: contractUserID},
+			{Key: "token", Value: "reset-token"},
+			{Key: "expires_at", Value: later},
+			{Key: "created_at", Value: now},
+		}},
+		"email_verifications": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "user_id", Value: contractUserID},
+			{Key: "token", Value: "verify-token"},
+			{Key: "expires_at", Value: later},
+			{Key: "created_at", Value: now},
+		}},
+		"email_changes": {{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "user_id", Value: contractUserID},
+			{Key: "old_email", Value: contractEmail},
+			{Key: "new_email", Value: "new@example.com"},
+			{Key: "token", Value: "change-token"},
+			{Key: "expires_at", Value: later},
+			{Key: "created_at", Value: now},
+		}},
+		"scheduled_reports": {{
+			{Key: "_id", Value: contractReportID},
+			{Key: "name", Value: "Weekly"},
+			{Key: "frequency", Value: "weekly"},
+			{Key: "sections", Value: bson.A{"stats"}},
+			{Key: "formats", Value: bson.A{"csv"}},
+			{Key: "recipients", Value: bson.A{}},
+			{Key: "enabled", Value: true},
+			{Key: "created_by", Value: contractUserID},
+			{Key: "next_run_at", Value: later},
+			{Key: "created_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}},
+		"generated_reports": {{
+			{Key: "_id", Value: contractFileID},
+			{Key: "report_id", Value: contractReportID},
+			{Key: "report_name", Value: "Weekly"},
+			{Key: "format", Value: "csv"},
+			{Key: "file_name", Value: "weekly.csv"},
+			{Key: "size_bytes", Value: int64(0)},
+			{Key: "period_start", Value: now.Add(-7 * 24 * time.Hour)},
+			{Key: "period_end", Value: now},
+			{Key: "trigger", Value: "manual"},
+			{Key: "created_at", Value: now},
+		}},
+	}
+}
+
+// handlerName turns "…/controllers.UpsertRating.func1" into "UpsertRating"
+func handlerName(route gin.RouteInfo) string {
+	name := strings.TrimSuffix(route.Handler, ".func1")
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func fillParams(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if value, ok := contractParams[segment]; ok {
+			segments[i] = value
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// newTestRouter serves every route against a mongotest deployment holding fixtures
+func newTestRouter(t *testing.T, fixtures map[string][]bson.D) (*gin.Engine, *mongotest.Deployment) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("DATABASE_NAME", "contract")
+	t.Setenv("REPORTS_DIR", t.TempDir())
+	utils.SECRET_KEY = "contract-secret"
+	utils.SECRET_REFRESH_KEY = "contract-refresh-secret"
+
+	client, deployment, err := mongotest.NewClient(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.ContextWithFallback = true
+	SetupUnProtectedRoutes(router, client)
+	SetupProtectedRoutes(router, client)
+	return router, deployment
+}
+
+func newContractRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	router, _ := newTestRouter(t, contractFixtures(t))
+	return router
+}
+
+// TestEveryJSONOperationDeclaresItsResponse fails when an operation's success response falls back
+// to a bare object because its apiOperations entry has no Response
+func TestEveryJSONOperationDeclaresItsResponse(t *testing.T) {
+	doc := controller.OpenAPIDocument(newContractRouter(t))
+
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			for status, response := range op.Responses {
+				media, ok := response.Content["application/json"]
+				if !ok || status == "default" {
+					continue
+				}
+				if s := media.Schema; s.Type == "object" && s.Ref == "" && s.Properties == nil && s.AdditionalProperties == nil {
+					t.Errorf("%s %s: %s response has no declared type", strings.ToUpper(method), path, status)
+				}
+			}
+		}
+	}
+}
+
+// TestResponsesMatchTheOpenAPIDocument sends a request to every route as an admin and checks each
+// JSON response against the operation the document declares for it
+func TestResponsesMatchTheOpenAPIDocument(t *testing.T) {
+	router := newContractRouter(t)
+	doc := controller.OpenAPIDocument(router)
+
+	access, refresh, err := utils.GenerateAllTokens(contractEmail, "Ada", "Admin", "ADMIN", contractUserID, "", models.AllPermissions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The download route serves the generated file from disk
+	if err := os.WriteFile(filepath.Join(os.Getenv("REPORTS_DIR"), "weekly.csv"), []byte("metric,value\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, route := range router.Routes() {
+		if route.Path == "/metrics" {
+			continue
+		}
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			req := httptest.NewRequest(route.Method, fillParams(route.Path), strings.NewReader(contractBodies[handlerName(route)]))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+access)
+			req.AddCookie(&http.Cookie{Name: "refresh_token", Value: refresh})
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if want, ok := contractExpectedStatus[handlerName(route)]; ok {
+				if rec.Code != want {
+					t.Errorf("status %d, want %d: %s", rec.Code, want, rec.Body.String())
+				}
+			} else if rec.Code >= http.StatusMultipleChoices {
+				t.Errorf("status %d: %s", rec.Code, rec.Body.String())
+			}
+
+			if !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+				return
+			}
+			for _, problem := range doc.ValidateResponse(route.Method, openapi.PathFromGin(route.Path), rec.Code, rec.Body.Bytes()) {
+				t.Errorf("response does not match the document: %s", problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/x/bsonx/bsoncore"
+)
+
+func TestDeleteProfileRemovesItsData(t *testing.T) {
+	router, deployment := newTestRouter(t, contractFixtures(t))
+
+	rec := serve(router, http.MethodDelete, "/api/v1/me/profiles/"+contractProfileID, bearer(t, "ADMIN", models.AllPermissions), ``)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, collection := range []string{"review_reports", "review_votes", "watchlists", "ratings", "watch_progress", "profiles"} {
+		if n := len(deployment.Commands("delete", collection)); n != 1 {
+			t.Errorf("%d deletes on %s, want 1", n, collection)
+		}
+	}
+	// Reports and votes are deleted by the ids of the profile's ratings
+	deletes := deployment.Commands("delete", "review_reports")
+	if len(deletes) == 1 {
+		ids, _ := deletes[0].Lookup("deletes", "0", "q", "rating_id", "$in").ArrayOK()
+		if values, _ := ids.Values(); len(values) != 1 || values[0].ObjectID() != contractRatingID {
+			t.Errorf("review reports deleted for %v, want [%s]", values, contractRatingID.Hex())
+		}
+	}
+}
+
+func TestDeleteProfileKeepsTheProfileWhenItsDataCannotBeDeleted(t *testing.T) {
+	router, deployment := newTestRouter(t, contractFixtures(t))
+	deployment.Reply = func(command bsoncore.Document) bson.D {
+		if collection, _ := command.Lookup("delete").StringValueOK(); collection == "watch_progress" {
+			return bson.D{{Key: "ok", Value: 0}, {Key: "code", Value: 1}, {Key: "errmsg", Value: "delete failed"}}
+		}
+		return nil
+	}
+
+	rec := serve(router, http.MethodDelete, "/api/v1/me/profiles/"+contractProfileID, bearer(t, "ADMIN", models.AllPermissions), ``)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusInternalServerError, rec.Body.String())
+	}
+	if len(deployment.Commands("delete", "profiles")) != 0 {
+		t.Errorf("profile deleted despite the failure")
+	}
+}
@@ -1,60 +1,121 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	controller "github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/controllers"
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/middleware"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 func SetupProtectedRoutes(router *gin.Engine, client *mongo.Client) {
-	router.Use(middleware.AuthMiddleWare())
+	api := newAPIGroup(router, middleware.AuthMiddleWare(client), middleware.ProfileMiddleware(client))
 
-	router.GET("/movie/:imdb_id", controller.GetMovie(client))
-	router.GET("/recommendedmovies", controller.GetRecommendedMovies(client))
+	api.handle(http.MethodGet, "/movies/:imdb_id", "/movie/:imdb_id", controller.GetMovie(client))
+	api.handle(http.MethodGet, "/me/recommendations", "/recommendedmovies", controller.GetRecommendedMovies(client))
 
 	// My List (watchlist) routes
-	router.POST("/mylist/:imdb_id", controller.AddToMyList(client))
-	router.DELETE("/mylist/:imdb_id", controller.RemoveFromMyList(client))
-	router.GET("/mylist", controller.GetMyList(client))
+	api.handle(http.MethodPost, "/me/list/:imdb_id", "/mylist/:imdb_id", controller.AddToMyList(client))
+	api.handle(http.MethodDelete, "/me/list/:imdb_id", "/mylist/:imdb_id", controller.RemoveFromMyList(client))
+	api.handle(http.MethodGet, "/me/list", "/mylist", controller.GetMyList(client))
 
 	// Account/profile routes
-	router.GET("/me", controller.GetMe(client))
-	router.PUT("/me/preferences", controller.UpdatePreferences(client))
+	api.handle(http.MethodGet, "/me", "/me", controller.GetMe(client))
+	api.handle(http.MethodPut, "/me/preferences", "/me/preferences", controller.UpdatePreferences(client))
+	api.handle(http.MethodPost, "/me/password", "/me/password", controller.ChangePassword(client))
+	api.handle(http.MethodPost, "/me/email", "/me/email", controller.RequestEmailChange(client))
+	api.handle(http.MethodPost, "/me/email/confirm", "/me/email/confirm", controller.ConfirmEmailChange(client))
+	api.handle(http.MethodGet, "/me/export", "/me/export", controller.ExportMyData(client))
+	api.handle(http.MethodDelete, "/me", "/me", controller.DeleteMe(client))
+	api.handle(http.MethodPost, "/me/cancel-deletion", "/me/cancel-deletion", controller.CancelAccountDeletion(client))
+
+	// Personal API keys for scripts and integrations
+	api.handle(http.MethodGet, "/me/api-keys", "", controller.ListAPIKeys(client))
+	api.handle(http.MethodPost, "/me/api-keys", "", controller.CreateAPIKey(client))
+	api.handle(http.MethodDelete, "/me/api-keys/:id", "", controller.RevokeAPIKey(client))
+
+	// Viewer profile routes
+	api.handle(http.MethodGet, "/me/profiles", "/me/profiles", controller.ListProfiles(client))
+	api.handle(http.MethodPost, "/me/profiles", "/me/profiles", controller.CreateProfile(client))
+	api.handle(http.MethodPatch, "/me/profiles/:profile_id", "/me/profiles/:profile_id", controller.UpdateProfile(client))
+	api.handle(http.MethodDelete, "/me/profiles/:profile_id", "/me/profiles/:profile_id", controller.DeleteProfile(client))
+	api.handle(http.MethodPost, "/me/profiles/:profile_id/select", "", controller.SelectProfile(client))
+
+	// Parental control routes
+	api.handle(http.MethodGet, "/me/parental-controls", "/me/parental-controls", controller.GetParentalControls(client))
+	api.handle(http.MethodPut, "/me/parental-controls", "/me/parental-controls", controller.UpdateParentalControls(client))
+	api.handle(http.MethodPut, "/me/parental-controls/pin", "/me/parental-controls/pin", controller.SetParentalPin(client))
+
+	// Watch progress routes
+	api.handle(http.MethodPut, "/me/progress/:imdb_id", "/progress/:imdb_id", controller.UpdateProgress(client))
+	api.handle(http.MethodGet, "/me/continue-watching", "/me/continue-watching", controller.GetContinueWatching(client))
+	api.handle(http.MethodGet, "/me/history", "/me/history", controller.GetMyWatchHistory(client))
 
 	// Subscription routes
-	router.GET("/plans", controller.GetPlans(client))
-	router.POST("/subscribe", controller.Subscribe(client))
-	router.GET("/subscription", controller.GetSubscription(client))
-	router.POST("/subscription/cancel", controller.CancelSubscription(client))
-	router.GET("/payments", controller.GetPaymentHistory(client))
+	api.handle(http.MethodGet, "/plans", "/plans", controller.GetPlans(client))
+	api.handle(http.MethodPost, "/me/subscription", "/subscribe", controller.Subscribe(client))
+	api.handle(http.MethodGet, "/me/subscription", "/subscription", controller.GetSubscription(client))
+	api.handle(http.MethodPost, "/me/subscription/cancel", "/subscription/cancel", controller.CancelSubscription(client))
+	api.handle(http.MethodGet, "/me/payments", "/payments", controller.GetPaymentHistory(client))
 
 	// Rating routes
-	router.PUT("/ratings/:imdb_id", controller.UpsertRating(client))
-	router.GET("/me/ratings", controller.GetUserRatings(client))
-	router.DELETE("/ratings/:imdb_id", controller.DeleteRating(client))
+	api.handle(http.MethodPut, "/movies/:imdb_id/rating", "/ratings/:imdb_id", controller.UpsertRating(client))
+	api.handle(http.MethodGet, "/me/ratings", "/me/ratings", controller.GetUserRatings(client))
+	api.handle(http.MethodDelete, "/movies/:imdb_id/rating", "/ratings/:imdb_id", controller.DeleteRating(client))
+	api.handle(http.MethodPost, "/reviews/:id/report", "/ratings/:id/report", controller.ReportReview(client))
+	api.handle(http.MethodPost, "/reviews/:id/helpful", "/reviews/:id/helpful", controller.VoteReviewHelpful(client))
+	api.handle(http.MethodDelete, "/reviews/:id/helpful", "/reviews/:id/helpful", controller.RemoveReviewHelpfulVote(client))
 
 	// Email verification routes (protected - user must be logged in to request)
-	router.POST("/verify-email/request", controller.RequestEmailVerification(client))
-	router.POST("/verify-email/confirm", controller.ConfirmEmailVerification(client))
+	api.handle(http.MethodPost, "/me/email/verification", "/verify-email/request", controller.RequestEmailVerification(client))
+	api.handle(http.MethodPost, "/me/email/verification/confirm", "/verify-email/confirm", controller.ConfirmEmailVerification(client))
 
-	// Admin-only routes
-	adminRoutes := router.Group("")
-	adminRoutes.Use(middleware.RequireAdmin())
+	// Admin routes, each guarded by the permission it needs
 	{
-		adminRoutes.GET("/admin/stats", controller.GetAdminStats(client))
-		adminRoutes.GET("/admin/users", controller.AdminListUsers(client))
-		adminRoutes.GET("/admin/users/:user_id", controller.AdminGetUser(client))
-		adminRoutes.PATCH("/admin/users/:user_id/role", controller.AdminUpdateUserRole(client))
-		adminRoutes.GET("/admin/subscriptions", controller.AdminListSubscriptions(client))
-		adminRoutes.PATCH("/admin/subscriptions/:id/cancel", controller.AdminCancelSubscription(client))
-		adminRoutes.PATCH("/admin/subscriptions/:id/activate", controller.AdminActivateSubscription(client))
-		adminRoutes.GET("/admin/payments", controller.AdminListPayments(client))
-		adminRoutes.GET("/admin/analytics/revenue", controller.AdminRevenueAnalytics(client))
-		adminRoutes.GET("/admin/analytics/subscriptions", controller.AdminSubscriptionTrendsAnalytics(client))
-		adminRoutes.GET("/admin/analytics/plans/popular", controller.AdminPopularPlansAnalytics(client))
-		adminRoutes.POST("/addmovie", controller.AddMovie(client))
-		adminRoutes.PATCH("/updatereview/:imdb_id", controller.AdminReviewUpdate(client))
-		adminRoutes.PATCH("/movie/:imdb_id", controller.UpdateMovie(client))
+		analyticsRead := middleware.RequirePermission(models.PermissionAnalyticsRead)
+		usersManage := middleware.RequirePermission(models.PermissionUsersManage)
+		billingManage := middleware.RequirePermission(models.PermissionBillingManage)
+		moviesWrite := middleware.RequirePermission(models.PermissionMoviesWrite)
+		reviewsRank := middleware.RequirePermission(models.PermissionReviewsRank)
+		reviewsModerate := middleware.RequirePermission(models.PermissionReviewsModerate)
+		auditRead := middleware.RequirePermission(models.PermissionAuditRead)
+		reportsManage := middleware.RequirePermission(models.PermissionReportsManage)
+
+		api.handle(http.MethodGet, "/admin/stats", "/admin/stats", analyticsRead, controller.GetAdminStats(client))
+		api.handle(http.MethodGet, "/admin/users", "/admin/users", usersManage, controller.AdminListUsers(client))
+		api.handle(http.MethodGet, "/admin/users/:user_id", "/admin/users/:user_id", usersManage, controller.AdminGetUser(client))
+		api.handle(http.MethodPatch, "/admin/users/:user_id/role", "/admin/users/:user_id/role", usersManage, controller.AdminUpdateUserRole(client))
+		api.handle(http.MethodPatch, "/admin/users/:user_id/status", "/admin/users/:user_id/status", usersManage, controller.AdminUpdateUserStatus(client))
+		api.handle(http.MethodPost, "/admin/users/:user_id/logout", "/admin/users/:user_id/logout", usersManage, controller.AdminForceLogout(client))
+		api.handle(http.MethodPost, "/admin/users/:user_id/impersonate", "/admin/users/:user_id/impersonate", usersManage, controller.AdminImpersonateUser(client))
+		api.handle(http.MethodGet, "/admin/roles", "/admin/roles", usersManage, controller.AdminListRoles(client))
+		api.handle(http.MethodPut, "/admin/roles/:name", "/admin/roles/:name", usersManage, controller.AdminUpsertRole(client))
+		api.handle(http.MethodDelete, "/admin/roles/:name", "/admin/roles/:name", usersManage, controller.AdminDeleteRole(client))
+		api.handle(http.MethodGet, "/admin/subscriptions", "/admin/subscriptions", billingManage, controller.AdminListSubscriptions(client))
+		api.handle(http.MethodPatch, "/admin/subscriptions/:id/cancel", "/admin/subscriptions/:id/cancel", billingManage, controller.AdminCancelSubscription(client))
+		api.handle(http.MethodPatch, "/admin/subscriptions/:id/activate", "/admin/subscriptions/:id/activate", billingManage, controller.AdminActivateSubscription(client))
+		api.handle(http.MethodGet, "/admin/payments", "/admin/payments", billingManage, controller.AdminListPayments(client))
+		api.handle(http.MethodGet, "/admin/analytics/revenue", "/admin/analytics/revenue", analyticsRead, controller.AdminRevenueAnalytics(client))
+		api.handle(http.MethodGet, "/admin/analytics/subscriptions", "/admin/analytics/subscriptions", analyticsRead, controller.AdminSubscriptionTrendsAnalytics(client))
+		api.handle(http.MethodGet, "/admin/analytics/plans/popular", "/admin/analytics/plans/popular", analyticsRead, controller.AdminPopularPlansAnalytics(client))
+		api.handle(http.MethodGet, "/admin/analytics/retention", "/admin/analytics/retention", analyticsRead, controller.AdminRetentionAnalytics(client))
+		api.handle(http.MethodGet, "/admin/analytics/content", "/admin/analytics/content", analyticsRead, controller.AdminContentAnalytics(client))
+		api.handle(http.MethodGet, "/admin/reports", "/admin/reports", analyticsRead, controller.AdminListReports(client))
+		api.handle(http.MethodPost, "/admin/reports", "/admin/reports", reportsManage, controller.AdminCreateReport(client))
+		api.handle(http.MethodPatch, "/admin/reports/:id", "/admin/reports/:id", reportsManage, controller.AdminUpdateReport(client))
+		api.handle(http.MethodDelete, "/admin/reports/:id", "/admin/reports/:id", reportsManage, controller.AdminDeleteReport(client))
+		api.handle(http.MethodPost, "/admin/reports/:id/run", "/admin/reports/:id/run", reportsManage, controller.AdminRunReport(client))
+		api.handle(http.MethodGet, "/admin/reports/:id/files", "/admin/reports/:id/files", analyticsRead, controller.AdminListReportFiles(client))
+		api.handle(http.MethodGet, "/admin/reports/:id/files/:file_id", "/admin/reports/:id/files/:file_id", analyticsRead, controller.AdminDownloadReportFile(client))
+		api.handle(http.MethodGet, "/admin/audit", "/admin/audit", auditRead, controller.AdminListAuditEvents(client))
+		api.handle(http.MethodGet, "/admin/audit/verify", "/admin/audit/verify", auditRead, controller.AdminVerifyAuditLog(client))
+		api.handle(http.MethodPost, "/admin/movies", "/addmovie", moviesWrite, controller.AddMovie(client))
+		api.handle(http.MethodPatch, "/admin/movies/:imdb_id/review", "/updatereview/:imdb_id", reviewsRank, controller.AdminReviewUpdate(client))
+		api.handle(http.MethodGet, "/admin/reviews/queue", "/admin/reviews/queue", reviewsModerate, controller.AdminReviewQueue(client))
+		api.handle(http.MethodPatch, "/admin/reviews/:id/approve", "/admin/reviews/:id/approve", reviewsModerate, controller.AdminApproveReview(client))
+		api.handle(http.MethodPatch, "/admin/reviews/:id/hide", "/admin/reviews/:id/hide", reviewsModerate, controller.AdminHideReview(client))
+		api.handle(http.MethodPatch, "/admin/movies/:imdb_id", "/movie/:imdb_id", moviesWrite, controller.UpdateMovie(client))
 	}
 }
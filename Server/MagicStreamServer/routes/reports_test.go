@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+)
+
+func TestAnalyticsReadersCannotChangeReports(t *testing.T) {
+	router, deployment := newTestRouter(t, contractFixtures(t))
+	analyst := bearer(t, "ANALYST", []string{models.PermissionAnalyticsRead})
+	id := contractReportID.Hex()
+
+	for _, route := range []struct{ method, path, body string }{
+		{http.MethodPost, "/api/v1/admin/reports", contractBodies["AdminCreateReport"]},
+		{http.MethodPatch, "/api/v1/admin/reports/" + id, contractBodies["AdminUpdateReport"]},
+		{http.MethodDelete, "/api/v1/admin/reports/" + id, ``},
+		{http.MethodPost, "/api/v1/admin/reports/" + id + "/run", ``},
+	} {
+		if rec := serve(router, route.method, route.path, analyst, route.body); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s: status %d, want %d", route.method, route.path, rec.Code, http.StatusForbidden)
+		}
+	}
+	if rec := serve(router, http.MethodGet, "/api/v1/admin/reports", analyst, ``); rec.Code != http.StatusOK {
+		t.Errorf("listing reports: status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if n := len(deployment.Commands("insert", "scheduled_reports")) + len(deployment.Commands("update", "scheduled_reports")); n != 0 {
+		t.Errorf("%d report writes, want none", n)
+	}
+}
+
+func TestReportRecipientsMustBeStaff(t *testing.T) {
+	tests := []struct {
+		recipient string
+		status    int
+	}{
+		// The users fixture is a verified ADMIN
+		{contractEmail, http.StatusCreated},
+		{"someone@example.com", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.recipient, func(t *testing.T) {
+			router, _ := newTestRouter(t, contractFixtures(t))
+
+			rec := serve(router, http.MethodPost, "/api/v1/admin/reports", bearer(t, "ADMIN", models.AllPermissions),
+				`{"name":"Weekly","frequency":"weekly","recipients":["`+tt.recipient+`"]}`)
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/x/bsonx/bsoncore"
+)
+
+// statusUpdates returns the moderation_status values set by updates to ratings
+func statusUpdates(commands []bsoncore.Document) []string {
+	var statuses []string
+	for _, command := range commands {
+		updates, _ := command.Lookup("updates").ArrayOK()
+		values, _ := updates.Values()
+		for _, update := range values {
+			if status, ok := update.Document().Lookup("u", "$set", "moderation_status").StringValueOK(); ok {
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	return statuses
+}
+
+func TestReportedReviewKeepsCountingTowardTheMovieRating(t *testing.T) {
+	tests := []struct {
+		name      string
+		reporters bson.A
+		requeued  bool
+	}{
+		{"below the threshold", bson.A{contractUserID}, false},
+		{"at the threshold", bson.A{contractUserID, "u4", "u5"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, deployment := newTestRouter(t, contractFixtures(t))
+			deployment.Reply = func(command bsoncore.Document) bson.D {
+				if collection, _ := command.Lookup("distinct").StringValueOK(); collection == "review_reports" {
+					return bson.D{{Key: "ok", Value: 1}, {Key: "values", Value: tt.reporters}}
+				}
+				return nil
+			}
+
+			rec := serve(router, http.MethodPost, "/api/v1/reviews/"+contractRatingID.Hex()+"/report",
+				bearer(t, "USER", []string{}), `{"reason":"spam"}`)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+
+			statuses := statusUpdates(deployment.Commands("update", "ratings"))
+			if requeued := len(statuses) == 1 && statuses[0] == models.ReviewStatusReported; requeued != tt.requeued {
+				t.Errorf("moderation status updates %v, requeued %v, want %v", statuses, requeued, tt.requeued)
+			}
+			// The movie's rating aggregates are left alone
+			if n := len(deployment.Commands("update", "movies")) + len(deployment.Commands("findAndModify", "movies")); n != 0 {
+				t.Errorf("%d movie updates, want none", n)
+			}
+		})
+	}
+}
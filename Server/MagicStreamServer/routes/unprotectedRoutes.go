@@ -1,24 +1,48 @@
 package routes
 
 import (
+	"net/http"
+
 	controller "github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/controllers"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/metrics"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/middleware"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 func SetupUnProtectedRoutes(router *gin.Engine, client *mongo.Client) {
+	api := newAPIGroup(router)
+
+	// Public catalog, filtered by parental controls when the viewer is logged in.
+	// The legacy /movies keeps returning a bare array when called without parameters.
+	api.handle(http.MethodGet, "/movies", "", middleware.OptionalAuthMiddleWare(client), middleware.ProfileMiddleware(client), controller.GetMovies(client))
+	api.alias(http.MethodGet, "/movies", "/movies", middleware.OptionalAuthMiddleWare(client), middleware.ProfileMiddleware(client), controller.GetMoviesLegacy(client))
+	api.handle(http.MethodGet, "/genres", "/genres", controller.GetGenres(client))
+
+	// Authentication
+	api.handle(http.MethodPost, "/auth/register", "/register", controller.RegisterUser(client))
+	api.handle(http.MethodPost, "/auth/login", "/login", controller.LoginUser(client))
+	api.handle(http.MethodPost, "/auth/logout", "/logout", controller.LogoutHandler(client))
+	api.handle(http.MethodPost, "/auth/refresh", "/refresh", controller.RefreshTokenHandler(client))
+	api.handle(http.MethodGet, "/auth/csrf", "", controller.GetCSRFToken())
+
+	// Public rating endpoints
+	api.handle(http.MethodGet, "/movies/:imdb_id/ratings", "/movies/:imdb_id/ratings", controller.GetMovieRatings(client))
+	api.handle(http.MethodGet, "/movies/:imdb_id/reviews", "/movies/:imdb_id/reviews", middleware.OptionalAuthMiddleWare(client), controller.GetMovieReviews(client))
 
-	router.GET("/movies", controller.GetMovies(client))
-	router.POST("/register", controller.RegisterUser(client))
-	router.POST("/login", controller.LoginUser(client))
-	router.POST("/logout", controller.LogoutHandler(client))
-	router.GET("/genres", controller.GetGenres(client))
-	router.POST("/refresh", controller.RefreshTokenHandler(client))
-	
-	// Public rating endpoint
-	router.GET("/movies/:imdb_id/ratings", controller.GetMovieRatings(client))
-	
 	// Password reset routes (unprotected - user not logged in)
-	router.POST("/forgot-password", controller.ForgotPassword(client))
-	router.POST("/reset-password", controller.ResetPassword(client))
+	api.handle(http.MethodPost, "/auth/forgot-password", "/forgot-password", controller.ForgotPassword(client))
+	api.handle(http.MethodPost, "/auth/reset-password", "/reset-password", controller.ResetPassword(client))
+
+	// OpenAPI document built from this route table, and a browser for it
+	api.handle(http.MethodGet, "/openapi.json", "/openapi.json", controller.OpenAPISpec(router))
+	api.handle(http.MethodGet, "/docs", "/docs", controller.APIDocs())
+
+	// Operational endpoints stay unversioned.
+	// Prometheus scrape endpoint, guarded by METRICS_TOKEN
+	router.GET("/metrics", middleware.RequireMetricsToken(), gin.WrapH(metrics.Handler()))
+
+	// Liveness and readiness probes
+	router.GET("/healthz", controller.Healthz())
+	router.GET("/readyz", controller.Readyz(client))
 }
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MongoMonitor returns a command monitor that opens a client span per MongoDB command as a child of the
+// operation's context. Command documents are not recorded since they carry user data.
+func MongoMonitor() *event.CommandMonitor {
+	var spans sync.Map
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			collection := ""
+			if elem, err := e.Command.IndexErr(0); err == nil {
+				if name, ok := elem.Value().StringValueOK(); ok {
+					collection = name
+				}
+			}
+
+			name := e.CommandName
+			attrs := []attribute.KeyValue{
+				attribute.String("db.system", "mongodb"),
+				attribute.String("db.name", e.DatabaseName),
+				attribute.String("db.operation", e.CommandName),
+			}
+			if collection != "" {
+				name += " " + collection
+				attrs = append(attrs, attribute.String("db.mongodb.collection", collection))
+			}
+
+			_, span := Tracer().Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+			)
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			if v, ok := spans.LoadAndDelete(e.RequestID); ok {
+				v.(trace.Span).End()
+			}
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			if v, ok := spans.LoadAndDelete(e.RequestID); ok {
+				span := v.(trace.Span)
+				span.RecordError(e.Failure)
+				span.SetStatus(codes.Error, e.Failure.Error())
+				span.End()
+			}
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// APIKeyPrefix starts every API key so it can be told apart from a JWT in the Authorization header
+const APIKeyPrefix = "msk_"
+
+// apiKeyLastUsedInterval limits how often last_used_at is written for a busy key
+const apiKeyLastUsedInterval = time.Minute
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// GenerateAPIKey returns a new random key, its display prefix and the hash stored in its place
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + hex.EncodeToString(bytes)
+	return key, key[:len(APIKeyPrefix)+8], HashAPIKey(key), nil
+}
+
+// HashAPIKey hashes a key for storage and lookup. Keys carry 256 random bits, so unlike
+// passwords they don't need a slow hash, which would otherwise be paid on every request.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether a bearer credential is an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// AuthenticateAPIKey resolves an active API key to claims for its owner. The permissions are those
+// the key was granted that the owner's role still has. IssuedAt is the key's creation time; a
+// force-logout doesn't reject keys by it but sets their revoked_at, so they drop out of ListAPIKeys too.
+func AuthenticateAPIKey(ctx context.Context, client *mongo.Client, key, ip string) (*SignedDetails, error) {
+	apiKeyCollection := database.OpenCollection("api_keys", client)
+
+	var apiKey models.APIKey
+	err := apiKeyCollection.FindOne(ctx, bson.D{
+		{Key: "key_hash", Value: HashAPIKey(key)},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}).Decode(&apiKey)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	err = database.OpenCollection("users", client).FindOne(ctx, bson.D{{Key: "user_id", Value: apiKey.UserID}},
+		options.FindOne().SetProjection(bson.M{"email": 1, "first_name": 1, "last_name": 1, "role": 1, "user_id": 1})).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	rolePermissions, err := ResolvePermissions(ctx, client, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	// Never nil: a nil list would fall back to the role's defaults in the auth middleware
+	permissions := []string{}
+	for _, permission := range apiKey.Permissions {
+		for _, granted := range rolePermissions {
+			if permission == granted {
+				permissions = append(permissions, permission)
+				break
+			}
+		}
+	}
+
+	recordAPIKeyUse(ctx, apiKeyCollection, apiKey, ip, now)
+
+	return &SignedDetails{
+		Email:       user.Email,
+		FirstName:   user.FirstName,
+		LastName:    user.LastName,
+		Role:        user.Role,
+		UserId:      user.UserID,
+		Permissions: permissions,
+		APIKeyId:    apiKey.ID.Hex(),
+		APIKeyWrite: apiKey.Write,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   "MagicStream",
+			IssuedAt: jwt.NewNumericDate(apiKey.CreatedAt),
+		},
+	}, nil
+}
+
+// recordAPIKeyUse stores when and from where the key was last used, at most once a minute
+func recordAPIKeyUse(ctx context.Context, apiKeyCollection *mongo.Collection, apiKey models.APIKey, ip string, now time.Time) {
+	if apiKey.LastUsedAt != nil && now.Sub(*apiKey.LastUsedAt) < apiKeyLastUsedInterval {
+		return
+	}
+
+	_, err := apiKeyCollection.UpdateOne(ctx, bson.D{{Key: "_id", Value: apiKey.ID}}, bson.M{
+		"$set": bson.M{"last_used_at": now, "last_used_ip": ip},
+	})
+	if err != nil {
+		slog.Warn("Failed to record API key use", "key_id", apiKey.ID.Hex(), "error", err)
+	}
+}
+
+// IsReadOnlyAPIKeyFromContext reports whether the request authenticated with an API key that
+// wasn't granted the write scope
+func IsReadOnlyAPIKeyFromContext(c *gin.Context) bool {
+	return GetAPIKeyIdFromContext(c) != "" && !c.GetBool("apiKeyWrite")
+}
+
+// GetAPIKeyIdFromContext returns the id of the API key that authenticated the request, or ""
+func GetAPIKeyIdFromContext(c *gin.Context) string {
+	apiKeyId, exists := c.Get("apiKeyId")
+	if !exists {
+		return ""
+	}
+
+	id, ok := apiKeyId.(string)
+	if !ok {
+		return ""
+	}
+
+	return id
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Fields that must never be copied into the audit log
+var auditRedactedFields = map[string]struct{}{
+	"password":      {},
+	"token":         {},
+	"refresh_token": {},
+	"parental_pin":  {},
+}
+
+// toAuditSnapshot converts a document to plain JSON values so it hashes the same before and after storage
+func toAuditSnapshot(doc interface{}) map[string]interface{} {
+	snapshot := map[string]interface{}{}
+	if doc == nil {
+		return snapshot
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return snapshot
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil || snapshot == nil {
+		return map[string]interface{}{}
+	}
+
+	for field := range auditRedactedFields {
+		delete(snapshot, field)
+	}
+	return snapshot
+}
+
+// diffAuditSnapshots returns the top-level fields whose values differ between before and after
+func diffAuditSnapshots(before, after map[string]interface{}) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+	for field, oldValue := range before {
+		newValue, exists := after[field]
+		if !exists || !reflect.DeepEqual(oldValue, newValue) {
+			changes[field] = models.AuditChange{Before: oldValue, After: newValue}
+		}
+	}
+	for field, newValue := range after {
+		if _, exists := before[field]; !exists {
+			changes[field] = models.AuditChange{Before: nil, After: newValue}
+		}
+	}
+	return changes
+}
+
+// normalizeAuditValue turns decoded BSON documents and arrays back into plain maps and slices
+func normalizeAuditValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case bson.D:
+		m := make(map[string]interface{}, len(value))
+		for _, e := range value {
+			m[e.Key] = normalizeAuditValue(e.Value)
+		}
+		return m
+	case bson.M:
+		m := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			m[k] = normalizeAuditValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			m[k] = normalizeAuditValue(e)
+		}
+		return m
+	case bson.A:
+		s := make([]interface{}, len(value))
+		for i, e := range value {
+			s[i] = normalizeAuditValue(e)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(value))
+		for i, e := range value {
+			s[i] = normalizeAuditValue(e)
+		}
+		return s
+	default:
+		return value
+	}
+}
+
+// ComputeAuditHash returns the chain hash of an event, covering every field except its own hash
+func ComputeAuditHash(event models.AuditEvent) string {
+	changes := make(map[string]interface{}, len(event.Changes))
+	for field, change := range event.Changes {
+		changes[field] = map[string]interface{}{
+			"before": normalizeAuditValue(change.Before),
+			"after":  normalizeAuditValue(change.After),
+		}
+	}
+
+	payload, _ := json.Marshal(struct {
+		Sequence   int64                  `json:"sequence"`
+		PrevHash   string                 `json:"prev_hash"`
+		ActorID    string                 `json:"actor_id"`
+		ActorRole  string                 `json:"actor_role"`
+		Action     string                 `json:"action"`
+		TargetType string                 `json:"target_type"`
+		TargetID   string                 `json:"target_id"`
+		Changes    map[string]interface{} `json:"changes"`
+		IP         string                 `json:"ip"`
+		RequestID  string                 `json:"request_id"`
+		CreatedAt  int64                  `json:"created_at"`
+	}{
+		Sequence:   event.Sequence,
+		PrevHash:   event.PrevHash,
+		ActorID:    event.ActorID,
+		ActorRole:  event.ActorRole,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Changes:    changes,
+		IP:         event.IP,
+		RequestID:  event.RequestID,
+		CreatedAt:  event.CreatedAt.UnixMilli(),
+	})
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordAuditEvent appends an admin mutation to the audit log.
+// before/after are snapshots of the target (nil for creations/deletions); only changed fields are stored.
+func RecordAuditEvent(ctx context.Context, client *mongo.Client, c *gin.Context, action, targetType, targetID string, before, after interface{}) error {
+	actorID, _ := GetUserIdFromContext(c)
+	actorRole, _ := GetRoleFromContext(c)
+
+	event := models.AuditEvent{
+		ActorID:    actorID,
+		ActorRole:  actorRole,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    diffAuditSnapshots(toAuditSnapshot(before), toAuditSnapshot(after)),
+		IP:         c.ClientIP(),
+		RequestID:  GetRequestIdFromContext(c),
+	}
+
+	auditCollection := database.OpenCollection("audit_events", client)
+	latestOpts := options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})
+
+	// The unique sequence index rejects concurrent writers racing for the same slot; retry on conflict
+	for attempt := 0; attempt < 5; attempt++ {
+		var latest models.AuditEvent
+		err := auditCollection.FindOne(ctx, bson.D{}, latestOpts).Decode(&latest)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		event.Sequence = latest.Sequence + 1
+		event.PrevHash = latest.Hash
+		event.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+		event.Hash = ComputeAuditHash(event)
+
+		_, err = auditCollection.InsertOne(ctx, event)
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+
+	return errors.New("audit log is busy, could not append event")
+}
+
+// VerifyAuditChain walks the audit log in order and returns the sequence of the first
+// event whose hash or link to its predecessor does not match (0 if the chain is intact).
+func VerifyAuditChain(ctx context.Context, client *mongo.Client) (checked int64, brokenAt int64, err error) {
+	auditCollection := database.OpenCollection("audit_events", client)
+	cursor, err := auditCollection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	prevHash := ""
+	var expectedSequence int64 = 1
+	for cursor.Next(ctx) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			return checked, 0, err
+		}
+
+		if event.Sequence != expectedSequence || event.PrevHash != prevHash || ComputeAuditHash(event) != event.Hash {
+			return checked, expectedSequence, nil
+		}
+
+		checked++
+		expectedSequence++
+		prevHash = event.Hash
+	}
+
+	return checked, 0, cursor.Err()
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"log/slog"
+)
+
+// Mailer sends outbound email. The default implementation only logs messages
+// (SIMULATION); swap DefaultMailer for a real provider in production.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// MailAttachment is a file sent along with a message
+type MailAttachment struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// AttachmentMailer is implemented by mailers that can deliver file attachments
+type AttachmentMailer interface {
+	SendWithAttachments(to, subject, body string, attachments []MailAttachment) error
+}
+
+// LogMailer writes outbound messages to the server log instead of sending them
+type LogMailer struct{}
+
+// Send logs the message
+func (LogMailer) Send(to, subject, body string) error {
+	slog.Info("Mail simulation", "to", to, "subject", subject, "body", body)
+	return nil
+}
+
+// SendWithAttachments logs the message and the name and size of each attachment
+func (m LogMailer) SendWithAttachments(to, subject, body string, attachments []MailAttachment) error {
+	for _, a := range attachments {
+		slog.Info("Mail simulation attachment", "to", to, "file", a.FileName, "type", a.ContentType, "size", len(a.Data))
+	}
+	return m.Send(to, subject, body)
+}
+
+// DefaultMailer is used by controllers to send notifications
+var DefaultMailer Mailer = LogMailer{}
+
+// SendMailWithAttachments delivers attachments when DefaultMailer supports them;
+// otherwise it sends the body alone, listing the attachment names
+func SendMailWithAttachments(to, subject, body string, attachments []MailAttachment) error {
+	if m, ok := DefaultMailer.(AttachmentMailer); ok {
+		return m.SendWithAttachments(to, subject, body, attachments)
+	}
+
+	body += "\n\nAttachments are not supported by the configured mailer. Files:"
+	for _, a := range attachments {
+		body += "\n- " + a.FileName
+	}
+	return DefaultMailer.Send(to, subject, body)
+}
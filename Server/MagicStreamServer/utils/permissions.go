@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// SeedDefaultRoles inserts the built-in roles that don't exist yet; edited roles are left alone.
+// ADMIN can't be edited, so it is always synced to the full permission list.
+func SeedDefaultRoles(ctx context.Context, client *mongo.Client) error {
+	roleCollection := database.OpenCollection("roles", client)
+	now := time.Now()
+
+	for _, role := range models.DefaultRoles {
+		onInsert := bson.M{
+			"name":        role.Name,
+			"description": role.Description,
+			"built_in":    role.BuiltIn,
+			"created_at":  now,
+			"updated_at":  now,
+		}
+		update := bson.M{"$setOnInsert": onInsert}
+		if role.Name == "ADMIN" {
+			update["$set"] = bson.M{"permissions": models.AllPermissions}
+		} else {
+			onInsert["permissions"] = role.Permissions
+		}
+		_, err := roleCollection.UpdateOne(ctx, bson.D{{Key: "name", Value: role.Name}}, update, options.UpdateOne().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResolvePermissions returns the permissions granted to a role, falling back to the built-in defaults
+func ResolvePermissions(ctx context.Context, client *mongo.Client, roleName string) ([]string, error) {
+	var role models.Role
+	err := database.OpenCollection("roles", client).FindOne(ctx, bson.D{{Key: "name", Value: roleName}}).Decode(&role)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return DefaultPermissionsOrEmpty(roleName), nil
+		}
+		return nil, err
+	}
+
+	if role.Permissions == nil {
+		return []string{}, nil
+	}
+	return role.Permissions, nil
+}
+
+// DefaultPermissionsOrEmpty returns the built-in permissions for a role, or an empty set
+func DefaultPermissionsOrEmpty(roleName string) []string {
+	if perms := models.DefaultRolePermissions(roleName); perms != nil {
+		return perms
+	}
+	return []string{}
+}
+
+// GetPermissionsFromContext returns the permissions carried by the access token
+func GetPermissionsFromContext(c *gin.Context) []string {
+	permissions, exists := c.Get("permissions")
+	if !exists {
+		return []string{}
+	}
+
+	perms, ok := permissions.([]string)
+	if !ok {
+		return []string{}
+	}
+
+	return perms
+}
+
+// HasPermission reports whether the current request carries the given permission
+func HasPermission(c *gin.Context, permission string) bool {
+	for _, p := range GetPermissionsFromContext(c) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -3,10 +3,13 @@ package utils
 import (
 	"sync"
 	"time"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/metrics"
 )
 
 // RateLimiter implements a simple token bucket rate limiter
 type RateLimiter struct {
+	name        string // label for the rejection metric
 	mu          sync.Mutex
 	requests    map[string][]time.Time
 	maxRequests int
@@ -14,10 +17,12 @@ type RateLimiter struct {
 }
 
 // NewRateLimiter creates a new rate limiter
+// name: identifies the limiter in metrics
 // maxRequests: maximum number of requests allowed
 // window: time window in which maxRequests are allowed
-func NewRateLimiter(maxRequests int, window time.Duration) *RateLimiter {
+func NewRateLimiter(name string, maxRequests int, window time.Duration) *RateLimiter {
 	return &RateLimiter{
+		name:        name,
 		requests:    make(map[string][]time.Time),
 		maxRequests: maxRequests,
 		window:      window,
@@ -46,6 +51,7 @@ func (rl *RateLimiter) Allow(key string) bool {
 
 	// Check if limit exceeded
 	if len(rl.requests[key]) >= rl.maxRequests {
+		metrics.RateLimitRejections.WithLabelValues(rl.name).Inc()
 		return false
 	}
 
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// ReportTable is one titled section of a generated report
+type ReportTable struct {
+	Title   string
+	Headers []string
+	Rows    [][]string
+}
+
+// ReportDocument is the format-agnostic content of a report; it is rendered as CSV or PDF
+type ReportDocument struct {
+	Title   string
+	Summary []string // free-form lines printed under the title (period, generation time)
+	Tables  []ReportTable
+}
+
+// RenderReportCSV writes the document as a single CSV file with one block per table,
+// separated by blank lines so spreadsheets keep each section readable
+func RenderReportCSV(doc ReportDocument) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	records := [][]string{{doc.Title}}
+	for _, line := range doc.Summary {
+		records = append(records, []string{line})
+	}
+	for _, table := range doc.Tables {
+		records = append(records, []string{}, []string{table.Title}, table.Headers)
+		records = append(records, table.Rows...)
+	}
+
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PDF layout: A4 portrait, monospaced font so table columns line up without measuring text
+const (
+	pdfPageWidth    = 595
+	pdfPageHeight   = 842
+	pdfMargin       = 50
+	pdfFontSize     = 9
+	pdfLineHeight   = 13
+	pdfMaxLineChars = 91 // (page width - margins) / Courier glyph width of 0.6em
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+)
+
+// RenderReportPDF writes the document as a minimal text-only PDF.
+// It uses the built-in Courier font, so no font embedding or external dependency is needed.
+func RenderReportPDF(doc ReportDocument) []byte {
+	lines := []string{doc.Title, strings.Repeat("=", min(len(doc.Title), pdfMaxLineChars))}
+	lines = append(lines, doc.Summary...)
+	for _, table := range doc.Tables {
+		lines = append(lines, "", table.Title, strings.Repeat("-", min(len(table.Title), pdfMaxLineChars)))
+		lines = append(lines, formatReportTable(table)...)
+	}
+
+	var pages [][]string
+	for len(lines) > 0 {
+		n := min(len(lines), pdfLinesPerPage)
+		pages = append(pages, lines[:n])
+		lines = lines[n:]
+	}
+
+	// Object layout: 1 catalog, 2 page tree, 3 font, then a page + content stream per page
+	var objects []string
+	kids := make([]string, 0, len(pages))
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+2*i))
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	)
+
+	for i, pageLines := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range pageLines {
+			fmt.Fprintf(&content, "(%s) '\n", escapePDFText(line))
+		}
+		content.WriteString("ET")
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return out.Bytes()
+}
+
+// formatReportTable pads every column to its widest cell and truncates to the printable width
+func formatReportTable(table ReportTable) []string {
+	widths := make([]int, len(table.Headers))
+	for i, h := range table.Headers {
+		widths[i] = len(h)
+	}
+	for _, row := range table.Rows {
+		for i, cell := range row {
+			if i < len(widths) && len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	format := func(cells []string) string {
+		parts := make([]string, 0, len(cells))
+		for i, cell := range cells {
+			if i < len(widths) {
+				cell = fmt.Sprintf("%-*s", widths[i], cell)
+			}
+			parts = append(parts, cell)
+		}
+		line := strings.TrimRight(strings.Join(parts, "  "), " ")
+		if len(line) > pdfMaxLineChars {
+			line = line[:pdfMaxLineChars]
+		}
+		return line
+	}
+
+	lines := []string{format(table.Headers)}
+	for _, row := range table.Rows {
+		lines = append(lines, format(row))
+	}
+	if len(table.Rows) == 0 {
+		lines = append(lines, "(no data)")
+	}
+	return lines
+}
+
+// escapePDFText escapes string delimiters and replaces characters outside printable ASCII
+func escapePDFText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Used when REVIEW_BLOCKED_WORDS is not set
+var defaultBlockedWords = []string{"fuck", "shit", "bitch", "cunt", "asshole", "nigger", "faggot", "retard"}
+
+var (
+	reviewLinkPattern = regexp.MustCompile(`(?i)(https?://|www\.)`)
+	reviewWordPattern = regexp.MustCompile(`[\p{L}\p{N}']+`)
+)
+
+// reviewBlockedWords returns the configured blocked words (REVIEW_BLOCKED_WORDS, comma-separated)
+func reviewBlockedWords() []string {
+	v := os.Getenv("REVIEW_BLOCKED_WORDS")
+	if v == "" {
+		return defaultBlockedWords
+	}
+
+	words := []string{}
+	for _, w := range strings.Split(v, ",") {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// reviewMaxLinks returns how many links a review may contain before it is held (REVIEW_MAX_LINKS, default 0)
+func reviewMaxLinks() int {
+	if v := os.Getenv("REVIEW_MAX_LINKS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return 0
+}
+
+// CheckReviewText runs the profanity/spam filter over a review.
+// It returns a short reason when the review should be held for moderation, or "" when it looks fine.
+func CheckReviewText(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+
+	blocked := map[string]struct{}{}
+	for _, w := range reviewBlockedWords() {
+		blocked[w] = struct{}{}
+	}
+	for _, word := range reviewWordPattern.FindAllString(strings.ToLower(text), -1) {
+		if _, hit := blocked[word]; hit {
+			return "profanity"
+		}
+	}
+
+	if len(reviewLinkPattern.FindAllString(text, -1)) > reviewMaxLinks() {
+		return "links"
+	}
+
+	if hasRepeatedRun(text, 10) {
+		return "repeated_characters"
+	}
+
+	// Long reviews written entirely in capitals are almost always spam
+	letters, upper := 0, 0
+	for _, r := range text {
+		if r >= 'a' && r <= 'z' {
+			letters++
+		} else if r >= 'A' && r <= 'Z' {
+			letters++
+			upper++
+		}
+	}
+	if letters >= 30 && upper == letters {
+		return "all_caps"
+	}
+
+	return ""
+}
+
+// hasRepeatedRun reports whether any character repeats at least n times in a row
+// (RE2 has no backreferences, so this can't be a regexp)
+func hasRepeatedRun(text string, n int) bool {
+	var prev rune
+	run := 0
+	for _, r := range text {
+		if r == prev {
+			run++
+		} else {
+			prev, run = r, 1
+		}
+		if run >= n {
+			return true
+		}
+	}
+	return false
+}
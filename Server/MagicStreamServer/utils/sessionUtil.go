@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrAccountSuspended = errors.New("account is suspended")
+	ErrTokenRevoked     = errors.New("token has been revoked")
+	ErrUserNotFound     = errors.New("user not found")
+)
+
+// CheckSession verifies that the token's user still exists, is not suspended
+// and has not been force-logged-out since the token was issued.
+func CheckSession(ctx context.Context, client *mongo.Client, claims *SignedDetails) error {
+	var user models.User
+	projection := bson.M{"status": 1, "tokens_valid_after": 1}
+	err := database.OpenCollection("users", client).FindOne(ctx, bson.D{{Key: "user_id", Value: claims.UserId}},
+		options.FindOne().SetProjection(projection)).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	if user.Status == models.UserStatusSuspended {
+		return ErrAccountSuspended
+	}
+
+	// API keys are revoked one by one (revoked_at), including by RevokeAllTokens
+	if user.TokensValidAfter != nil && claims.APIKeyId == "" {
+		if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(*user.TokensValidAfter) {
+			return ErrTokenRevoked
+		}
+	}
+
+	return nil
+}
+
+// RevocationTime returns the tokens_valid_after value that revokes every token issued so far.
+// Token iat has one-second resolution, so it is rounded up to the next whole second: a token
+// issued earlier in the current second must not survive.
+func RevocationTime() time.Time {
+	return time.Now().Truncate(time.Second).Add(time.Second)
+}
+
+// RevokeSessions invalidates every access/refresh token issued so far to the users matching
+// filter and returns the time tokens are valid from again. API keys are left alone: they
+// resolve the owner's role on every request.
+func RevokeSessions(ctx context.Context, client *mongo.Client, filter bson.D) (time.Time, error) {
+	validAfter := RevocationTime()
+	_, err := database.OpenCollection("users", client).UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{
+			"tokens_valid_after": validAfter,
+			"token":              "",
+			"refresh_token":      "",
+			"update_at":          time.Now(),
+		},
+	})
+	return validAfter, err
+}
+
+// RevokeAllTokens invalidates every access/refresh token issued to the user so far, revokes their
+// API keys and returns the time tokens are valid from again
+func RevokeAllTokens(ctx context.Context, client *mongo.Client, userID string) (time.Time, error) {
+	validAfter, err := RevokeSessions(ctx, client, bson.D{{Key: "user_id", Value: userID}})
+	if err != nil {
+		return validAfter, err
+	}
+
+	_, err = database.OpenCollection("api_keys", client).UpdateMany(ctx, bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	return validAfter, err
+}
+
+// ImpersonationTTL returns how long an impersonation token stays valid.
+// Configured via IMPERSONATION_TTL_MINUTES (default 15, max 60).
+func ImpersonationTTL() time.Duration {
+	minutes := 15
+	if v := os.Getenv("IMPERSONATION_TTL_MINUTES"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 && parsed <= 60 {
+			minutes = parsed
+		}
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GenerateImpersonationToken issues a short-lived, read-only access token for userId on behalf of an admin.
+// It carries no permissions, so admin routes stay closed even if the target is an admin.
+func GenerateImpersonationToken(user models.User, impersonatorId string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ImpersonationTTL())
+	claims := &SignedDetails{
+		Email:          user.Email,
+		FirstName:      user.FirstName,
+		LastName:       user.LastName,
+		Role:           user.Role,
+		UserId:         user.UserID,
+		Permissions:    []string{},
+		ImpersonatorId: impersonatorId,
+		ReadOnly:       true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "MagicStream",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
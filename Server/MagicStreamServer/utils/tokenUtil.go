@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
@@ -20,23 +21,42 @@ type SignedDetails struct {
 	LastName  string
 	Role      string
 	UserId    string
+	// Permissions granted by Role when the token was issued
+	Permissions []string
+	// Set on impersonation tokens: the admin acting as UserId, limited to read-only requests
+	ImpersonatorId string `json:",omitempty"`
+	ReadOnly       bool   `json:",omitempty"`
+	// Set when the request authenticated with an API key rather than a JWT
+	APIKeyId    string `json:",omitempty"`
+	APIKeyWrite bool   `json:",omitempty"`
+	// Set when the session is locked to a kids profile; X-Profile-ID can't select another one
+	ProfileId string `json:",omitempty"`
 	jwt.RegisteredClaims
 }
 
 var SECRET_KEY string = os.Getenv("SECRET_KEY")
 var SECRET_REFRESH_KEY string = os.Getenv("SECRET_REFRESH_KEY")
 
-func GenerateAllTokens(email, firstName, lastName, role, userId string) (string, string, error) {
+// GenerateAllTokens issues an access and a refresh token; profileId locks the session to a kids profile
+func GenerateAllTokens(email, firstName, lastName, role, userId, profileId string, permissions []string) (string, string, error) {
+	return GenerateAllTokensAt(time.Now(), email, firstName, lastName, role, userId, profileId, permissions)
+}
+
+// GenerateAllTokensAt is GenerateAllTokens with an explicit issue time, for sessions reissued
+// right after RevokeAllTokens: issuing them at the returned time keeps them valid
+func GenerateAllTokensAt(issuedAt time.Time, email, firstName, lastName, role, userId, profileId string, permissions []string) (string, string, error) {
 	claims := &SignedDetails{
-		Email:     email,
-		FirstName: firstName,
-		LastName:  lastName,
-		Role:      role,
-		UserId:    userId,
+		Email:       email,
+		FirstName:   firstName,
+		LastName:    lastName,
+		Role:        role,
+		UserId:      userId,
+		Permissions: permissions,
+		ProfileId:   profileId,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    "MagicStream",
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(24 * time.Hour)),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -52,10 +72,11 @@ func GenerateAllTokens(email, firstName, lastName, role, userId string) (string,
 		LastName:  lastName,
 		Role:      role,
 		UserId:    userId,
+		ProfileId: profileId,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    "MagicStream",
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 7 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(24 * 7 * time.Hour)),
 		},
 	}
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
@@ -93,16 +114,22 @@ func UpdateAllTokens(userId, token, refreshToken string, client *mongo.Client) (
 	return nil
 }
 
+// GetAccessToken returns the request's credential: an impersonation token, then an
+// "Authorization: Bearer" JWT or API key, then the access_token cookie
 func GetAccessToken(c *gin.Context) (string, error) {
-	// authHeader := c.Request.Header.Get("Authorization")
-	// if authHeader == "" {
-	// 	return "", errors.New("Authorization header is required")
-	// }
-	// tokenString := authHeader[len("Bearer "):]
-
-	// if tokenString == "" {
-	// 	return "", errors.New("Bearer token is required")
-	// }
+	// Support tools send impersonation tokens in a header so the admin's own session cookie is untouched
+	if impersonationToken := c.GetHeader("X-Impersonation-Token"); impersonationToken != "" {
+		return impersonationToken, nil
+	}
+
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		scheme, tokenString, ok := strings.Cut(authHeader, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(tokenString) == "" {
+			return "", errors.New("authorization header must be \"Bearer <token>\"")
+		}
+		return strings.TrimSpace(tokenString), nil
+	}
+
 	tokenString, err := c.Cookie("access_token")
 	if err != nil {
 
@@ -199,3 +226,74 @@ func HashPassword(password string) (string, error) {
 	}
 	return string(hashedPassword), nil
 }
+
+// GetProfileIdFromContext returns the selected profile id, or "" for the account's primary profile
+func GetProfileIdFromContext(c *gin.Context) string {
+	profileId, exists := c.Get("profileId")
+	if !exists {
+		return ""
+	}
+
+	id, ok := profileId.(string)
+	if !ok {
+		return ""
+	}
+
+	return id
+}
+
+// GetLockedProfileIdFromContext returns the kids profile the session is locked to, or ""
+func GetLockedProfileIdFromContext(c *gin.Context) string {
+	profileId, exists := c.Get("lockedProfileId")
+	if !exists {
+		return ""
+	}
+
+	id, ok := profileId.(string)
+	if !ok {
+		return ""
+	}
+
+	return id
+}
+
+// IsKidsProfileFromContext reports whether the selected profile is a kids profile
+func IsKidsProfileFromContext(c *gin.Context) bool {
+	isKids, exists := c.Get("isKidsProfile")
+	if !exists {
+		return false
+	}
+
+	kids, ok := isKids.(bool)
+	return ok && kids
+}
+
+// GetRequestIdFromContext returns the request ID assigned by RequestIdMiddleware
+func GetRequestIdFromContext(c *gin.Context) string {
+	requestId, exists := c.Get("requestId")
+	if !exists {
+		return ""
+	}
+
+	id, ok := requestId.(string)
+	if !ok {
+		return ""
+	}
+
+	return id
+}
+
+// GetImpersonatorIdFromContext returns the admin id behind an impersonated request, or ""
+func GetImpersonatorIdFromContext(c *gin.Context) string {
+	impersonatorId, exists := c.Get("impersonatorId")
+	if !exists {
+		return ""
+	}
+
+	id, ok := impersonatorId.(string)
+	if !ok {
+		return ""
+	}
+
+	return id
+}
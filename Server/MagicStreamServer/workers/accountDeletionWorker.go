@@ -0,0 +1,157 @@
+package workers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// AccountDeletionGracePeriod returns how long a deletion request waits before data is purged.
+// Configured via ACCOUNT_DELETION_GRACE_DAYS (default 30, 0 purges immediately).
+func AccountDeletionGracePeriod() time.Duration {
+	graceDays := 30
+	if v := os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			graceDays = parsed
+		}
+	}
+
+	return time.Duration(graceDays) * 24 * time.Hour
+}
+
+// PseudonymizeUserID returns a stable, non-reversible identifier for a deleted user.
+// The same user always maps to the same pseudonym so accounting and cohort data stay consistent.
+func PseudonymizeUserID(userID string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("SECRET_KEY")))
+	mac.Write([]byte(userID))
+	return "deleted_" + hex.EncodeToString(mac.Sum(nil))[:24]
+}
+
+// PurgeUser removes or anonymizes everything keyed to the user:
+// cancels subscriptions, anonymizes ratings, deletes watchlists, watch history, tokens and API keys,
+// pseudonymizes payments (kept for accounting) and finally deletes the user document.
+func PurgeUser(ctx context.Context, client *mongo.Client, userID string) error {
+	now := time.Now()
+	pseudonym := PseudonymizeUserID(userID)
+	userFilter := bson.D{{Key: "user_id", Value: userID}}
+
+	subscriptionCollection := database.OpenCollection("subscriptions", client)
+	_, err := subscriptionCollection.UpdateMany(ctx, bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: []string{"ACTIVE", "PENDING"}}}},
+	}, bson.M{"$set": bson.M{
+		"status":        "CANCELED",
+		"auto_renew":    false,
+		"canceled_at":   now,
+		"cancel_reason": models.CancelReasonAccountDeleted,
+		"updated_at":    now,
+	}})
+	if err != nil {
+		return err
+	}
+	_, err = subscriptionCollection.UpdateMany(ctx, userFilter, bson.M{"$set": bson.M{"user_id": pseudonym}})
+	if err != nil {
+		return err
+	}
+
+	// Ratings keep their score so movie aggregates are unchanged
+	ratingCollection := database.OpenCollection("ratings", client)
+	_, err = ratingCollection.UpdateMany(ctx, userFilter, bson.M{
+		"$set":   bson.M{"user_id": pseudonym},
+		"$unset": bson.M{"review_text": ""},
+	})
+	if err != nil {
+		return err
+	}
+
+	paymentCollection := database.OpenCollection("payments", client)
+	_, err = paymentCollection.UpdateMany(ctx, userFilter, bson.M{
+		"$set":   bson.M{"user_id": pseudonym},
+		"$unset": bson.M{"card_last4": ""},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"watchlists", "watch_progress", "profiles", "password_resets", "email_verifications", "email_changes", "review_reports", "review_votes", "api_keys"} {
+		if _, err := database.OpenCollection(name, client).DeleteMany(ctx, userFilter); err != nil {
+			return err
+		}
+	}
+
+	_, err = database.OpenCollection("users", client).DeleteOne(ctx, userFilter)
+	return err
+}
+
+// PurgeDueAccounts purges every account whose deletion grace period has elapsed
+func PurgeDueAccounts(ctx context.Context, client *mongo.Client) (int, error) {
+	userCollection := database.OpenCollection("users", client)
+	filter := bson.D{{Key: "deletion_scheduled_at", Value: bson.D{{Key: "$lte", Value: time.Now()}}}}
+
+	cursor, err := userCollection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range users {
+		// Stop between accounts on shutdown; each purge can be retried from the start
+		if ctx.Err() != nil {
+			return purged, ctx.Err()
+		}
+		if err := PurgeUser(ctx, client, user.UserID); err != nil {
+			slog.Warn("Failed to purge account", "user_id", user.UserID, "error", err)
+			continue
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// StartAccountDeletionWorker periodically purges accounts past their grace period, until ctx is cancelled
+func StartAccountDeletionWorker(ctx context.Context, client *mongo.Client, interval time.Duration) {
+	state := registerWorker("account_deletion", interval, 5*time.Minute)
+	workerGroup.Add(1)
+	go func() {
+		defer workerGroup.Done()
+		defer state.stopped()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			purged, err := PurgeDueAccounts(runCtx, client)
+			cancel()
+			state.ran(err)
+
+			if err != nil {
+				slog.Warn("Account deletion worker failed", "error", err)
+			} else if purged > 0 {
+				slog.Info("Account deletion worker purged accounts", "accounts", purged)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
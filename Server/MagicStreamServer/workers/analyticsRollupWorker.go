@@ -0,0 +1,346 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const rollupDayFormat = "2006-01-02"
+
+// UTCDay truncates t to midnight UTC
+func UTCDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+type rollupRow struct {
+	ID struct {
+		Day    string `bson:"day"`
+		PlanID string `bson:"plan_id"`
+	} `bson:"_id"`
+	Count  int64   `bson:"count"`
+	Amount float64 `bson:"amount"`
+}
+
+// aggregateByDay groups documents matching base by the UTC day of timeExpr within [from, to).
+// When byPlan is set rows are also split by plan_id; amount sums the amount field.
+func aggregateByDay(ctx context.Context, collection *mongo.Collection, base bson.M, timeExpr interface{}, from, to time.Time, byPlan bool) ([]rollupRow, error) {
+	groupID := bson.M{"day": bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$fact_time"}}}
+	if byPlan {
+		groupID["plan_id"] = "$plan_id"
+	}
+
+	pipeline := []bson.M{
+		{"$match": base},
+		{"$addFields": bson.M{"fact_time": timeExpr}},
+		{"$match": bson.M{"fact_time": bson.M{"$gte": from, "$lt": to}}},
+		{"$group": bson.M{
+			"_id":    groupID,
+			"count":  bson.M{"$sum": 1},
+			"amount": bson.M{"$sum": bson.M{"$ifNull": []interface{}{"$amount", 0}}},
+		}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []rollupRow
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// RollupAnalyticsDays recomputes the daily facts for every UTC day in [from, to) and upserts them
+// into analytics_daily. Days without activity get zeroed documents so gaps are explicit.
+func RollupAnalyticsDays(ctx context.Context, client *mongo.Client, from, to time.Time) (int, error) {
+	from, to = UTCDay(from), UTCDay(to)
+	if !to.After(from) {
+		return 0, nil
+	}
+
+	facts := map[string]*models.AnalyticsDaily{}
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		facts[d.Format(rollupDayFormat)] = &models.AnalyticsDaily{
+			Day:                 d,
+			RevenueByPlan:       map[string]float64{},
+			SubscriptionsByPlan: map[string]int64{},
+		}
+	}
+	factFor := func(day string) *models.AnalyticsDaily {
+		return facts[day]
+	}
+
+	payments, err := aggregateByDay(ctx, database.OpenCollection("payments", client),
+		bson.M{"status": "SUCCESS"}, "$created_at", from, to, true)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range payments {
+		if f := factFor(r.ID.Day); f != nil {
+			f.Revenue += r.Amount
+			f.Payments += r.Count
+			f.RevenueByPlan[r.ID.PlanID] += r.Amount
+		}
+	}
+
+	subscriptionCollection := database.OpenCollection("subscriptions", client)
+	newSubscriptions, err := aggregateByDay(ctx, subscriptionCollection, bson.M{}, "$created_at", from, to, true)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range newSubscriptions {
+		if f := factFor(r.ID.Day); f != nil {
+			f.NewSubscriptions += r.Count
+			f.SubscriptionsByPlan[r.ID.PlanID] += r.Count
+		}
+	}
+
+	// Subscriptions canceled before canceled_at was recorded fall back to updated_at
+	cancellations, err := aggregateByDay(ctx, subscriptionCollection, bson.M{"status": "CANCELED"},
+		bson.M{"$ifNull": []interface{}{"$canceled_at", "$updated_at"}}, from, to, false)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range cancellations {
+		if f := factFor(r.ID.Day); f != nil {
+			f.Cancellations += r.Count
+		}
+	}
+
+	simpleCounts := []struct {
+		collection string
+		base       bson.M
+		apply      func(f *models.AnalyticsDaily, n int64)
+	}{
+		{"users", bson.M{}, func(f *models.AnalyticsDaily, n int64) { f.Signups += n }},
+		{"ratings", RatingAggregateMatch(), func(f *models.AnalyticsDaily, n int64) { f.Ratings += n }},
+		{"watchlists", bson.M{}, func(f *models.AnalyticsDaily, n int64) { f.WatchlistAdds += n }},
+	}
+	for _, sc := range simpleCounts {
+		rows, err := aggregateByDay(ctx, database.OpenCollection(sc.collection, client), sc.base, "$created_at", from, to, false)
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range rows {
+			if f := factFor(r.ID.Day); f != nil {
+				sc.apply(f, r.Count)
+			}
+		}
+	}
+
+	rollupCollection := database.OpenCollection("analytics_daily", client)
+	now := time.Now()
+	for _, f := range facts {
+		// $set leaves any totals snapshot on the day untouched
+		_, err := rollupCollection.UpdateOne(ctx, bson.D{{Key: "day", Value: f.Day}}, bson.M{"$set": bson.M{
+			"revenue":               f.Revenue,
+			"payments":              f.Payments,
+			"revenue_by_plan":       f.RevenueByPlan,
+			"signups":               f.Signups,
+			"new_subscriptions":     f.NewSubscriptions,
+			"subscriptions_by_plan": f.SubscriptionsByPlan,
+			"cancellations":         f.Cancellations,
+			"ratings":               f.Ratings,
+			"watchlist_adds":        f.WatchlistAdds,
+			"updated_at":            now,
+		}}, options.UpdateOne().SetUpsert(true))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(facts), nil
+}
+
+// ComputeAnalyticsTotals counts the dashboard headline numbers live
+func ComputeAnalyticsTotals(ctx context.Context, client *mongo.Client) (models.AnalyticsTotals, error) {
+	totals := models.AnalyticsTotals{ComputedAt: time.Now()}
+
+	var err error
+	if totals.Movies, err = database.OpenCollection("movies", client).CountDocuments(ctx, bson.D{}); err != nil {
+		return totals, err
+	}
+	if totals.Users, err = database.OpenCollection("users", client).CountDocuments(ctx, bson.D{}); err != nil {
+		return totals, err
+	}
+
+	activeSubscriptionFilter := bson.D{
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: totals.ComputedAt}}},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: []string{"ACTIVE", "CANCELED"}}}},
+	}
+	if totals.ActiveSubscriptions, err = database.OpenCollection("subscriptions", client).CountDocuments(ctx, activeSubscriptionFilter); err != nil {
+		return totals, err
+	}
+	if totals.Ratings, err = database.OpenCollection("ratings", client).CountDocuments(ctx, bson.D{}); err != nil {
+		return totals, err
+	}
+	if totals.WatchlistItems, err = database.OpenCollection("watchlists", client).CountDocuments(ctx, bson.D{}); err != nil {
+		return totals, err
+	}
+
+	cursor, err := database.OpenCollection("payments", client).Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"status": "SUCCESS"}},
+		{"$group": bson.M{"_id": nil, "amount": bson.M{"$sum": "$amount"}}},
+	})
+	if err != nil {
+		return totals, err
+	}
+	var rows []struct {
+		Amount float64 `bson:"amount"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return totals, err
+	}
+	if len(rows) > 0 {
+		totals.Revenue = rows[0].Amount
+	}
+
+	return totals, nil
+}
+
+// SnapshotAnalyticsTotals stores the current headline numbers on today's rollup document
+func SnapshotAnalyticsTotals(ctx context.Context, client *mongo.Client) error {
+	totals, err := ComputeAnalyticsTotals(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.OpenCollection("analytics_daily", client).UpdateOne(ctx,
+		bson.D{{Key: "day", Value: UTCDay(totals.ComputedAt)}},
+		bson.M{"$set": bson.M{"totals": totals}},
+		options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+// earliestActivity returns the oldest created_at across the collections that feed the rollups
+func earliestActivity(ctx context.Context, client *mongo.Client) (time.Time, bool, error) {
+	var earliest time.Time
+	found := false
+
+	for _, name := range []string{"payments", "users", "subscriptions", "ratings", "watchlists"} {
+		var doc struct {
+			CreatedAt time.Time `bson:"created_at"`
+		}
+		err := database.OpenCollection(name, client).FindOne(ctx,
+			bson.D{{Key: "created_at", Value: bson.D{{Key: "$type", Value: "date"}}}},
+			options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetProjection(bson.M{"created_at": 1}),
+		).Decode(&doc)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return earliest, false, err
+		}
+		if !found || doc.CreatedAt.Before(earliest) {
+			earliest = doc.CreatedAt
+			found = true
+		}
+	}
+
+	return earliest, found, nil
+}
+
+// BackfillAnalyticsRollups computes every day since the first recorded activity that has
+// no analytics_daily document yet. Existing days are left alone; use RebuildAnalyticsRollups for those.
+func BackfillAnalyticsRollups(ctx context.Context, client *mongo.Client) (int, error) {
+	earliest, found, err := earliestActivity(ctx, client)
+	if err != nil || !found {
+		return 0, err
+	}
+
+	var days []time.Time
+	if err := database.OpenCollection("analytics_daily", client).Distinct(ctx, "day", bson.D{}).Decode(&days); err != nil {
+		return 0, err
+	}
+	existing := make(map[string]struct{}, len(days))
+	for _, d := range days {
+		existing[d.UTC().Format(rollupDayFormat)] = struct{}{}
+	}
+
+	// Roll up each contiguous run of missing days with one pass per collection
+	filled := 0
+	end := UTCDay(time.Now()).AddDate(0, 0, 1)
+	var runStart time.Time
+	for d := UTCDay(earliest); !d.After(end); d = d.AddDate(0, 0, 1) {
+		_, have := existing[d.Format(rollupDayFormat)]
+		missing := !have && d.Before(end)
+
+		if missing && runStart.IsZero() {
+			runStart = d
+		}
+		if !missing && !runStart.IsZero() {
+			n, err := RollupAnalyticsDays(ctx, client, runStart, d)
+			if err != nil {
+				return filled, err
+			}
+			filled += n
+			runStart = time.Time{}
+		}
+	}
+
+	return filled, nil
+}
+
+// RebuildAnalyticsRollups recomputes every day in [from, to], overwriting existing facts.
+// Use it after corrections to historical data (refunds, purges, reactivations).
+func RebuildAnalyticsRollups(ctx context.Context, client *mongo.Client, from, to time.Time) (int, error) {
+	return RollupAnalyticsDays(ctx, client, from, UTCDay(to).AddDate(0, 0, 1))
+}
+
+// StartAnalyticsRollupWorker backfills missing days once, then keeps yesterday and today current
+// and refreshes the totals snapshot on every tick, until ctx is cancelled
+func StartAnalyticsRollupWorker(ctx context.Context, client *mongo.Client, interval time.Duration) {
+	// The initial backfill may take up to 30 minutes before the first regular run
+	state := registerWorker("analytics_rollup", interval, 30*time.Minute)
+	workerGroup.Add(1)
+	go func() {
+		defer workerGroup.Done()
+		defer state.stopped()
+		runCtx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+		filled, err := BackfillAnalyticsRollups(runCtx, client)
+		cancel()
+		if err != nil {
+			slog.Warn("Analytics rollup backfill failed", "error", err)
+		} else if filled > 0 {
+			slog.Info("Analytics rollup backfill computed days", "days", filled)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			today := UTCDay(time.Now())
+			// Yesterday is included so late writes around midnight are picked up
+			_, err := RollupAnalyticsDays(runCtx, client, today.AddDate(0, 0, -1), today.AddDate(0, 0, 1))
+			if err == nil {
+				err = SnapshotAnalyticsTotals(runCtx, client)
+			}
+			cancel()
+			state.ran(err)
+
+			if err != nil {
+				slog.Warn("Analytics rollup worker failed", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,267 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// HiddenRatingsInAggregates reports whether hidden reviews still count toward a movie's average.
+// Configured via REVIEW_HIDDEN_IN_AGGREGATES (default true: hiding removes the text, not the score).
+func HiddenRatingsInAggregates() bool {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv("REVIEW_HIDDEN_IN_AGGREGATES")))
+	return v != "false" && v != "0"
+}
+
+// RatingCounted reports whether a rating with the given moderation status counts toward aggregates.
+// Pending ratings only count once a moderator approves them.
+func RatingCounted(moderationStatus string) bool {
+	switch moderationStatus {
+	case models.ReviewStatusPending:
+		return false
+	case models.ReviewStatusHidden:
+		return HiddenRatingsInAggregates()
+	}
+	return true
+}
+
+// RatingAggregateMatch matches the ratings that count toward aggregates
+func RatingAggregateMatch() bson.M {
+	excluded := []string{models.ReviewStatusPending}
+	if !HiddenRatingsInAggregates() {
+		excluded = append(excluded, models.ReviewStatusHidden)
+	}
+	return bson.M{"moderation_status": bson.M{"$nin": excluded}}
+}
+
+// ApplyRatingDelta atomically adjusts a movie's materialized rating aggregates.
+// removeStar is the rating leaving the aggregate and addStar the one joining it (0 means none).
+func ApplyRatingDelta(ctx context.Context, client *mongo.Client, imdbID string, removeStar, addStar int) error {
+	if removeStar == addStar {
+		return nil
+	}
+
+	countDelta, sumDelta := 0, addStar-removeStar
+	histogramDelta := bson.M{}
+	if removeStar > 0 {
+		countDelta--
+		key := strconv.Itoa(removeStar)
+		histogramDelta[key] = bson.M{"$add": []interface{}{bson.M{"$ifNull": []interface{}{"$rating_histogram." + key, 0}}, -1}}
+	}
+	if addStar > 0 {
+		countDelta++
+		key := strconv.Itoa(addStar)
+		histogramDelta[key] = bson.M{"$add": []interface{}{bson.M{"$ifNull": []interface{}{"$rating_histogram." + key, 0}}, 1}}
+	}
+
+	emptyHistogram := bson.M{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}
+
+	// Update pipelines are applied atomically per document, so concurrent ratings can't lose increments
+	pipeline := []bson.M{
+		{"$set": bson.M{
+			"rating_count": bson.M{"$add": []interface{}{bson.M{"$ifNull": []interface{}{"$rating_count", 0}}, countDelta}},
+			"rating_sum":   bson.M{"$add": []interface{}{bson.M{"$ifNull": []interface{}{"$rating_sum", 0}}, sumDelta}},
+			"rating_histogram": bson.M{"$mergeObjects": []interface{}{
+				emptyHistogram,
+				bson.M{"$ifNull": []interface{}{"$rating_histogram", bson.M{}}},
+				histogramDelta,
+			}},
+		}},
+		{"$set": bson.M{
+			"avg_rating": bson.M{"$cond": []interface{}{
+				bson.M{"$gt": []interface{}{"$rating_count", 0}},
+				bson.M{"$divide": []interface{}{"$rating_sum", "$rating_count"}},
+				0,
+			}},
+		}},
+	}
+
+	_, err := database.OpenCollection("movies", client).UpdateOne(ctx, bson.D{{Key: "imdb_id", Value: imdbID}}, pipeline)
+	return err
+}
+
+// movieRatingStats holds the aggregates computed from the ratings collection for one movie
+type movieRatingStats struct {
+	Count     int64
+	Sum       int64
+	Histogram map[string]int64
+}
+
+func newMovieRatingStats() *movieRatingStats {
+	return &movieRatingStats{Histogram: map[string]int64{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}}
+}
+
+// computeRatingStats aggregates ratings per movie; imdbIDs limits the movies (nil means all)
+func computeRatingStats(ctx context.Context, client *mongo.Client, imdbIDs []string) (map[string]*movieRatingStats, error) {
+	match := RatingAggregateMatch()
+	if imdbIDs != nil {
+		match["imdb_id"] = bson.M{"$in": imdbIDs}
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":   bson.M{"imdb_id": "$imdb_id", "rating": "$rating"},
+			"count": bson.M{"$sum": 1},
+		}},
+	}
+
+	cursor, err := database.OpenCollection("ratings", client).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var buckets []struct {
+		ID struct {
+			ImdbID string `bson:"imdb_id"`
+			Rating int    `bson:"rating"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &buckets); err != nil {
+		return nil, err
+	}
+
+	stats := map[string]*movieRatingStats{}
+	for _, b := range buckets {
+		if b.ID.Rating < 1 || b.ID.Rating > 5 {
+			continue
+		}
+		s, ok := stats[b.ID.ImdbID]
+		if !ok {
+			s = newMovieRatingStats()
+			stats[b.ID.ImdbID] = s
+		}
+		s.Count += b.Count
+		s.Sum += int64(b.ID.Rating) * b.Count
+		s.Histogram[strconv.Itoa(b.ID.Rating)] = b.Count
+	}
+	return stats, nil
+}
+
+// ratingStatsUpdate builds the $set that stores stats on a movie document
+func ratingStatsUpdate(s *movieRatingStats) bson.M {
+	avg := 0.0
+	if s.Count > 0 {
+		avg = float64(s.Sum) / float64(s.Count)
+	}
+	return bson.M{"$set": bson.M{
+		"avg_rating":       avg,
+		"rating_count":     s.Count,
+		"rating_sum":       s.Sum,
+		"rating_histogram": s.Histogram,
+	}}
+}
+
+// RecomputeMovieRatings rebuilds the aggregates of the given movies from the ratings collection.
+// Used after bulk rating changes (e.g. deleting a profile) where per-rating deltas are impractical.
+func RecomputeMovieRatings(ctx context.Context, client *mongo.Client, imdbIDs ...string) error {
+	if len(imdbIDs) == 0 {
+		return nil
+	}
+
+	stats, err := computeRatingStats(ctx, client, imdbIDs)
+	if err != nil {
+		return err
+	}
+
+	movieCollection := database.OpenCollection("movies", client)
+	for _, imdbID := range imdbIDs {
+		s, ok := stats[imdbID]
+		if !ok {
+			s = newMovieRatingStats()
+		}
+		if _, err := movieCollection.UpdateOne(ctx, bson.D{{Key: "imdb_id", Value: imdbID}}, ratingStatsUpdate(s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReconcileRatingAggregates compares every movie's materialized aggregates with the ratings
+// collection and repairs any drift. Returns the number of movies fixed.
+func ReconcileRatingAggregates(ctx context.Context, client *mongo.Client) (int, error) {
+	stats, err := computeRatingStats(ctx, client, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	movieCollection := database.OpenCollection("movies", client)
+	projection := bson.M{"imdb_id": 1, "rating_count": 1, "rating_sum": 1, "rating_histogram": 1}
+	cursor, err := movieCollection.Find(ctx, bson.D{}, options.Find().SetProjection(projection))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	repaired := 0
+	for cursor.Next(ctx) {
+		var movie models.Movie
+		if err := cursor.Decode(&movie); err != nil {
+			return repaired, err
+		}
+
+		s, ok := stats[movie.ImdbID]
+		if !ok {
+			s = newMovieRatingStats()
+		}
+
+		inSync := movie.RatingHistogram != nil && movie.RatingCount == s.Count && movie.RatingSum == s.Sum
+		for star, count := range s.Histogram {
+			if movie.RatingHistogram[star] != count {
+				inSync = false
+			}
+		}
+		if inSync {
+			continue
+		}
+
+		if _, err := movieCollection.UpdateOne(ctx, bson.D{{Key: "_id", Value: movie.ID}}, ratingStatsUpdate(s)); err != nil {
+			return repaired, err
+		}
+		repaired++
+	}
+
+	return repaired, cursor.Err()
+}
+
+// StartRatingReconciliationWorker periodically repairs drift in the materialized rating aggregates, until ctx is cancelled
+func StartRatingReconciliationWorker(ctx context.Context, client *mongo.Client, interval time.Duration) {
+	state := registerWorker("rating_reconciliation", interval, 10*time.Minute)
+	workerGroup.Add(1)
+	go func() {
+		defer workerGroup.Done()
+		defer state.stopped()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+			repaired, err := ReconcileRatingAggregates(runCtx, client)
+			cancel()
+			state.ran(err)
+
+			if err != nil {
+				slog.Warn("Rating reconciliation failed", "error", err)
+			} else if repaired > 0 {
+				slog.Info("Rating reconciliation repaired movies", "movies", repaired)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
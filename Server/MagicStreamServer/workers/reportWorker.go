@@ -0,0 +1,142 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database"
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ReportRunner renders and delivers one scheduled report for the period [start, end)
+type ReportRunner func(ctx context.Context, client *mongo.Client, report models.ScheduledReport, start, end time.Time) error
+
+// reportPeriodStart returns the UTC start of the daily/weekly/monthly period containing t.
+// Weeks start on Monday to match the week granularity of the analytics endpoints.
+func reportPeriodStart(frequency string, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch frequency {
+	case models.ReportFrequencyWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case models.ReportFrequencyMonthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// addReportPeriods moves a period start forward (or backward) by n periods
+func addReportPeriods(frequency string, t time.Time, n int) time.Time {
+	switch frequency {
+	case models.ReportFrequencyWeekly:
+		return t.AddDate(0, 0, 7*n)
+	case models.ReportFrequencyMonthly:
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+// LastCompleteReportPeriod returns the most recent fully elapsed period before now, as [start, end)
+func LastCompleteReportPeriod(frequency string, now time.Time) (time.Time, time.Time) {
+	end := reportPeriodStart(frequency, now)
+	return addReportPeriods(frequency, end, -1), end
+}
+
+// NextReportRun returns when the period containing now closes and its report becomes due
+func NextReportRun(frequency string, now time.Time) time.Time {
+	return addReportPeriods(frequency, reportPeriodStart(frequency, now), 1)
+}
+
+// RunDueReports claims and runs every enabled report whose next_run_at has passed.
+// Claiming advances next_run_at atomically, so concurrent instances never run the same report twice.
+func RunDueReports(ctx context.Context, client *mongo.Client, run ReportRunner) (int, error) {
+	reportCollection := database.OpenCollection("scheduled_reports", client)
+	ran := 0
+
+	for {
+		now := time.Now()
+
+		var report models.ScheduledReport
+		err := reportCollection.FindOne(ctx, bson.D{
+			{Key: "enabled", Value: true},
+			{Key: "next_run_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		}, options.FindOne().SetSort(bson.D{{Key: "next_run_at", Value: 1}})).Decode(&report)
+		if err == mongo.ErrNoDocuments {
+			return ran, nil
+		}
+		if err != nil {
+			return ran, err
+		}
+
+		claim, err := reportCollection.UpdateOne(ctx, bson.D{
+			{Key: "_id", Value: report.ID},
+			{Key: "next_run_at", Value: report.NextRunAt},
+		}, bson.M{"$set": bson.M{"next_run_at": NextReportRun(report.Frequency, now)}})
+		if err != nil {
+			return ran, err
+		}
+		if claim.ModifiedCount == 0 {
+			// Another instance claimed it first
+			continue
+		}
+
+		// A report that was due a while ago (e.g. server was down) still covers the
+		// period that closed at its scheduled time, not the current one
+		start, end := LastCompleteReportPeriod(report.Frequency, report.NextRunAt)
+
+		runErr := run(ctx, client, report, start, end)
+		lastError := ""
+		if runErr != nil {
+			slog.Warn("Scheduled report failed", "report_id", report.ID.Hex(), "error", runErr)
+			lastError = runErr.Error()
+		}
+
+		_, err = reportCollection.UpdateOne(ctx, bson.D{{Key: "_id", Value: report.ID}}, bson.M{"$set": bson.M{
+			"last_run_at": now,
+			"last_error":  lastError,
+		}})
+		if err != nil {
+			return ran, err
+		}
+		ran++
+	}
+}
+
+// StartScheduledReportWorker periodically generates reports that have come due, until ctx is cancelled
+func StartScheduledReportWorker(ctx context.Context, client *mongo.Client, interval time.Duration, run ReportRunner) {
+	state := registerWorker("scheduled_reports", interval, 10*time.Minute)
+	workerGroup.Add(1)
+	go func() {
+		defer workerGroup.Done()
+		defer state.stopped()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+			ran, err := RunDueReports(runCtx, client, run)
+			cancel()
+			state.ran(err)
+
+			if err != nil {
+				slog.Warn("Scheduled report worker failed", "error", err)
+			} else if ran > 0 {
+				slog.Info("Scheduled report worker generated reports", "reports", ran)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,112 @@
+package workers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WorkerStatus describes a background worker for the readiness probe
+type WorkerStatus struct {
+	Name      string     `json:"name"`
+	Running   bool       `json:"running"`
+	Healthy   bool       `json:"healthy"`
+	StartedAt time.Time  `json:"started_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type workerState struct {
+	name       string
+	staleAfter time.Duration
+	running    bool
+	startedAt  time.Time
+	lastRunAt  *time.Time
+	lastError  string
+}
+
+var workerRegistry = struct {
+	sync.Mutex
+	workers map[string]*workerState
+}{workers: map[string]*workerState{}}
+
+// registerWorker records that a worker loop started. A worker is considered stuck once it has
+// not completed a run for two intervals plus the time one run is allowed to take.
+func registerWorker(name string, interval, runTimeout time.Duration) *workerState {
+	state := &workerState{
+		name:       name,
+		staleAfter: 2*interval + runTimeout,
+		running:    true,
+		startedAt:  time.Now(),
+	}
+
+	workerRegistry.Lock()
+	workerRegistry.workers[name] = state
+	workerRegistry.Unlock()
+	return state
+}
+
+// ran records the outcome of one run; a failed run still counts as the worker being alive
+func (w *workerState) ran(err error) {
+	workerRegistry.Lock()
+	defer workerRegistry.Unlock()
+	now := time.Now()
+	w.lastRunAt = &now
+	w.lastError = ""
+	if err != nil {
+		w.lastError = err.Error()
+	}
+}
+
+// stopped records that the worker loop exited
+func (w *workerState) stopped() {
+	workerRegistry.Lock()
+	defer workerRegistry.Unlock()
+	w.running = false
+}
+
+// WorkerStatuses lists every registered worker, sorted by name
+func WorkerStatuses() []WorkerStatus {
+	workerRegistry.Lock()
+	defer workerRegistry.Unlock()
+
+	now := time.Now()
+	statuses := make([]WorkerStatus, 0, len(workerRegistry.workers))
+	for _, w := range workerRegistry.workers {
+		lastActivity := w.startedAt
+		if w.lastRunAt != nil {
+			lastActivity = *w.lastRunAt
+		}
+		statuses = append(statuses, WorkerStatus{
+			Name:      w.name,
+			Running:   w.running,
+			Healthy:   w.running && now.Sub(lastActivity) <= w.staleAfter,
+			StartedAt: w.startedAt,
+			LastRunAt: w.lastRunAt,
+			LastError: w.lastError,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// workerGroup tracks the running worker loops so shutdown can wait for in-flight runs
+var workerGroup sync.WaitGroup
+
+// Wait blocks until every worker loop has exited after its context was cancelled, or ctx is done
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		workerGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,41 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tuananhsilly/MagicStream-main/Server/MagicStreamServer/database/mongotest"
+)
+
+func TestWaitReturnsWhenCancelledDuringARun(t *testing.T) {
+	t.Setenv("DATABASE_NAME", "workers")
+	client, deployment, err := mongotest.NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment.Block()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartAccountDeletionWorker(ctx, client, time.Hour)
+	StartRatingReconciliationWorker(ctx, client, time.Hour)
+	StartScheduledReportWorker(ctx, client, time.Hour, nil)
+	StartAnalyticsRollupWorker(ctx, client, time.Hour)
+
+	// Every worker starts with a run, each of which is now stuck on its first query
+	for i := 0; i < 4; i++ {
+		select {
+		case <-deployment.Held():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of 4 workers started a run", i)
+		}
+	}
+	cancel()
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	if err := Wait(waitCtx); err != nil {
+		t.Fatalf("Wait did not return after the workers were cancelled mid-run: %v", err)
+	}
+}